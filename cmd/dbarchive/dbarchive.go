@@ -0,0 +1,107 @@
+// This package dumps a db to a JSON archive, or restores one from it, like !db dump and !db restore.
+//
+//	dbarchive dump -db bot.db -o bot.json [indexes...]
+//	dbarchive restore -db bot.db -i bot.json [indexes...]
+//
+// Give indexes to only dump or restore those. Stop the bot before restoring.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	_ "github.com/unswpcsoc/pcsocgo/handlers" // adds the migrations
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var (
+		fs    = flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+		store = fs.String("store", commands.DefaultStore, "Store the db is in, one of "+strings.Join(commands.Stores(), ", "))
+		path  = fs.String("db", "bot.db", "Path to the db")
+		file  string
+	)
+
+	switch os.Args[1] {
+	case "dump":
+		fs.StringVar(&file, "o", "-", "File to write the archive to, - for stdout")
+	case "restore":
+		fs.StringVar(&file, "i", "-", "File to read the archive from, - for stdin")
+	default:
+		usage()
+	}
+	fs.Parse(os.Args[2:])
+
+	commands.MigrateLogf = log.Printf
+	// dumping leaves the db as it is, restoring migrates it anyway
+	commands.DBDryRun = os.Args[1] == "dump"
+	err := commands.DBOpenStore(*store, *path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer commands.DBClose()
+
+	if os.Args[1] == "dump" {
+		err = dump(file, fs.Args())
+	} else {
+		err = restore(file, fs.Args())
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dbarchive dump|restore [flags] [indexes...]")
+	os.Exit(2)
+}
+
+// dump writes an archive of the indexes to the file
+func dump(file string, indexes []string) error {
+	var w io.Writer = os.Stdout
+	if file != "-" {
+		fp, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		w = fp
+	}
+
+	err := commands.DBDump(w, indexes...)
+	if err != nil {
+		return err
+	}
+	if file != "-" {
+		log.Println("Dumped the db to", file)
+	}
+	return nil
+}
+
+// restore replaces the indexes with what's in the archive in the file
+func restore(file string, indexes []string) error {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		fp, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		r = fp
+	}
+
+	n, err := commands.DBRestore(r, indexes...)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored %d things from %s", n, file)
+	return nil
+}
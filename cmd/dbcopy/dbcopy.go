@@ -0,0 +1,52 @@
+// This package copies everything in a db from one store to another, e.g. to move the bot to sqlite.
+//
+// Keys already in the destination are overwritten. Stop the bot first so nothing changes while copying.
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+var (
+	fromStore string // store to copy from
+	fromPath  string // path to the db to copy from
+	toStore   string // store to copy to
+	toPath    string // path to the db to copy to
+)
+
+func init() {
+	stores := strings.Join(commands.Stores(), ", ")
+	flag.StringVar(&fromStore, "from", commands.DefaultStore, "Store to copy from, one of "+stores)
+	flag.StringVar(&fromPath, "from-db", "bot.db", "Path to the db to copy from")
+	flag.StringVar(&toStore, "to", "sqlite", "Store to copy to, one of "+stores)
+	flag.StringVar(&toPath, "to-db", "bot.sqlite", "Path to the db to copy to")
+	flag.Parse()
+}
+
+func main() {
+	if fromStore == toStore && fromPath == toPath {
+		log.Fatalln("Not copying a db into itself")
+	}
+
+	from, err := commands.OpenStore(fromStore, fromPath)
+	if err != nil {
+		log.Fatalln(fromStore+":", err)
+	}
+	defer from.Close()
+
+	to, err := commands.OpenStore(toStore, toPath)
+	if err != nil {
+		log.Fatalln(toStore+":", err)
+	}
+	defer to.Close()
+
+	n, err := commands.CopyStore(to, from)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Copied %d keys from %s %s to %s %s", n, fromStore, fromPath, toStore, toPath)
+}
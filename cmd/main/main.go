@@ -10,23 +10,22 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/unswpcsoc/pcsocgo/commands"
 	"github.com/unswpcsoc/pcsocgo/handlers"
-	"github.com/unswpcsoc/pcsocgo/internal/utils"
+	"github.com/unswpcsoc/pcsocgo/internal/config"
 )
 
 var (
-	prod bool // production mode i.e. db saves to file rather than memory
-	sync bool // sync mode - will handle events syncronously if set, might break things if you do this
-
-	lastCom = make(map[string]commands.Command) // map of uid->command for most recently used command
+	prod bool   // production mode i.e. db saves to file rather than memory
+	sync bool   // sync mode - will handle events syncronously if set, might break things if you do this
+	conf string // path to the config file
 
 	dgo *discordgo.Session
+	ses commands.Session
 
 	errs = log.New(os.Stderr, "Error: ", log.Ltime) // logger for errors
 )
@@ -35,6 +34,7 @@ var (
 func init() {
 	flag.BoolVar(&prod, "prod", false, "Enables production mode")
 	flag.BoolVar(&sync, "sync", false, "Enables synchronous event handling")
+	flag.StringVar(&conf, "config", "config.json", "Path to the config file, reloaded on SIGHUP")
 	flag.Parse()
 }
 
@@ -65,164 +65,126 @@ func main() {
 	}
 
 	dgo.SyncEvents = sync
+	ses = commands.NewSession(dgo)
 
 	log.Printf("Logged in as: %v\nSyncEvents is %v", dgo.State.User.ID, dgo.SyncEvents)
 	defer dgo.Close()
 
-	// db init
+	// db init in the config file's store, migrating it if it's old
+	fcfg, err := config.Load(conf)
+	if err != nil {
+		errs.Fatalln("Bad config:", err)
+	}
+	commands.MigrateLogf = log.Printf
 	if prod {
-		err = commands.DBOpen("./bot.db")
+		err = commands.DBOpenStore(fcfg.Store, fcfg.StorePath)
 	} else {
-		err = commands.DBOpen(":memory:")
+		err = commands.DBOpenStore(fcfg.Store, ":memory:")
 	}
 	if err != nil {
 		errs.Fatalln(err)
 	}
+	log.Println("Opened the db in store:", fcfg.Store)
 	defer commands.DBClose()
 
-	dgo.UpdateStatus(0, commands.Prefix+handlers.HelpAlias)
+	// config init, needs the db for overrides
+	cfg, err := handlers.LoadConfig(conf)
+	if err != nil {
+		errs.Fatalln("Bad config:", err)
+	}
+	handlers.ApplyConfig(cfg)
+
+	ses.UpdateStatus(0, commands.DefaultPrefix()+handlers.HelpAlias)
 
 	// init loggers
-	handlers.InitLogs(dgo)
+	handlers.InitLogs(ses)
 
 	// init daemons
 	var closeDaemons func()
-	closeDaemons = handlers.InitDaemons(dgo)
+	closeDaemons = handlers.InitDaemons(ses)
 	defer closeDaemons()
 
 	// init guild cache
-	err = commands.InitGuilds(dgo)
+	err = commands.InitGuilds(ses)
 	if err != nil {
 		errs.Fatalln(err)
 	}
-	log.Println("Operating on guild:", commands.Guild)
-
-	// handle create message event
-	dgo.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
-		handleMessageEvent(s, m.Message)
-	})
-
-	// handle update message event
-	dgo.AddHandler(func(s *discordgo.Session, m *discordgo.MessageUpdate) {
-		handleMessageEvent(s, m.Message)
-	})
-
-	// keep alive
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
-	sig := <-sc
+	for _, guild := range commands.Guilds() {
+		log.Println("Operating on guild:", guild.Name, guild.ID)
+	}
 
-	log.Println("Received Signal: " + sig.String())
-	log.Println("Bye!")
-}
+	// move data from when the bot only had one guild into that guild
+	if gid := cfg.LegacyGuildID(); len(gid) > 0 {
+		err = handlers.MigrateGuildKeys(gid)
+		if err != nil {
+			errs.Fatalln(err)
+		}
+	} else {
+		log.Println("Not moving data from before there were guilds, set legacy_guild in the config to pick the guild it goes to")
+	}
 
-func handleMessageEvent(s *discordgo.Session, m *discordgo.Message) {
-	// catch panics on production
+	// command dispatch
+	chain := commands.DefaultMiddleware(handlers.RouterRoute, handlers.GuildRoute, handlers.Suggest, commands.NewHistory(), commands.NewCooldowns(true), log.Printf, errs.Printf)
 	if prod {
-		defer func() {
-			if r := recover(); r != nil {
-				errs.Printf("Caught panic: %#v\n", r)
-			}
-		}()
+		// catch panics on production
+		chain = append([]commands.Middleware{commands.Recover(errs.Printf)}, chain...)
 	}
+	dispatcher := commands.NewDispatcher(chain...)
 
-	if m.Author == nil || m.Author.ID == s.State.User.ID || m.Author.Bot {
-		return
-	}
+	// handle create message event
+	ses.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		dispatcher.Dispatch(ses, m.Message)
+	})
 
-	trm := strings.TrimSpace(m.Content)
-	if !strings.HasPrefix(trm, commands.Prefix) || len(trm) == 1 {
-		return
-	}
+	// handle update message event
+	ses.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageUpdate) {
+		dispatcher.Dispatch(ses, m.Message)
+	})
 
-	// route message
-	var com commands.Command
-	var ind int
-	var ok bool
-	argv := strings.Split(trm[1:], " ")
-	if argv[0] == "!" {
-		com, ok = lastCom[m.Author.ID]
-		if !ok {
-			return
-		}
-		// !! args...
-		ind = 1
-	} else {
-		// regular routing
-		com, ind = handlers.RouterRoute(argv)
-		if com == nil {
-			return
+	// register slash commands, prefix commands still work if this fails
+	slash := commands.SlashCommands(handlers.RouterToSlice())
+	for _, guild := range commands.Guilds() {
+		err = commands.RegisterSlashCommands(ses, ses.State().User.ID, guild.ID, slash)
+		if err != nil {
+			errs.Println("Couldn't register slash commands in guild", guild.ID+":", err)
 		}
 	}
 
-	// check chans
-	chans := com.Chans()
-	has, err := utils.MsgInChannels(s, m, chans)
+	// custom commands are per guild so they aren't slash commands
+	err = handlers.InitCustomCommands()
 	if err != nil {
-		errs.Printf("Channel checking threw: %#v\n", err)
+		errs.Println("Couldn't route all custom commands:", err)
 	}
-	if !has {
-		out := "Error: You must be in " + utils.Code(chans[0])
-		if len(chans) > 1 {
-			others := chans[1:]
-			for _, oth := range others {
-				out += " or " + utils.Code(oth)
-			}
+
+	// handle slash commands, discordgo doesn't have an event for these yet
+	ses.AddHandler(func(_ *discordgo.Session, e *discordgo.Event) {
+		in, ok := commands.ParseInteraction(e)
+		if ok {
+			dispatcher.DispatchInteraction(ses, in)
 		}
-		out += " to use this command"
-		s.ChannelMessageSend(m.ChannelID, utils.Italics(out))
-		return
-	}
+	})
 
-	// check roles
-	roles := com.Roles()
-	has, err = utils.MsgHasRoles(s, m, roles)
-	if err != nil {
-		errs.Printf("Role checking threw: %#v\n", err)
-	}
-	if !has {
-		out := "Error: You must be a " + utils.Code(roles[0])
-		if len(roles) > 1 {
-			others := roles[1:]
-			for _, oth := range others {
-				out += " or a " + utils.Code(oth)
+	// reload config on SIGHUP, keeping the old one if the new one is bad
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			cfg, err := handlers.LoadConfig(conf)
+			if err != nil {
+				errs.Println("Not reloading bad config:", err)
+				continue
 			}
+			handlers.ApplyConfig(cfg)
+			ses.UpdateStatus(0, commands.DefaultPrefix()+handlers.HelpAlias)
+			log.Println("Reloaded config from", conf)
 		}
-		out += " to use this command"
-		s.ChannelMessageSend(m.ChannelID, utils.Italics(out))
-		return
-	}
-
-	// successfully routed, register in !! before usage check
-	lastCom[m.Author.ID] = com
+	}()
 
-	// fill args and check usage
-	err = commands.FillArgs(com, argv[ind:])
-	if err != nil {
-		usage := "Usage: " + commands.GetUsage(com)
-		s.ChannelMessageSend(m.ChannelID, usage)
-		errs.Printf("Usage error on command %#v: %#v\n", com, err)
-		return
-	}
-
-	// handle message
-	log.Printf("Calling command handler: %s%s %+v", commands.Prefix, com.Aliases()[0], com)
-	s.ChannelTyping(m.ChannelID)
-	snd, err := com.MsgHandle(s, m)
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, utils.Italics("Error: "+err.Error()))
-		errs.Printf("%#v threw error: %#v\n", com, err)
-		return
-	}
-
-	// send returned message
-	if snd != nil {
-		err = snd.Send(s)
-		if err != nil {
-			errs.Printf("Send error: %#v\n", err)
-		}
-	}
+	// keep alive
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
+	sig := <-sc
 
-	// clean up args
-	commands.CleanArgs(com)
+	log.Println("Received Signal: " + sig.String())
+	log.Println("Bye!")
 }
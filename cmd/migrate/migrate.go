@@ -1,110 +1,106 @@
+// This package migrates a db to the version the bot wants, like the bot does when it starts.
+//
+// Use -dry to see what would be migrated without changing anything.
+// Use -import-tags with -guild to add the tags from the bot's old sqlite db to a guild afterwards.
 package main
 
 import (
 	"database/sql"
-	//"encoding/json"
-	"fmt"
+	"flag"
 	"log"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/handlers" // adds the migrations
 )
 
-// use tag implementation
-type tag struct {
-	UID      string
-	Username string // don't trust this, always fetch from the UID
-	Tag      string
-	Platform string
-	PingMe   bool
-}
-
-type platform struct {
-	Name  string
-	Role  interface{}
-	Users map[string]*tag // indexed by user id's
-}
+var (
+	store    string // store the db is in
+	path     string // path to the db
+	dry      bool   // dry run, roll back everything
+	tagsFrom string // path to the old sqlite db to import tags from
+	guild    string // guild the imported tags go in
+)
 
-// TODO: default games and api integrations
-type tagStorer struct {
-	Platforms map[string]*platform
+func init() {
+	flag.StringVar(&store, "store", commands.DefaultStore, "Store the db is in, one of "+strings.Join(commands.Stores(), ", "))
+	flag.StringVar(&path, "db", "bot.db", "Path to the db to migrate")
+	flag.BoolVar(&dry, "dry", false, "Only show what would be migrated")
+	flag.StringVar(&tagsFrom, "import-tags", "", "Path to the old sqlite db to import the tag table from, e.g. db.sqlite3")
+	flag.StringVar(&guild, "guild", "", "Guild id the imported tags go in")
+	flag.Parse()
 }
 
-func (t *tagStorer) Index() string { return "tags" }
-
 func main() {
-	var err error
+	commands.MigrateLogf = log.Printf
+	commands.DBDryRun = dry
 
-	// open db to migrate
-	db, err := sql.Open("sqlite3", "db.sqlite3")
+	err := commands.DBOpenStore(store, path)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	defer db.Close()
-
-	// open db to create
-	err = commands.DBOpen("bot.db")
 	defer commands.DBClose()
 
-	fmt.Println("Opened both dbs...")
-
-	rows, err := db.Query("select * from tag")
+	ver, err := commands.DBVersion()
 	if err != nil {
 		log.Fatalln(err)
 	}
-	defer rows.Close()
 
-	/*
-		cols, _ := rows.Columns()
-		for _, str := range cols {
-			fmt.Println(str)
-		}
-	*/
+	migs := commands.Migrations()
+	latest := 0
+	if len(migs) > 0 {
+		latest = migs[len(migs)-1].Version
+	}
+	log.Printf("The db is at version %d of %d", ver, latest)
+
+	if len(tagsFrom) == 0 {
+		return
+	}
+	if dry {
+		log.Println("Not importing tags on a dry run")
+		return
+	}
+	if len(guild) == 0 {
+		log.Fatalln("Give the -guild to import the tags into")
+	}
+	n, err := importTags(tagsFrom, guild)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Imported %d tags from %s, they don't want pings until cmd/pingme is run", n, tagsFrom)
+}
 
-	// create tagStorer
-	tgs := &tagStorer{
-		Platforms: make(map[string]*platform),
+// importTags adds the tags in the old sqlite db's tag table to the guild
+func importTags(path string, guildID string) (int, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
 	}
+	defer db.Close()
 
-	fmt.Println("Iterating rows...")
+	rows, err := db.Query("select * from tag")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
 
+	// indexed by platform then user id
+	tgs := make(map[string]map[string]string)
 	for rows.Next() {
-		var uid, plt, tagg string
-		rows.Scan(&uid, &plt, &tagg)
-
-		// check if platform already exists
-		plat, ok := tgs.Platforms[plt]
-		if !ok {
-			// new platform, make it and add it to the db
-			plat = &platform{
-				Name:  plt,
-				Role:  nil,
-				Users: make(map[string]*tag),
-			}
-			tgs.Platforms[plt] = plat
+		var uid, plt, tag string
+		if err = rows.Scan(&uid, &plt, &tag); err != nil {
+			return 0, err
 		}
-
-		// create tag and enter it into the platform
-		plat.Users[uid] = &tag{
-			UID:      uid,
-			Username: "",
-			Tag:      tagg,
-			Platform: plt,
-			PingMe:   false,
+		if tgs[plt] == nil {
+			tgs[plt] = make(map[string]string)
 		}
+		tgs[plt][uid] = tag
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
 	}
 
-	/*
-		b, err := json.MarshalIndent(tgs, "", "    ")
-		if err != nil {
-			log.Fatalln(err)
-		}
-		fmt.Println(string(b))
-	*/
-
-	// commit to the db
-	commands.DBSet(tgs, "fulltags")
-
-	fmt.Println("Committed changes")
+	return handlers.ImportTags(guildID, tgs)
 }
@@ -1,73 +1,44 @@
+// This package turns on PingMe for every tag in a guild, e.g. after importing them with cmd/migrate.
+//
+// Tags that turned pings off get them back too, so only run it on tags that never had the choice.
 package main
 
 import (
+	"flag"
 	"log"
+	"strings"
 
 	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/handlers"
 )
 
-const (
-	tagsKey = "fulltags"
+var (
+	store string // store the db is in
+	path  string // path to the db
+	guild string // guild whose tags get pings
 )
 
-// use tag implementation
-type tag struct {
-	UID      string
-	Username string // don't trust this, always fetch from the UID
-	Tag      string
-	Platform string
-	PingMe   bool
+func init() {
+	flag.StringVar(&store, "store", commands.DefaultStore, "Store the db is in, one of "+strings.Join(commands.Stores(), ", "))
+	flag.StringVar(&path, "db", "bot.db", "Path to the db")
+	flag.StringVar(&guild, "guild", "", "Guild id whose tags get pings")
+	flag.Parse()
 }
 
-type platform struct {
-	Name  string
-	Role  interface{}
-	Users map[string]*tag // indexed by user id's
-}
-
-// TODO: default games and api integrations
-type tagStorer struct {
-	Platforms map[string]*platform
-}
-
-func (t *tagStorer) Index() string { return "tags" }
-
 func main() {
-	var err error
-	var tgs tagStorer
+	if len(guild) == 0 {
+		log.Fatalln("Give the -guild whose tags get pings")
+	}
 
-	err = commands.DBOpen("bot.db")
+	err := commands.DBOpenStore(store, path)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer commands.DBClose()
 
-	// open tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
+	n, err := handlers.PingAllTags(guild)
 	if err != nil {
 		log.Fatalln(err)
 	}
-
-	log.Println("Opened tags")
-
-	// iterate tags
-	for _, plat := range tgs.Platforms {
-		log.Println("On Platform", plat)
-		for _, tag := range plat.Users {
-			tag.PingMe = true
-		}
-	}
-
-	// confirm change
-	for _, plat := range tgs.Platforms {
-		for _, tag := range plat.Users {
-			if !tag.PingMe {
-				log.Fatalln("Change not successful")
-			}
-		}
-	}
-
-	// commit to the db
-	commands.DBSet(&tgs, tagsKey)
-	log.Println("Committed changes")
+	log.Printf("Turned on pings for %d tags", n)
 }
@@ -0,0 +1,393 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+const (
+	// memberPageLimit is the most members discord will return per request
+	memberPageLimit = 1000
+)
+
+var (
+	// ErrArgNotFound means the user, channel or role named in an arg doesn't exist
+	ErrArgNotFound = errors.New("not found")
+	// ErrArgNoSession means FillArgs was asked to resolve a discord type without a session
+	ErrArgNoSession = errors.New("cannot resolve discord args without a session and message")
+	// ErrFlagUnknown means the user gave a --flag the command doesn't have
+	ErrFlagUnknown = errors.New("command has no such flag")
+	// ErrFlagValue means the user gave a non-bool flag without a value
+	ErrFlagValue = errors.New("flag needs a value")
+
+	// DateLayouts are the layouts tried for date args without a format tag
+	DateLayouts = []string{"2006-01-02", "2/Jan/2006", "2/Jan", "2/1/2006", "2/1"}
+
+	userType     = reflect.TypeOf(&discordgo.User{})
+	memberType   = reflect.TypeOf(&discordgo.Member{})
+	channelType  = reflect.TypeOf(&discordgo.Channel{})
+	roleType     = reflect.TypeOf(&discordgo.Role{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	dateType     = reflect.TypeOf(time.Time{})
+
+	snowflakeRegex      = regexp.MustCompile(`^[0-9]+$`)
+	userMentionRegex    = regexp.MustCompile(`^<@!?([0-9]+)>$`)
+	channelMentionRegex = regexp.MustCompile(`^<#([0-9]+)>$`)
+	roleMentionRegex    = regexp.MustCompile(`^<@&([0-9]+)>$`)
+)
+
+// ArgError means the user gave something that couldn't be put into an arg field
+type ArgError struct {
+	Arg   string // name of the arg from its tag
+	Type  string // human-readable type of the arg
+	Value string // what the user gave
+	Err   error  // why it failed
+}
+
+func (e *ArgError) Error() string {
+	if len(e.Value) == 0 {
+		return fmt.Sprintf("%s: %v", utils.Under(e.Arg), e.Err)
+	}
+	return fmt.Sprintf("%s is not a valid %s for %s: %v", utils.Code(e.Value), e.Type, utils.Under(e.Arg), e.Err)
+}
+
+// argName returns the name of an arg field, or the --name of a flag field
+func argName(f reflect.StructField) string {
+	if name, ok := f.Tag.Lookup("flag"); ok {
+		return "--" + name
+	}
+	return f.Tag.Get("arg")
+}
+
+// argRest returns whether an arg field takes the rest of the message as typed
+func argRest(f reflect.StructField) bool {
+	rest, _ := strconv.ParseBool(f.Tag.Get("rest"))
+	return rest
+}
+
+// argTypeName returns the human-readable name of an arg field's type
+//
+// Elements of slices are named with plural set
+func argTypeName(f reflect.StructField, t reflect.Type, plural bool) string {
+	if argRest(f) {
+		return "text"
+	}
+	if enum, ok := f.Tag.Lookup("enum"); ok {
+		return strings.Join(strings.Split(enum, ","), "/")
+	}
+
+	var name string
+	switch t {
+	case userType:
+		name = "user"
+	case memberType:
+		name = "member"
+	case channelType:
+		name = "channel"
+	case roleType:
+		name = "role"
+	case durationType:
+		name = "duration"
+	case dateType:
+		name = "date"
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			// doesn't pluralise
+			return "true/false"
+		case reflect.Int:
+			name = "number"
+		case reflect.Float64:
+			name = "decimal"
+		case reflect.String:
+			name = "word"
+		default:
+			return t.Name()
+		}
+	}
+
+	if plural {
+		name += "s"
+	}
+	return name
+}
+
+// argOptional returns whether an arg field can be left out
+func argOptional(f reflect.StructField) bool {
+	if _, ok := f.Tag.Lookup("default"); ok {
+		return true
+	}
+	opt, _ := strconv.ParseBool(f.Tag.Get("optional"))
+	return opt
+}
+
+// TakesArgs returns whether the command has any arg fields
+func TakesArgs(c Command) bool {
+	t := reflect.TypeOf(c)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("arg"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// argDefault returns the value of an arg field's default tag, or the zero value if it has none
+//
+// Panics if the default can't be parsed, discord types can't have defaults
+func argDefault(f reflect.StructField) reflect.Value {
+	def, ok := f.Tag.Lookup("default")
+	if !ok {
+		return reflect.Zero(f.Type)
+	}
+
+	got, err := parseArg(nil, nil, f, f.Type, def)
+	if err != nil {
+		panic("argDefault: bad default for arg " + argName(f) + ": " + err.Error())
+	}
+	return got
+}
+
+// parseArg converts a single user-given arg into a value of type t for the field f
+//
+// Panics if the type can't be handled
+func parseArg(ses Session, msg *discordgo.Message, f reflect.StructField, t reflect.Type, arg string) (reflect.Value, error) {
+	var got interface{}
+	var err error
+
+	switch t {
+	case userType:
+		got, err = resolveUser(ses, msg, arg)
+	case memberType:
+		got, err = resolveMember(ses, msg, arg)
+	case channelType:
+		got, err = ResolveChannel(ses, msg, arg)
+	case roleType:
+		got, err = resolveRole(ses, msg, arg)
+	case durationType:
+		got, err = time.ParseDuration(arg)
+	case dateType:
+		got, err = parseDate(f.Tag.Get("format"), arg)
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			got, err = parseEnum(f.Tag.Get("enum"), arg)
+		case reflect.Int:
+			got, err = strconv.Atoi(arg)
+		case reflect.Float64:
+			got, err = strconv.ParseFloat(arg, 64)
+		case reflect.Bool:
+			got, err = strconv.ParseBool(arg)
+		default:
+			panic("FillArgs: arg field cannot handle type " + t.String())
+		}
+	}
+
+	if err != nil {
+		return reflect.Value{}, &ArgError{
+			Arg:   argName(f),
+			Type:  argTypeName(f, t, false),
+			Value: arg,
+			Err:   err,
+		}
+	}
+	return reflect.ValueOf(got).Convert(t), nil
+}
+
+// parseEnum checks that arg is one of the comma-separated values in enum, ignoring case
+//
+// Any arg is accepted for an empty enum
+func parseEnum(enum string, arg string) (string, error) {
+	if len(enum) == 0 {
+		return arg, nil
+	}
+	for _, val := range strings.Split(enum, ",") {
+		if strings.EqualFold(val, arg) {
+			return val, nil
+		}
+	}
+	return "", errors.New("must be one of " + strings.Join(strings.Split(enum, ","), ", "))
+}
+
+// parseDate parses arg with the given layout, or with DateLayouts if layout is empty
+func parseDate(layout string, arg string) (time.Time, error) {
+	layouts := DateLayouts
+	if len(layout) > 0 {
+		layouts = []string{layout}
+	}
+
+	var err error
+	for _, lay := range layouts {
+		var got time.Time
+		got, err = time.Parse(lay, arg)
+		if err == nil {
+			return got, nil
+		}
+	}
+	return time.Time{}, errors.New("use the format " + strings.Join(layouts, " or "))
+}
+
+// resolveMember finds a guild member from a mention, id, username or nickname
+func resolveMember(ses Session, msg *discordgo.Message, arg string) (*discordgo.Member, error) {
+	if ses == nil || msg == nil {
+		return nil, ErrArgNoSession
+	}
+
+	id := arg
+	if mat := userMentionRegex.FindStringSubmatch(arg); mat != nil {
+		id = mat[1]
+	}
+
+	if snowflakeRegex.MatchString(id) {
+		mem, err := ses.State().Member(msg.GuildID, id)
+		if err != nil {
+			mem, err = ses.GuildMember(msg.GuildID, id)
+		}
+		if err == nil {
+			return mem, nil
+		}
+	}
+
+	// search members by name, case-insensitive, in the state before asking for every member
+	name := strings.TrimPrefix(arg, "@")
+	if mem := stateMemberNamed(ses.State(), msg.GuildID, name); mem != nil {
+		return mem, nil
+	}
+
+	after := "0"
+	for {
+		members, err := ses.GuildMembers(msg.GuildID, after, memberPageLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mem := range members {
+			if strings.EqualFold(mem.User.Username, name) || strings.EqualFold(mem.Nick, name) {
+				return mem, nil
+			}
+		}
+
+		if len(members) < memberPageLimit {
+			break
+		}
+		after = members[len(members)-1].User.ID
+	}
+
+	return nil, ErrArgNotFound
+}
+
+// stateMemberNamed returns the member of the guild in the state with the username or nickname, nil if there isn't one
+func stateMemberNamed(st *discordgo.State, guildID string, name string) *discordgo.Member {
+	gui, err := st.Guild(guildID)
+	if err != nil {
+		return nil
+	}
+
+	st.RLock()
+	defer st.RUnlock()
+	for _, mem := range gui.Members {
+		if mem.User != nil && (strings.EqualFold(mem.User.Username, name) || strings.EqualFold(mem.Nick, name)) {
+			return mem
+		}
+	}
+	return nil
+}
+
+// resolveUser finds a user from a mention, id, username or nickname
+func resolveUser(ses Session, msg *discordgo.Message, arg string) (*discordgo.User, error) {
+	mem, err := resolveMember(ses, msg, arg)
+	if err == nil {
+		return mem.User, nil
+	}
+	if err != ErrArgNotFound {
+		return nil, err
+	}
+
+	// might not be in the guild
+	id := arg
+	if mat := userMentionRegex.FindStringSubmatch(arg); mat != nil {
+		id = mat[1]
+	}
+	if snowflakeRegex.MatchString(id) {
+		if usr, err := ses.User(id); err == nil {
+			return usr, nil
+		}
+	}
+	return nil, ErrArgNotFound
+}
+
+// ResolveChannel finds a channel in the message's guild from a mention, id or name, as channel args are
+func ResolveChannel(ses Session, msg *discordgo.Message, arg string) (*discordgo.Channel, error) {
+	if ses == nil || msg == nil {
+		return nil, ErrArgNoSession
+	}
+
+	id := arg
+	if mat := channelMentionRegex.FindStringSubmatch(arg); mat != nil {
+		id = mat[1]
+	}
+
+	if snowflakeRegex.MatchString(id) {
+		cha, err := ses.State().Channel(id)
+		if err != nil {
+			cha, err = ses.Channel(id)
+		}
+		// channels in other guilds the bot is in aren't this guild's to use
+		if err == nil && cha.GuildID == msg.GuildID {
+			return cha, nil
+		}
+	}
+
+	channels, err := ses.GuildChannels(msg.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimPrefix(arg, "#")
+	for _, cha := range channels {
+		if strings.EqualFold(cha.Name, name) {
+			return cha, nil
+		}
+	}
+	return nil, ErrArgNotFound
+}
+
+// resolveRole finds a role from a mention, id or name
+func resolveRole(ses Session, msg *discordgo.Message, arg string) (*discordgo.Role, error) {
+	if ses == nil || msg == nil {
+		return nil, ErrArgNoSession
+	}
+
+	id := arg
+	if mat := roleMentionRegex.FindStringSubmatch(arg); mat != nil {
+		id = mat[1]
+	}
+
+	roles, err := ses.GuildRoles(msg.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimPrefix(arg, "@")
+	for _, rol := range roles {
+		if rol.ID == id || strings.EqualFold(rol.Name, name) {
+			return rol, nil
+		}
+	}
+	return nil, ErrArgNotFound
+}
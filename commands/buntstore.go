@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/tidwall/buntdb"
+)
+
+// buntStore implements the IndexStore interface with a buntdb file
+type buntStore struct {
+	db *buntdb.DB
+}
+
+func openBuntStore(path string) (Store, error) {
+	db, err := buntdb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db.Shrink()
+	return &buntStore{db}, nil
+}
+
+func (b *buntStore) View(view func(tx StoreTx) error) error {
+	return b.db.View(func(tx *buntdb.Tx) error {
+		return view(&buntTx{tx})
+	})
+}
+
+func (b *buntStore) Update(update func(tx StoreTx) error) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		return update(&buntTx{tx})
+	})
+}
+
+func (b *buntStore) Close() error { return b.db.Close() }
+
+func (b *buntStore) CreateIndex(name string, prefix string, field string) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		err := tx.CreateIndex(name, prefix+"*", buntdb.IndexJSONCaseSensitive(field))
+		if err == buntdb.ErrIndexExists {
+			return nil
+		}
+		return err
+	})
+}
+
+// buntTx implements the IndexStoreTx interface
+type buntTx struct {
+	tx *buntdb.Tx
+}
+
+func (b *buntTx) Get(key string) (string, error) { return b.tx.Get(key) }
+
+func (b *buntTx) Set(key string, val string, ttl time.Duration) error {
+	var opts *buntdb.SetOptions
+	if ttl > 0 {
+		opts = &buntdb.SetOptions{Expires: true, TTL: ttl}
+	}
+	_, _, err := b.tx.Set(key, val, opts)
+	return err
+}
+
+func (b *buntTx) Delete(key string) error {
+	_, err := b.tx.Delete(key)
+	return err
+}
+
+func (b *buntTx) TTL(key string) (time.Duration, error) {
+	ttl, err := b.tx.TTL(key)
+	if ttl < 0 {
+		// never expires
+		ttl = 0
+	}
+	return ttl, err
+}
+
+func (b *buntTx) AscendPrefix(prefix string, iter func(key, val string) bool) error {
+	// keys are ordered, so everything with the prefix comes right after it
+	return b.tx.AscendGreaterOrEqual("", prefix, func(key, val string) bool {
+		if !strings.HasPrefix(key, prefix) {
+			return false
+		}
+		// expired items are only removed every second
+		if _, err := b.tx.TTL(key); err == buntdb.ErrNotFound {
+			return true
+		}
+		return iter(key, val)
+	})
+}
+
+func (b *buntTx) AscendIndex(name string, field string, value string, iter func(key, val string) bool) error {
+	pivot, err := json.Marshal(map[string]string{field: value})
+	if err != nil {
+		return err
+	}
+	return b.tx.AscendEqual(name, string(pivot), iter)
+}
@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrDBNoField means the Collection has no index on the field
+	ErrDBNoField = errors.New("collection has no index on that field")
+
+	collections     []*Collection
+	collectionsLock = &sync.Mutex{}
+
+	keyEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+)
+
+/* collections */
+
+// Collection keeps many Storers of one kind each at their own key, instead of all of them in one big Storer
+//
+// A record at key is stored at its Index() + ":" + key like any other Storer,
+// so DBGet, DBSet and DBUpdate work on single records too.
+// Build keys with RecordKey so parts can't run into each other.
+type Collection struct {
+	index  string
+	new    func() Storer
+	fields []string
+}
+
+// NewCollection makes a Collection of what new makes, with a secondary index on each of the JSON fields
+//
+// Indexes are made when the db is opened, or straight away if it already is.
+func NewCollection(new func() Storer, fields ...string) *Collection {
+	c := &Collection{
+		index:  new().Index(),
+		new:    new,
+		fields: fields,
+	}
+
+	collectionsLock.Lock()
+	collections = append(collections, c)
+	collectionsLock.Unlock()
+
+	if DB != nil {
+		c.createIndexes()
+	}
+	return c
+}
+
+// RecordKey joins the parts into a record key, escaping colons in them
+//
+//	commands.RecordKey(msg.GuildID, platform, msg.Author.ID)
+func RecordKey(parts ...string) string {
+	esc := make([]string, len(parts))
+	for i, part := range parts {
+		esc[i] = keyEscaper.Replace(part)
+	}
+	return strings.Join(esc, ":")
+}
+
+// RecordPrefix is RecordKey for the start of keys, e.g. everything in a guild
+func RecordPrefix(parts ...string) string {
+	return RecordKey(parts...) + ":"
+}
+
+// indexName is the name of the Store's index on the field
+func (c *Collection) indexName(field string) string {
+	return c.index + "_" + field
+}
+
+// createIndexes makes the Collection's secondary indexes, if the Store has them and they aren't there already
+func (c *Collection) createIndexes() error {
+	ist, ok := DB.(IndexStore)
+	if !ok {
+		return nil
+	}
+	for _, field := range c.fields {
+		if err := ist.CreateIndex(c.indexName(field), c.index+":", field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCollectionIndexes makes the indexes of every Collection, for when the db is opened
+func createCollectionIndexes() error {
+	collectionsLock.Lock()
+	defer collectionsLock.Unlock()
+
+	for _, c := range collections {
+		if err := c.createIndexes(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update is DBUpdate on the record at the key, starting from an empty record if there isn't one
+func (c *Collection) Update(key string, update func(v Storer) error) error {
+	return DBUpdate(c.new(), key, update)
+}
+
+// Delete removes the record at the key, ErrDBNotFound if there isn't one
+func (c *Collection) Delete(key string) error {
+	return DBTransact(func(tx *DBTx) error {
+		return tx.Delete(c.new(), key)
+	})
+}
+
+// Ascend calls iter with each record whose key starts with prefix in key order, until iter returns false
+func (c *Collection) Ascend(prefix string, iter func(key string, v Storer) bool) error {
+	return DBView(func(tx *DBTx) error {
+		return tx.Ascend(c, prefix, iter)
+	})
+}
+
+// AscendField is Ascend for only the records whose indexed field is value
+func (c *Collection) AscendField(field string, value string, prefix string, iter func(key string, v Storer) bool) error {
+	return DBView(func(tx *DBTx) error {
+		return tx.AscendField(c, field, value, prefix, iter)
+	})
+}
+
+// DeletePrefix removes every record whose key starts with prefix and returns how many there were
+func (c *Collection) DeletePrefix(prefix string) (int, error) {
+	var n int
+	err := DBTransact(func(tx *DBTx) error {
+		var err error
+		n, err = tx.DeletePrefix(c, prefix)
+		return err
+	})
+	return n, err
+}
+
+/* transactions */
+
+// DBTx is a db transaction, for reading or changing several records at once
+//
+// Records can't be set or deleted while iterating, collect their keys first.
+type DBTx struct {
+	tx StoreTx
+}
+
+// DBView calls view with a read only transaction
+func DBView(view func(tx *DBTx) error) error {
+	if DB == nil {
+		return ErrDBNotOpen
+	}
+	return DB.View(func(tx StoreTx) error {
+		return view(&DBTx{tx})
+	})
+}
+
+// DBTransact calls update with a read-write transaction, nothing is changed if it returns an error
+func DBTransact(update func(tx *DBTx) error) error {
+	if DB == nil {
+		return ErrDBNotOpen
+	}
+	return DB.Update(func(tx StoreTx) error {
+		return update(&DBTx{tx})
+	})
+}
+
+// Get is DBGet in the transaction
+func (t *DBTx) Get(s Storer, key string, got Storer) error {
+	if s == nil || got == nil {
+		return ErrStorerNil
+	}
+	if reflect.TypeOf(got).Kind() != reflect.Ptr {
+		return ErrDBNotPtr
+	}
+
+	res, err := t.tx.Get(s.Index() + ":" + key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(res), got)
+}
+
+// Set is DBSet in the transaction
+func (t *DBTx) Set(s Storer, key string) error {
+	if s == nil {
+		return ErrStorerNil
+	}
+	if len(key) == 0 {
+		return ErrDBKeyEmpty
+	}
+
+	mar, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return t.tx.Set(s.Index()+":"+key, string(mar), 0)
+}
+
+// Delete removes the Storer at the key, ErrDBNotFound if there isn't one
+func (t *DBTx) Delete(s Storer, key string) error {
+	if s == nil {
+		return ErrStorerNil
+	}
+	return t.tx.Delete(s.Index() + ":" + key)
+}
+
+// Ascend is Collection.Ascend in the transaction
+func (t *DBTx) Ascend(c *Collection, prefix string, iter func(key string, v Storer) bool) error {
+	full := c.index + ":" + prefix
+
+	var err error
+	ierr := t.tx.AscendPrefix(full, func(key, val string) bool {
+		rec := c.new()
+		if err = json.Unmarshal([]byte(val), rec); err != nil {
+			return false
+		}
+		return iter(strings.TrimPrefix(key, c.index+":"), rec)
+	})
+	if ierr != nil {
+		return ierr
+	}
+	return err
+}
+
+// AscendField is Collection.AscendField in the transaction
+func (t *DBTx) AscendField(c *Collection, field string, value string, prefix string, iter func(key string, v Storer) bool) error {
+	found := false
+	for _, f := range c.fields {
+		found = found || f == field
+	}
+	if !found {
+		return ErrDBNoField
+	}
+
+	full := c.index + ":" + prefix
+	var err error
+	each := func(key, val string) bool {
+		if !strings.HasPrefix(key, full) {
+			return true
+		}
+		rec := c.new()
+		if err = json.Unmarshal([]byte(val), rec); err != nil {
+			return false
+		}
+		return iter(strings.TrimPrefix(key, c.index+":"), rec)
+	}
+
+	var ierr error
+	if itx, ok := t.tx.(IndexStoreTx); ok {
+		ierr = itx.AscendIndex(c.indexName(field), field, value, each)
+	} else {
+		// no index, look at every record with the prefix
+		ierr = t.tx.AscendPrefix(full, func(key, val string) bool {
+			var fields map[string]json.RawMessage
+			if err = json.Unmarshal([]byte(val), &fields); err != nil {
+				return false
+			}
+			var got string
+			if json.Unmarshal(fields[field], &got) != nil || got != value {
+				return true
+			}
+			return each(key, val)
+		})
+	}
+	if ierr != nil {
+		return ierr
+	}
+	return err
+}
+
+// DeletePrefix is Collection.DeletePrefix in the transaction
+func (t *DBTx) DeletePrefix(c *Collection, prefix string) (int, error) {
+	keys := []string{}
+	err := t.Ascend(c, prefix, func(key string, v Storer) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		if err = t.Delete(c.new(), key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+// Keys returns the keys of the Storers at the index whose key matches the pattern, where * matches anything
+func (t *DBTx) Keys(s Storer, pattern string) ([]string, error) {
+	if s == nil {
+		return nil, ErrStorerNil
+	}
+
+	// everything before the first * is a prefix
+	full := s.Index() + ":" + pattern
+	keys := []string{}
+	err := t.tx.AscendPrefix(strings.SplitN(full, "*", 2)[0], func(key, val string) bool {
+		if matchKey(full, key) {
+			keys = append(keys, key[len(s.Index())+1:])
+		}
+		return true
+	})
+	return keys, err
+}
+
+/* migration */
+
+// DBSplit replaces the Storer at key with the records split makes from it, all in one transaction
+//
+// split returns the records by their keys, each key under the record's own index.
+// Does nothing if there's nothing at key, and records that are already there are kept.
+func DBSplit(s Storer, key string, split func(v Storer) (map[string]Storer, error)) error {
+	return DBTransact(func(tx *DBTx) error {
+		return tx.Split(s, key, split)
+	})
+}
+
+// Split is DBSplit in the transaction
+func (t *DBTx) Split(s Storer, key string, split func(v Storer) (map[string]Storer, error)) error {
+	if s == nil || split == nil {
+		return ErrStorerNil
+	}
+	if reflect.TypeOf(s).Kind() != reflect.Ptr {
+		return ErrDBNotPtr
+	}
+
+	err := t.Get(s, key, s)
+	if err == ErrDBNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recs, err := split(s)
+	if err != nil {
+		return err
+	}
+	for rkey, rec := range recs {
+		_, err = t.tx.Get(rec.Index() + ":" + rkey)
+		if err == nil {
+			continue
+		}
+		if err != ErrDBNotFound {
+			return err
+		}
+		if err = t.Set(rec, rkey); err != nil {
+			return err
+		}
+	}
+	return t.Delete(s, key)
+}
@@ -0,0 +1,124 @@
+package commands_test
+
+import (
+	"testing"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// record is a thing kept in a Collection
+type record struct {
+	Owner string
+	Count int
+}
+
+func (r *record) Index() string { return "record" }
+
+var records = NewCollection(func() Storer { return &record{} }, "Owner")
+
+// TestRecordKey escapes parts so they can't be mistaken for more parts
+func TestRecordKey(t *testing.T) {
+	got := RecordKey("1", "a:b", "50%")
+	if exp := "1:a%3Ab:50%25"; got != exp {
+		t.Errorf("RecordKey gave %q; want %q", got, exp)
+	}
+	if got, exp := RecordPrefix("1", "a"), "1:a:"; got != exp {
+		t.Errorf("RecordPrefix gave %q; want %q", got, exp)
+	}
+}
+
+// TestCollection sets records in two guilds then finds them by prefix and by owner
+func TestCollection(t *testing.T) {
+	for _, key := range []string{RecordKey("1", "b"), RecordKey("1", "a"), RecordKey("10", "a"), RecordKey("2", "a")} {
+		err := records.Update(key, func(v Storer) error {
+			rec := v.(*record)
+			rec.Owner = "bob"
+			rec.Count++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "10:a" isn't in guild 1
+	keys := []string{}
+	err := records.Ascend(RecordPrefix("1"), func(key string, v Storer) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "1:a" || keys[1] != "1:b" {
+		t.Errorf("Ascend(1:) gave %v; want [1:a 1:b]", keys)
+	}
+
+	DBSet(&record{Owner: "alice", Count: 5}, RecordKey("1", "c"))
+	n := 0
+	err = records.AscendField("Owner", "bob", RecordPrefix("1"), func(key string, v Storer) bool {
+		n += v.(*record).Count
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("AscendField(Owner, bob) counted %d; want 2", n)
+	}
+
+	err = records.AscendField("Count", "1", "", func(key string, v Storer) bool { return true })
+	if err != ErrDBNoField {
+		t.Errorf("AscendField on an unindexed field gave %v; want %v", err, ErrDBNoField)
+	}
+
+	del, err := records.DeletePrefix(RecordPrefix("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if del != 3 {
+		t.Errorf("DeletePrefix(1:) deleted %d; want 3", del)
+	}
+	if err = records.Delete(RecordKey("1", "a")); err != ErrDBNotFound {
+		t.Errorf("Delete on a deleted record gave %v; want %v", err, ErrDBNotFound)
+	}
+}
+
+// TestDBSplit splits a map of things into records, keeping records already there
+func TestDBSplit(t *testing.T) {
+	DBSet(&record{Owner: "new", Count: 1}, RecordKey("3", "kept"))
+	_, _, err := DBSet(&thing{A: "blob"}, "split")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DBSplit(&thing{}, "split", func(v Storer) (map[string]Storer, error) {
+		return map[string]Storer{
+			RecordKey("3", v.(*thing).A): &record{Owner: "old", Count: 2},
+			RecordKey("3", "kept"):       &record{Owner: "old", Count: 3},
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got record
+	if err = DBGet(&got, RecordKey("3", "blob"), &got); err != nil || got.Count != 2 {
+		t.Errorf("split record is %v, %v; want count 2", got, err)
+	}
+	if err = DBGet(&got, RecordKey("3", "kept"), &got); err != nil || got.Owner != "new" {
+		t.Errorf("existing record is %v, %v; want owner new", got, err)
+	}
+	if err = DBGet(&thing{}, "split", &thing{}); err != ErrDBNotFound {
+		t.Errorf("split thing is still there, got %v", err)
+	}
+
+	// nothing to split
+	err = DBSplit(&thing{}, "split", func(v Storer) (map[string]Storer, error) {
+		t.Errorf("DBSplit called split with nothing at the key")
+		return nil, nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
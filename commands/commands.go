@@ -1,13 +1,13 @@
 // Package commands implements a command interface for pcsocgo
 // with helper structs and funcs for sending discordgo messages,
-// and high-level abstractions of buntdb
+// and high-level abstractions of the db
 package commands
 
 import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 
@@ -15,18 +15,25 @@ import (
 )
 
 const (
-	// Prefix is the prefix for commands
-	Prefix = "!"
 	// MessageLimit is the character limit for messages
 	MessageLimit = 2000
 )
 
 var (
+	// Prefix is the prefix for commands, set from the config
+	//
+	// Use SetPrefix and DefaultPrefix once commands are being dispatched.
+	Prefix = "!"
+
 	// ErrSendLimit means the message was too long
+	//
+	// Deprecated: Send splits long messages now
 	ErrSendLimit = errors.New("message exceeds send limit of 2000 characters")
 	// ErrNotEnoughArgs means the user did not provide enough arguments to the command
 	ErrNotEnoughArgs = errors.New("not enough arguments provided")
-	// Guild is the guild to use (this bot is designed for only one server)
+	// Guild is the first guild the bot is in
+	//
+	// Deprecated: the bot can be in more than one guild, use GetGuild or Guilds
 	Guild = &discordgo.UserGuild{}
 )
 
@@ -38,20 +45,21 @@ type Command interface {
 	Roles() []string        // Roles required
 	Chans() []string        // Channels required
 
-	MsgHandle(*discordgo.Session, *discordgo.Message) (*CommandSend, error) // Handler for MessageCreate event
+	MsgHandle(Session, *discordgo.Message) (*CommandSend, error) // Handler for MessageCreate event
 }
 
 // CommandSend is a helper struct that buffers things commands need to send.
 type CommandSend struct {
-	data      []*discordgo.MessageSend
-	channelid string
+	data       []*discordgo.MessageSend
+	channelid  string
+	components map[int]*ComponentSet // indexed by data index
 }
 
 // NewSend Returns a send struct.
 func NewSend(cid string) *CommandSend {
 	return &CommandSend{
-		[]*discordgo.MessageSend{},
-		cid,
+		data:      []*discordgo.MessageSend{},
+		channelid: cid,
 	}
 }
 
@@ -90,14 +98,68 @@ func (c *CommandSend) MessageSend(send *discordgo.MessageSend) *CommandSend {
 	return c
 }
 
-// Send Sends the messages a command returns while also checking message length
-func (c *CommandSend) Send(s *discordgo.Session) error {
-	// Get the stuff out of BeegYoshi and send it into the server
+// Components Attaches components to the last message.
+func (c *CommandSend) Components(set *ComponentSet) *CommandSend {
+	if len(c.data) == 0 {
+		c.data = append(c.data, &discordgo.MessageSend{})
+	}
+	if c.components == nil {
+		c.components = make(map[int]*ComponentSet)
+	}
+	c.components[len(c.data)-1] = set
+	return c
+}
+
+// Send Sends the messages a command returns
+//
+// Content over MessageLimit is split into several messages with SplitMessage,
+// and content over FileLimit is sent as a .txt attachment instead.
+// Embeds, files, components and the like go with the last message.
+// Embeds are checked with ValidateEmbed before anything is sent.
+func (c *CommandSend) Send(s Session) error {
 	for _, data := range c.data {
-		if utils.Strlen(data) > MessageLimit {
-			return fmt.Errorf("Send: following message exceeds limit\n%#v", data)
+		err := ValidateEmbed(data.Embed)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Get the stuff out of BeegYoshi and send it into the server
+	for i, data := range c.data {
+		if len(data.Content) > FileLimit {
+			file := &discordgo.File{
+				Name:        "message.txt",
+				ContentType: "text/plain",
+				Reader:      strings.NewReader(data.Content),
+			}
+			cpy := *data
+			cpy.Content = "That's a lot of text, so it's attached."
+			cpy.Files = append([]*discordgo.File{file}, cpy.Files...)
+			data = &cpy
+		}
+
+		chunks := SplitMessage(data.Content, MessageLimit)
+		for _, chunk := range chunks[:len(chunks)-1] {
+			_, err := s.ChannelMessageSendComplex(c.channelid, &discordgo.MessageSend{
+				Content: chunk,
+				TTS:     data.TTS,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		last := *data
+		last.Content = chunks[len(chunks)-1]
+		var err error
+		if set, ok := c.components[i]; ok {
+			_, err = SendComponents(s, c.channelid, &last, set)
+		} else {
+			_, err = s.ChannelMessageSendComplex(c.channelid, &last)
+		}
+		if err != nil {
+			return err
 		}
-		s.ChannelMessageSendComplex(c.channelid, data)
 	}
 	return nil
 }
@@ -105,10 +167,16 @@ func (c *CommandSend) Send(s *discordgo.Session) error {
 /* usage generation */
 
 // GetUsage generates the usage message from a Command in the following format
-//  !alias0 (type0) __arg0__ (type1) __arg1__ ...
+//  !alias0 (type0) __arg0__ (type1) [__optional1__] (type2) [__default2__=value] ...
 //  description of command
+//  __Flags__ | --flag0, -f (type0) | --flag1 (type1) ...
 //  __Aliases__ | !alias1 | !alias2 ...
-func GetUsage(c Command) (usage string) {
+func GetUsage(c Command) string {
+	return GetUsagePrefix(c, DefaultPrefix())
+}
+
+// GetUsagePrefix is GetUsage with the commands shown with prefix e.g. a guild's from GuildPrefix
+func GetUsagePrefix(c Command, prefix string) (usage string) {
 	v := reflect.ValueOf(c)
 
 	if v.Kind() == reflect.Ptr {
@@ -125,7 +193,7 @@ func GetUsage(c Command) (usage string) {
 
 	// command alias
 	names := c.Aliases()
-	usage = utils.Bold("!" + names[0])
+	usage = utils.Bold(prefix + names[0])
 
 	// parse struct fields with arg tags
 	for i := 0; i < v.NumField(); i++ {
@@ -136,41 +204,54 @@ func GetUsage(c Command) (usage string) {
 			continue
 		}
 
-		// kind switch to parse into human-readable
-		// names for types
+		// parse into human-readable names for types
 		var tName string
 		switch f.Type.Kind() {
-		case reflect.Bool:
-			tName = "true/false"
-		case reflect.Int:
-			tName = "number"
-		case reflect.String:
-			tName = "word"
 		case reflect.Array, reflect.Slice:
-			tName = "multiple "
-			switch f.Type.Elem().Kind() {
-			case reflect.Bool:
-				tName += "true/false"
-			case reflect.Int:
-				tName += "numbers"
-			case reflect.String:
-				tName += "words"
-			}
+			tName = "multiple " + argTypeName(f, f.Type.Elem(), true)
 		default:
-			tName = f.Type.Name()
+			tName = argTypeName(f, f.Type, false)
 		}
 
-		usage += " (" + tName + ") " + utils.Under(tag)
+		if argOptional(f) {
+			if def, ok := f.Tag.Lookup("default"); ok {
+				usage += " (" + tName + ") [" + utils.Under(tag) + "=" + def + "]"
+			} else {
+				usage += " (" + tName + ") [" + utils.Under(tag) + "]"
+			}
+		} else {
+			usage += " (" + tName + ") " + utils.Under(tag)
+		}
 	}
 
 	// description
 	usage += "\n" + c.Desc()
 
+	// flags
+	flags := ""
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+
+		name, ok := f.Tag.Lookup("flag")
+		if !ok {
+			continue
+		}
+
+		flags += " | --" + name
+		if short, ok := f.Tag.Lookup("short"); ok {
+			flags += ", -" + short
+		}
+		flags += " (" + argTypeName(f, f.Type, false) + ")"
+	}
+	if len(flags) > 0 {
+		usage += "\n" + utils.Under("Flags") + flags
+	}
+
 	// aliases
 	if len(names) > 1 {
 		usage += "\n" + utils.Under("Aliases")
 		for _, name := range names[1:] {
-			usage += " | !" + name
+			usage += " | " + prefix + name
 		}
 	}
 
@@ -178,7 +259,7 @@ func GetUsage(c Command) (usage string) {
 	if len(c.Subcommands()) > 0 {
 		usage += "\n" + utils.Under("Subcommands")
 		for _, sc := range c.Subcommands() {
-			usage += " | !" + sc.Aliases()[0]
+			usage += " | " + prefix + sc.Aliases()[0]
 		}
 	}
 
@@ -191,11 +272,42 @@ func GetUsage(c Command) (usage string) {
 
 // FillArgs tries to fill the given command's struct fields with the args given
 //
-// FillArgs will return a strconv error if types cannot be matched
+// The args are treated as if they were typed separated by single spaces,
+// use FillTokens to fill from a message split with Tokenize.
+func FillArgs(ses Session, msg *discordgo.Message, c Command, args []string) error {
+	return FillTokens(ses, msg, c, newTokens(args))
+}
+
+// FillTokens tries to fill the given command's struct fields with the tokenized args given
+//
+// Besides string, int, float64 and bool fields (and slices of them), FillTokens can fill
+//  - *discordgo.User, *discordgo.Member from a mention, id, username or nickname
+//  - *discordgo.Channel from a mention, id or name
+//  - *discordgo.Role from a mention, id or name
+//  - time.Duration in time.ParseDuration format e.g. 1h30m
+//  - time.Time from a date in the layout of the `format` tag, or one of DateLayouts
+//  - string fields with an `enum:"a,b,c"` tag, which only accept those values
+//  - string fields with a `rest:"true"` tag, which take the rest of the message as it was typed
+//
+// Discord types are resolved in the message's guild using the session.
+// The session and message can be nil if the command has no discord-typed args.
+//
+// Args can be made optional with an `optional:"true"` tag, or given a default with a
+// `default:"value"` tag. Optional args must come after all required args. Missing optional
+// args are set to their default (or zero value). If an optional arg can't be parsed and
+// there is a later arg field, the optional arg is skipped and the later field gets it instead,
+// so e.g. `!staticice [floor] query...` can be called with or without a floor.
+// Trailing slices are always optional.
+//
+// Fields with a `flag:"name"` tag (and optionally `short:"n"`) are filled from
+// --name=value, --name value, -n value or -n=value. Bool flags don't need a value.
+// Flags must come before the other args, and -- can be used to end them.
+// Flags are always optional, and also take `default` tags.
+//
+// FillTokens will return an *ArgError if an arg cannot be parsed
 // and will panic if there are unexported arg fields or if variable args are done incorrectly
 // or if input is generally messed up
-func FillArgs(c Command, args []string) error {
-	var err error
+func FillTokens(ses Session, msg *discordgo.Message, c Command, toks *Tokens) error {
 	var val reflect.Value
 	val = reflect.ValueOf(c)
 
@@ -211,62 +323,121 @@ func FillArgs(c Command, args []string) error {
 		panic(fmt.Sprintf("FillArgs: %#v is not a struct\n", val))
 	}
 
-	// get arg fields as slice of values
+	// get arg fields as slices of types and values, flag fields by name
+	argTypes := []reflect.StructField{}
 	argFields := []reflect.Value{}
+	flags := map[string]int{}
+	flagTypes := []reflect.StructField{}
+	flagFields := []reflect.Value{}
 	for i := 0; i < val.NumField(); i++ {
 		ft := val.Type().Field(i)
 		fv := val.Field(i)
-		_, ok := ft.Tag.Lookup("arg")
-		if !ok {
+		_, isArg := ft.Tag.Lookup("arg")
+		name, isFlag := ft.Tag.Lookup("flag")
+		if !isArg && !isFlag {
 			continue
 		}
 		if !fv.CanSet() {
 			panic("FillArgs: using unexported field with arg tag")
 		}
+		if isArg && isFlag {
+			panic("FillArgs: field " + ft.Name + " has both arg and flag tags")
+		}
+
+		if isFlag {
+			flags["--"+name] = len(flagFields)
+			if short, ok := ft.Tag.Lookup("short"); ok {
+				flags["-"+short] = len(flagFields)
+			}
+			flagTypes = append(flagTypes, ft)
+			flagFields = append(flagFields, fv)
+			fv.Set(argDefault(ft))
+			continue
+		}
+
+		argTypes = append(argTypes, ft)
 		argFields = append(argFields, fv)
 	}
 
+	// parse flags
+	i := 0
+	for ; len(flagFields) > 0 && i < len(toks.Args); i++ {
+		raw := toks.raw[i]
+		if raw == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(raw, "-") || len(raw) < 2 {
+			break
+		}
+
+		// split --name=value
+		name, value := toks.Args[i], ""
+		hasValue := false
+		if ind := strings.Index(name, "="); ind >= 0 {
+			name, value = name[:ind], name[ind+1:]
+			hasValue = true
+		}
+
+		ind, ok := flags[name]
+		if !ok {
+			if strings.HasPrefix(raw, "--") {
+				return &ArgError{Arg: name, Type: "flag", Value: toks.Args[i], Err: ErrFlagUnknown}
+			}
+			// probably a negative number
+			break
+		}
+
+		ft, fv := flagTypes[ind], flagFields[ind]
+		if !hasValue {
+			if fv.Kind() == reflect.Bool {
+				value = "true"
+			} else if i+1 < len(toks.Args) {
+				i++
+				value = toks.Args[i]
+			} else {
+				return &ArgError{Arg: argName(ft), Type: argTypeName(ft, ft.Type, false), Err: ErrFlagValue}
+			}
+		}
+
+		got, err := parseArg(ses, msg, ft, ft.Type, value)
+		if err != nil {
+			return err
+		}
+		fv.Set(got)
+	}
+	toks = toks.From(i)
+	args := toks.Args
+
 	if len(argFields) == 0 {
 		return nil
 	}
 
-	if len(args) < len(argFields) {
-		// check var args in the last slot
-		if !(len(args) == len(argFields)-1 && argFields[len(argFields)-1].Kind() == reflect.Slice) {
-			return ErrNotEnoughArgs
+	// count required args, checking they all come before optional ones
+	required := 0
+	for i, ft := range argTypes {
+		if argFields[i].Kind() == reflect.Slice {
+			// var args are always optional
+			continue
 		}
+		if argOptional(ft) {
+			continue
+		}
+		if required != i {
+			panic("FillArgs: required arg " + ft.Tag.Get("arg") + " comes after an optional arg")
+		}
+		required++
+	}
+
+	if len(args) < required {
+		return ErrNotEnoughArgs
 	}
 
 	// iterate through arg fields
 	argIndex := 0
 	for i, fv := range argFields {
-		// make sure we don't hit invalid indexes
-		if i == len(args) {
-			break
-		}
-
-		// kind switch for field types
-		switch fv.Kind() {
-		case reflect.String:
-			fv.SetString(args[argIndex])
-
-		case reflect.Int:
-			var got int
-			got, err = strconv.Atoi(args[argIndex])
-			if err != nil {
-				return err
-			}
-			fv.SetInt(int64(got))
-
-		case reflect.Bool:
-			var got bool
-			got, err = strconv.ParseBool(args[argIndex])
-			if err != nil {
-				return err
-			}
-			fv.SetBool(got)
-
-		case reflect.Array, reflect.Slice:
+		switch {
+		case fv.Kind() == reflect.Array || fv.Kind() == reflect.Slice:
 			// check slice is last arg field
 			if i+1 != len(argFields) {
 				panic("FillArgs: variable-length arg but is not the final arg field")
@@ -275,40 +446,47 @@ func FillArgs(c Command, args []string) error {
 			// make new slice value of slice field's element type
 			elemType := fv.Type().Elem()
 			sv := reflect.MakeSlice(reflect.SliceOf(elemType), 0, 0)
-
-			// kind switch for slice elem types
-			switch elemType.Kind() {
-			case reflect.String:
-				for ; argIndex < len(args); argIndex++ {
-					sv = reflect.Append(sv, reflect.ValueOf(args[argIndex]))
-				}
-			case reflect.Int:
-				for ; argIndex < len(args); argIndex++ {
-					var got int
-					got, err = strconv.Atoi(args[argIndex])
-					if err != nil {
-						return err
-					}
-					sv = reflect.Append(sv, reflect.ValueOf(got))
+			for ; argIndex < len(args); argIndex++ {
+				got, err := parseArg(ses, msg, argTypes[i], elemType, args[argIndex])
+				if err != nil {
+					return err
 				}
-			case reflect.Bool:
-				for ; argIndex < len(args); argIndex++ {
-					var got bool
-					got, err = strconv.ParseBool(args[argIndex])
-					if err != nil {
-						return err
-					}
-					sv = reflect.Append(sv, reflect.ValueOf(got))
-				}
-			default:
-				panic("FillArgs: var-arg field cannot handle elem type " + elemType.Kind().String())
+				sv = reflect.Append(sv, got)
 			}
 
 			fv.Set(sv.Slice(0, sv.Len()))
 			return nil
 
+		case argRest(argTypes[i]):
+			// check rest is last arg field
+			if i+1 != len(argFields) || fv.Kind() != reflect.String {
+				panic("FillArgs: rest arg but is not the final arg field or not a string")
+			}
+
+			if argIndex == len(args) {
+				fv.Set(argDefault(argTypes[i]))
+			} else {
+				fv.SetString(toks.rest[argIndex])
+			}
+			return nil
+
 		default:
-			panic("FillArgs: arg field cannot handle type " + fv.Kind().String())
+			// ran out of args, use defaults for the rest
+			if argIndex == len(args) {
+				fv.Set(argDefault(argTypes[i]))
+				continue
+			}
+
+			got, err := parseArg(ses, msg, argTypes[i], fv.Type(), args[argIndex])
+			if err != nil {
+				if argOptional(argTypes[i]) && i+1 < len(argFields) {
+					// leave the arg for the next field
+					fv.Set(argDefault(argTypes[i]))
+					continue
+				}
+				return err
+			}
+			fv.Set(got)
 		}
 
 		// continue along arg
@@ -317,7 +495,8 @@ func FillArgs(c Command, args []string) error {
 	return nil
 }
 
-// CleanArgs cleans arg-fields from commands after they've been handled
+// CleanArgs cleans arg-fields from commands after they've been handled,
+// resetting them to their defaults
 //
 // This should be called after your Command is done handling the message.
 //
@@ -338,30 +517,42 @@ func CleanArgs(c Command) {
 		panic(fmt.Sprintf("CleanArgs: %#v is not a struct\n", val))
 	}
 
-	// iterate over arg fields and zero them
+	// iterate over arg and flag fields and reset them
 	for i := 0; i < val.NumField(); i++ {
 		ft := val.Type().Field(i)
 		fv := val.Field(i)
-		_, ok := ft.Tag.Lookup("arg")
-		if !ok {
+		_, isArg := ft.Tag.Lookup("arg")
+		_, isFlag := ft.Tag.Lookup("flag")
+		if !isArg && !isFlag {
 			continue
 		}
 		if !fv.CanSet() {
 			panic("FillArgs: using unexported field with arg tag")
 		}
 
-		// TODO: default arg handling goes here
-		// zero out field
-		fv.Set(reflect.Zero(fv.Type()))
+		// reset field to its default
+		fv.Set(argDefault(ft))
 	}
 }
 
-// InitGuilds initialises guilds for the modules to use
-func InitGuilds(ses *discordgo.Session) error {
-	guilds, err := ses.UserGuilds(1, "", "")
-	if err != nil {
-		return err
+// Clone returns a fresh copy of a command for a single invocation
+//
+// Non-arg fields (e.g. names and descriptions set by the constructor) are copied,
+// arg-fields are cleaned. Fill the clone rather than the command stored in the router
+// so that concurrent invocations don't write over each other's args.
+//
+// Clone will panic if the command is not a pointer to a struct
+func Clone(c Command) Command {
+	val := reflect.ValueOf(c)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("Clone: %#v is not a pointer to a struct\n", c))
 	}
-	Guild = guilds[0]
-	return nil
+
+	// shallow copy into a new value of the same type
+	cpy := reflect.New(val.Elem().Type())
+	cpy.Elem().Set(val.Elem())
+
+	com := cpy.Interface().(Command)
+	CleanArgs(com)
+	return com
 }
@@ -2,7 +2,9 @@ package commands_test
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 
@@ -24,11 +26,13 @@ func (p *BadPing) Aliases() []string { return []string{"ping", "ping pong"} }
 
 func (p *BadPing) Desc() string { return "BadPing!" }
 
+func (p *BadPing) Subcommands() []Command { return nil }
+
 func (p *BadPing) Roles() []string { return nil }
 
 func (p *BadPing) Chans() []string { return nil }
 
-func (p *BadPing) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*CommandSend, error) {
+func (p *BadPing) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
 	return nil, nil
 }
 
@@ -45,11 +49,13 @@ func (p *Ping) Aliases() []string { return []string{"ping", "ping pong"} }
 
 func (p *Ping) Desc() string { return "Ping!" }
 
+func (p *Ping) Subcommands() []Command { return nil }
+
 func (p *Ping) Roles() []string { return nil }
 
 func (p *Ping) Chans() []string { return nil }
 
-func (p *Ping) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*CommandSend, error) {
+func (p *Ping) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
 	return nil, nil
 }
 
@@ -69,14 +75,14 @@ func TestArgFill(t *testing.T) {
 
 	// fill with nothing
 	args = []string{}
-	err = FillArgs(got, args)
+	err = FillArgs(nil, nil, got, args)
 	if err != ErrNotEnoughArgs {
 		t.Errorf("ArgFill(%v, %v) threw error: %v\nexpected error: %v", got, args, err, ErrNotEnoughArgs)
 	}
 
 	// fill with incomplete args
 	args = []string{"bob", "42"}
-	err = FillArgs(got, args)
+	err = FillArgs(nil, nil, got, args)
 	if err != ErrNotEnoughArgs {
 		t.Errorf("ArgFill(%v, %v) threw error: %v\nexpected error: %v", got, args, err, ErrNotEnoughArgs)
 	}
@@ -88,7 +94,7 @@ func TestArgFill(t *testing.T) {
 	exp.Rest = []string{"bob", "is", "cool"}
 
 	args = []string{"bob", "42", "true", "bob", "is", "cool"}
-	err = FillArgs(got, args)
+	err = FillArgs(nil, nil, got, args)
 
 	if err != nil {
 		t.Errorf("ArgFill(%#v, %v)\nthrew error: %v", NewPing(), args, err)
@@ -108,6 +114,273 @@ func TestArgFill(t *testing.T) {
 
 	// fill badly-structured command with args
 	args = []string{"bob", "42", "bob", "is", "cool", "true"}
-	err = FillArgs(pan, args)
+	err = FillArgs(nil, nil, pan, args)
 	t.Errorf("ArgFill(%#v, %v)\nDidn't panic with bad var args placement!", NewBadPing(), args)
 }
+
+type Role struct {
+	name   string
+	Target string `arg:"target"`
+}
+
+func (r *Role) Aliases() []string { return []string{r.name} }
+
+func (r *Role) Desc() string { return "Role!" }
+
+func (r *Role) Subcommands() []Command { return nil }
+
+func (r *Role) Roles() []string { return nil }
+
+func (r *Role) Chans() []string { return nil }
+
+func (r *Role) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	return nil, nil
+}
+
+// TestClone clones a filled command and verifies that
+// - The clone is a different value of the same type
+// - Arg fields are cleaned and non-arg fields are kept
+// - Filling the clone doesn't touch the original
+func TestClone(t *testing.T) {
+	orig := &Role{name: "weeb"}
+	err := FillArgs(nil, nil, orig, []string{"bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := Clone(orig).(*Role)
+	if !ok {
+		t.Fatalf("Clone(%#v) returned a different type", orig)
+	}
+	if got == orig {
+		t.Errorf("Clone(%#v) returned the same pointer", orig)
+	}
+
+	exp := &Role{name: "weeb"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Clone(%#v) = %#v; want %#v", orig, got, exp)
+	}
+
+	err = FillArgs(nil, nil, got, []string{"alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orig.Target != "bob" {
+		t.Errorf("filling the clone changed the original's arg to %s", orig.Target)
+	}
+}
+
+type Rich struct {
+	User    *discordgo.User     `arg:"user"`
+	Channel *discordgo.Channel  `arg:"channel"`
+	Role    *discordgo.Role     `arg:"role"`
+	Wait    time.Duration       `arg:"wait"`
+	Date    time.Time           `arg:"date" format:"2/Jan"`
+	Weight  float64             `arg:"weight"`
+	Mode    string              `arg:"mode" enum:"on,off"`
+	Members []*discordgo.Member `arg:"members"`
+}
+
+func (r *Rich) Aliases() []string { return []string{"rich"} }
+
+func (r *Rich) Desc() string { return "Rich!" }
+
+func (r *Rich) Subcommands() []Command { return nil }
+
+func (r *Rich) Roles() []string { return nil }
+
+func (r *Rich) Chans() []string { return nil }
+
+func (r *Rich) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	return nil, nil
+}
+
+// TestArgFillTypes fills discord and time typed args
+// and verifies that FillArgs will:
+// - Resolve mentions, ids and names to users, channels and roles
+// - Parse durations, dates, decimals and enums
+// - Throw an ArgError naming the arg when parsing fails
+func TestArgFillTypes(t *testing.T) {
+	ses := newFake()
+	msg := &discordgo.Message{GuildID: "1", ChannelID: "3"}
+
+	got := &Rich{}
+	args := []string{"<@!4>", "#General", "mod", "1h30m", "2/jan", "1.5", "ON", "bob", "4"}
+	err := FillArgs(ses, msg, got, args)
+	if err != nil {
+		t.Fatalf("FillArgs(%v) threw error: %v", args, err)
+	}
+
+	if got.User == nil || got.User.ID != "4" {
+		t.Errorf("FillArgs(%v) set user %#v; want id 4", args, got.User)
+	}
+	if got.Channel == nil || got.Channel.ID != "3" {
+		t.Errorf("FillArgs(%v) set channel %#v; want id 3", args, got.Channel)
+	}
+	if got.Role == nil || got.Role.ID != "2" {
+		t.Errorf("FillArgs(%v) set role %#v; want id 2", args, got.Role)
+	}
+	if got.Wait != 90*time.Minute {
+		t.Errorf("FillArgs(%v) set wait %v; want %v", args, got.Wait, 90*time.Minute)
+	}
+	if got.Date.Month() != time.January || got.Date.Day() != 2 {
+		t.Errorf("FillArgs(%v) set date %v; want 2 Jan", args, got.Date)
+	}
+	if got.Weight != 1.5 {
+		t.Errorf("FillArgs(%v) set weight %v; want 1.5", args, got.Weight)
+	}
+	if got.Mode != "on" {
+		t.Errorf("FillArgs(%v) set mode %v; want on", args, got.Mode)
+	}
+	if len(got.Members) != 2 || got.Members[0].User.ID != "4" || got.Members[1].User.ID != "4" {
+		t.Errorf("FillArgs(%v) set members %v; want [4 4]", args, got.Members)
+	}
+
+	// bad args
+	bad := map[int]string{
+		0: "nobody",
+		1: "<#42>",
+		3: "soon",
+		4: "tomorrow",
+		6: "maybe",
+	}
+	for ind, arg := range bad {
+		args := []string{"4", "3", "2", "1h", "2/jan", "1", "on"}
+		args[ind] = arg
+		err = FillArgs(ses, msg, &Rich{}, args)
+		if _, ok := err.(*ArgError); !ok {
+			t.Errorf("FillArgs(%v) threw %v; want an ArgError", args, err)
+		}
+	}
+
+	// channels in other guilds the bot is in
+	ses.State().GuildAdd(&discordgo.Guild{
+		ID:       "8",
+		Channels: []*discordgo.Channel{&discordgo.Channel{ID: "9", GuildID: "8", Name: "other"}},
+	})
+	err = FillArgs(ses, msg, &Rich{}, []string{"4", "<#9>", "2", "1h", "2/jan", "1", "on"})
+	if _, ok := err.(*ArgError); !ok {
+		t.Errorf("FillArgs with another guild's channel threw %v; want an ArgError", err)
+	}
+
+	// no session
+	err = FillArgs(nil, nil, &Rich{}, []string{"4", "3", "2", "1h", "2/jan", "1", "on"})
+	if argErr, ok := err.(*ArgError); !ok || argErr.Err != ErrArgNoSession {
+		t.Errorf("FillArgs without a session threw %v; want %v", err, ErrArgNoSession)
+	}
+}
+
+// TestGetUsage verifies usage strings name arg types
+func TestGetUsage(t *testing.T) {
+	got := strings.Split(GetUsage(&Rich{}), "\n")[0]
+	exp := "**!rich** (user) __user__ (channel) __channel__ (role) __role__ (duration) __wait__" +
+		" (date) __date__ (decimal) __weight__ (on/off) __mode__ (multiple members) __members__"
+	if got != exp {
+		t.Errorf("GetUsage(&Rich{}) = %s; want %s", got, exp)
+	}
+}
+
+type Optional struct {
+	Name  string          `arg:"name"`
+	Count int             `arg:"count" default:"3"`
+	User  *discordgo.User `arg:"user" optional:"true"`
+	Rest  []string        `arg:"rest"`
+}
+
+func (o *Optional) Aliases() []string { return []string{"optional"} }
+
+func (o *Optional) Desc() string { return "Optional!" }
+
+func (o *Optional) Subcommands() []Command { return nil }
+
+func (o *Optional) Roles() []string { return nil }
+
+func (o *Optional) Chans() []string { return nil }
+
+func (o *Optional) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	return nil, nil
+}
+
+type BadOptional struct {
+	Count int    `arg:"count" default:"1"`
+	Name  string `arg:"name"`
+}
+
+func (o *BadOptional) Aliases() []string { return []string{"optional"} }
+
+func (o *BadOptional) Desc() string { return "BadOptional!" }
+
+func (o *BadOptional) Subcommands() []Command { return nil }
+
+func (o *BadOptional) Roles() []string { return nil }
+
+func (o *BadOptional) Chans() []string { return nil }
+
+func (o *BadOptional) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	return nil, nil
+}
+
+// TestArgFillOptional verifies that FillArgs will:
+// - Use defaults for missing optional args
+// - Skip optional args that can't be parsed if a later arg can take them
+// - Require all non-optional args
+// - Panic if a required arg comes after an optional one
+// and that CleanArgs resets args to their defaults
+func TestArgFillOptional(t *testing.T) {
+	ses := newFake()
+	msg := &discordgo.Message{GuildID: "1", ChannelID: "3"}
+
+	got := &Optional{}
+	err := FillArgs(ses, msg, got, []string{"foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "foo" || got.Count != 3 || got.User != nil || len(got.Rest) != 0 {
+		t.Errorf("FillArgs([foo]) set %#v; want defaults", got)
+	}
+
+	got = &Optional{}
+	err = FillArgs(ses, msg, got, []string{"foo", "5", "bob", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 5 || got.User == nil || got.User.ID != "4" || len(got.Rest) != 2 {
+		t.Errorf("FillArgs([foo 5 bob a b]) set %#v", got)
+	}
+
+	// count and user can't be parsed, so they fall through to rest
+	got = &Optional{}
+	err = FillArgs(ses, msg, got, []string{"foo", "nobody", "else"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 3 || got.User != nil || len(got.Rest) != 2 || got.Rest[0] != "nobody" {
+		t.Errorf("FillArgs([foo nobody else]) set %#v", got)
+	}
+
+	err = FillArgs(ses, msg, &Optional{}, []string{})
+	if err != ErrNotEnoughArgs {
+		t.Errorf("FillArgs([]) threw %v; want %v", err, ErrNotEnoughArgs)
+	}
+
+	CleanArgs(got)
+	if got.Name != "" || got.Count != 3 || got.Rest != nil {
+		t.Errorf("CleanArgs left %#v; want defaults", got)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("FillArgs(&BadOptional{}) didn't panic")
+		}
+	}()
+	FillArgs(nil, nil, &BadOptional{}, []string{"1", "foo"})
+}
+
+// TestGetUsageOptional verifies usage strings mark optional args
+func TestGetUsageOptional(t *testing.T) {
+	got := strings.Split(GetUsage(&Optional{}), "\n")[0]
+	exp := "**!optional** (word) __name__ (number) [__count__=3] (user) [__user__] (multiple words) __rest__"
+	if got != exp {
+		t.Errorf("GetUsage(&Optional{}) = %s; want %s", got, exp)
+	}
+}
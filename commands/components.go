@@ -0,0 +1,389 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ComponentType is the type of a Component
+type ComponentType int
+
+// component types
+const (
+	ComponentActionsRow ComponentType = iota + 1
+	ComponentButton
+	ComponentSelectMenu
+)
+
+// ButtonStyle is the colour of a button, link buttons open their URL instead of calling a handler
+type ButtonStyle int
+
+// button styles
+const (
+	ButtonPrimary ButtonStyle = iota + 1
+	ButtonSecondary
+	ButtonSuccess
+	ButtonDanger
+	ButtonLink
+)
+
+const (
+	// DefaultComponentTimeout is how long components last without being used
+	DefaultComponentTimeout = 5 * time.Minute
+
+	// ComponentExpired and ComponentNotYours are told to users that can't use a component
+	ComponentExpired  = "This has expired, try the command again."
+	ComponentNotYours = "This isn't for you."
+
+	// component limits
+	componentRowsLimit    = 5
+	componentsPerRowLimit = 5
+	selectOptionsLimit    = 25
+)
+
+var (
+	// ErrComponentFiles means a message with components also had files, which we can't send together
+	ErrComponentFiles = errors.New("can't send files with components")
+	// ErrComponentLimit means a ComponentSet has too many rows, or a row has too many components
+	ErrComponentLimit = errors.New("too many components")
+
+	// componentSets are the sets waiting to be used, indexed by set id
+	componentSets     = make(map[string]*ComponentSet)
+	componentSetsLock = &sync.Mutex{}
+	componentSetID    = 0
+	// componentRun makes set ids unique between runs, so old components don't reach new sets
+	componentRun = strconv.FormatInt(time.Now().UnixNano(), 36)
+)
+
+// Component is a button, select menu or row of components, as discord marshals them
+type Component struct {
+	Type     ComponentType `json:"type"`
+	CustomID string        `json:"custom_id,omitempty"`
+	Disabled bool          `json:"disabled,omitempty"`
+
+	// buttons
+	Style ButtonStyle      `json:"style,omitempty"`
+	Label string           `json:"label,omitempty"`
+	Emoji *discordgo.Emoji `json:"emoji,omitempty"`
+	URL   string           `json:"url,omitempty"`
+
+	// select menus
+	Placeholder string          `json:"placeholder,omitempty"`
+	MinValues   int             `json:"min_values,omitempty"`
+	MaxValues   int             `json:"max_values,omitempty"`
+	Options     []*SelectOption `json:"options,omitempty"`
+
+	// rows
+	Components []*Component `json:"components,omitempty"`
+}
+
+// SelectOption is an option in a select menu
+type SelectOption struct {
+	Label       string `json:"label"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default,omitempty"`
+}
+
+// NewButton returns a button, customID is the key of its handler in a ComponentSet
+func NewButton(style ButtonStyle, label string, customID string) *Component {
+	return &Component{Type: ComponentButton, Style: style, Label: label, CustomID: customID}
+}
+
+// NewLinkButton returns a button that opens the url
+func NewLinkButton(label string, url string) *Component {
+	return &Component{Type: ComponentButton, Style: ButtonLink, Label: label, URL: url}
+}
+
+// NewSelectMenu returns a select menu that takes one option, customID is the key of its handler in a ComponentSet
+//
+// Options past discord's limit of 25 are dropped
+func NewSelectMenu(customID string, placeholder string, opts ...*SelectOption) *Component {
+	if len(opts) > selectOptionsLimit {
+		opts = opts[:selectOptionsLimit]
+	}
+	return &Component{Type: ComponentSelectMenu, CustomID: customID, Placeholder: placeholder, Options: opts}
+}
+
+// ComponentHandler handles a user using a component
+type ComponentHandler func(ctx *ComponentContext) error
+
+// ComponentSet is the components on a message and the handlers for them
+//
+// Build it up with Row and Handle, then attach it to a CommandSend with Components.
+// Handlers are keyed by the custom IDs of the components, which only need to be unique within the set.
+// The components are removed from the message after Timeout passes without them being used, or on Close.
+type ComponentSet struct {
+	Users   []string      // if set, only these users can use the components
+	Timeout time.Duration // how long the components last between uses, DefaultComponentTimeout if 0
+
+	rows     []*Component
+	handlers map[string]ComponentHandler
+
+	mu     sync.Mutex
+	id     string
+	ses    Session
+	msg    *discordgo.Message
+	timer  *time.Timer
+	closed bool
+}
+
+// NewComponentSet returns an empty ComponentSet
+func NewComponentSet() *ComponentSet {
+	return &ComponentSet{
+		rows:     []*Component{},
+		handlers: make(map[string]ComponentHandler),
+	}
+}
+
+// Row adds a row of components
+func (s *ComponentSet) Row(comps ...*Component) *ComponentSet {
+	s.rows = append(s.rows, &Component{Type: ComponentActionsRow, Components: comps})
+	return s
+}
+
+// Handle sets the handler for the components with the custom ID
+func (s *ComponentSet) Handle(customID string, handler ComponentHandler) *ComponentSet {
+	s.handlers[customID] = handler
+	return s
+}
+
+// Message returns the message the components are on, nil if they haven't been sent
+func (s *ComponentSet) Message() *discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.msg
+}
+
+// validate checks the set against discord's limits
+func (s *ComponentSet) validate() error {
+	if len(s.rows) > componentRowsLimit {
+		return ErrComponentLimit
+	}
+	for _, row := range s.rows {
+		if len(row.Components) > componentsPerRowLimit {
+			return ErrComponentLimit
+		}
+	}
+	return nil
+}
+
+// render returns the rows with custom IDs prefixed by the set's id, must be called with the lock held
+func (s *ComponentSet) render() []*Component {
+	rows := []*Component{}
+	for _, row := range s.rows {
+		cpy := *row
+		cpy.Components = []*Component{}
+		for _, comp := range row.Components {
+			c := *comp
+			if len(c.CustomID) > 0 {
+				c.CustomID = s.id + ":" + c.CustomID
+			}
+			cpy.Components = append(cpy.Components, &c)
+		}
+		rows = append(rows, &cpy)
+	}
+	return rows
+}
+
+// start registers the set so its handlers can be called, must be called with the lock held
+func (s *ComponentSet) start(ses Session) {
+	componentSetsLock.Lock()
+	componentSetID++
+	s.id = componentRun + "." + strconv.Itoa(componentSetID)
+	componentSets[s.id] = s
+	componentSetsLock.Unlock()
+
+	s.ses = ses
+	s.timer = time.AfterFunc(s.timeout(), s.Close)
+}
+
+func (s *ComponentSet) timeout() time.Duration {
+	if s.Timeout == 0 {
+		return DefaultComponentTimeout
+	}
+	return s.Timeout
+}
+
+// allowed returns whether the user can use the components
+func (s *ComponentSet) allowed(userID string) bool {
+	if len(s.Users) == 0 {
+		return true
+	}
+	for _, usr := range s.Users {
+		if usr == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Close removes the components from the message and stops handling them, it is safe to call more than once
+func (s *ComponentSet) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+}
+
+// close does Close, must be called with the lock held
+func (s *ComponentSet) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	componentSetsLock.Lock()
+	delete(componentSets, s.id)
+	componentSetsLock.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.msg != nil {
+		url := endpointSlashAPI + "channels/" + s.msg.ChannelID + "/messages/" + s.msg.ID
+		s.ses.RequestWithBucketID("PATCH", url, map[string][]*Component{"components": {}},
+			endpointSlashAPI+"channels/"+s.msg.ChannelID+"/messages")
+	}
+}
+
+// SendComponents sends a message with the set's components, and starts handling them
+//
+// discordgo can't send components yet, so this can't send files either
+func SendComponents(ses Session, channelID string, data *discordgo.MessageSend, set *ComponentSet) (*discordgo.Message, error) {
+	if data.File != nil || len(data.Files) > 0 {
+		return nil, ErrComponentFiles
+	}
+	if err := set.validate(); err != nil {
+		return nil, err
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.start(ses)
+
+	body := newWebhookMessage(data)
+	body.Components = set.render()
+
+	// reply to the interaction if we're in one
+	var msg *discordgo.Message
+	var err error
+	if isl, ok := ses.(*interactionSession); ok && channelID == isl.in.ChannelID {
+		msg, err = isl.send(channelID, body)
+	} else {
+		msg, err = sendRaw(ses, channelID, body)
+	}
+	if err != nil {
+		set.close()
+		return nil, err
+	}
+
+	set.msg = msg
+	return msg, nil
+}
+
+// sendRaw sends a message the way discord takes it, rather than the way discordgo does
+func sendRaw(ses Session, channelID string, body *webhookMessage) (*discordgo.Message, error) {
+	url := endpointSlashAPI + "channels/" + channelID + "/messages"
+	res, err := ses.RequestWithBucketID("POST", url, body, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg discordgo.Message
+	err = json.Unmarshal(res, &msg)
+	if err != nil {
+		return nil, err
+	}
+	msg.ChannelID = channelID
+	return &msg, nil
+}
+
+// ComponentContext is a single use of a component
+type ComponentContext struct {
+	Session     Session
+	Interaction *Interaction
+	User        *discordgo.User // who used the component
+	CustomID    string          // the component's custom ID, without the set's prefix
+	Values      []string        // the options chosen in a select menu
+	Set         *ComponentSet
+}
+
+// webhookURL returns the url for the interaction's webhook
+func (c *ComponentContext) webhookURL() string {
+	return endpointSlashAPI + "webhooks/" + c.Interaction.ApplicationID + "/" + c.Interaction.Token
+}
+
+// Update edits the message the component is on, the embed is left alone if nil
+func (c *ComponentContext) Update(content string, embed *discordgo.MessageEmbed) error {
+	body := newWebhookMessage(&discordgo.MessageSend{Content: content, Embed: embed})
+	_, err := c.Session.RequestWithBucketID("PATCH", c.webhookURL()+"/messages/@original", body,
+		endpointSlashAPI+"webhooks/"+c.Interaction.ApplicationID)
+	return err
+}
+
+// Reply sends a new message in reply, ephemeral replies are only shown to the user
+func (c *ComponentContext) Reply(content string, ephemeral bool) error {
+	body := &webhookMessage{Content: content}
+	if ephemeral {
+		body.Flags = messageFlagEphemeral
+	}
+	_, err := c.Session.RequestWithBucketID("POST", c.webhookURL(), body,
+		endpointSlashAPI+"webhooks/"+c.Interaction.ApplicationID)
+	return err
+}
+
+// Close removes the set's components from the message, see ComponentSet.Close
+func (c *ComponentContext) Close() { c.Set.Close() }
+
+// handleComponent calls the handler for a component interaction
+//
+// Users that can't use the component are told so, only they see it.
+func handleComponent(ses Session, in *Interaction) error {
+	id, customID := in.Data.CustomID, ""
+	if ind := strings.Index(id, ":"); ind >= 0 {
+		id, customID = id[:ind], id[ind+1:]
+	}
+
+	componentSetsLock.Lock()
+	set, ok := componentSets[id]
+	componentSetsLock.Unlock()
+	if !ok {
+		return respond(ses, in, responseMessage, &webhookMessage{Content: ComponentExpired, Flags: messageFlagEphemeral})
+	}
+
+	usr := in.Author()
+	if usr == nil || !set.allowed(usr.ID) {
+		return respond(ses, in, responseMessage, &webhookMessage{Content: ComponentNotYours, Flags: messageFlagEphemeral})
+	}
+
+	// handlers might take a while, so let discord know we got it
+	err := respond(ses, in, responseDeferredUpdate, nil)
+	if err != nil {
+		return err
+	}
+
+	set.mu.Lock()
+	if !set.closed {
+		set.timer.Reset(set.timeout())
+	}
+	handler := set.handlers[customID]
+	set.mu.Unlock()
+
+	if handler == nil {
+		return nil
+	}
+	return handler(&ComponentContext{
+		Session:     ses,
+		Interaction: in,
+		User:        usr,
+		CustomID:    customID,
+		Values:      in.Data.Values,
+		Set:         set,
+	})
+}
@@ -0,0 +1,114 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// customIDs returns the custom IDs of the components sent in a request
+func customIDs(t *testing.T, req *FakeRequest) []string {
+	mar, err := json.Marshal(req.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct{ Components []*Component }
+	json.Unmarshal(mar, &body)
+
+	ids := []string{}
+	for _, row := range body.Components {
+		for _, comp := range row.Components {
+			ids = append(ids, comp.CustomID)
+		}
+	}
+	return ids
+}
+
+// useComponent makes the user use the component, returning the callback's response type and content
+func useComponent(t *testing.T, ses *FakeSession, userID string, customID string) (int, string) {
+	before := len(ses.Requests())
+	NewDispatcher().DispatchInteraction(ses, &Interaction{
+		ID:            "100",
+		ApplicationID: "0",
+		Type:          InteractionMessageComponent,
+		Data:          &InteractionData{CustomID: customID, ComponentType: ComponentButton},
+		GuildID:       "1",
+		ChannelID:     "3",
+		Member:        &discordgo.Member{User: &discordgo.User{ID: userID}},
+		Token:         "token",
+	})
+
+	reqs := ses.Requests()
+	if len(reqs) == before {
+		t.Fatalf("using %s didn't respond", customID)
+	}
+	mar, _ := json.Marshal(reqs[before].Data)
+	var res struct {
+		Type int
+		Data struct{ Content string }
+	}
+	json.Unmarshal(mar, &res)
+	return res.Type, res.Data.Content
+}
+
+// TestComponents verifies that components will:
+// - Be sent with custom IDs unique to their set
+// - Call the handler for the component used, and only for allowed users
+// - Expire on Close and after their timeout
+func TestComponents(t *testing.T) {
+	ses := newFake()
+
+	used := ""
+	set := NewComponentSet()
+	set.Users = []string{"4"}
+	set.Row(NewButton(ButtonSuccess, "Yes", "yes"), NewButton(ButtonDanger, "No", "no"))
+	set.Handle("yes", func(ctx *ComponentContext) error {
+		used = ctx.CustomID
+		return ctx.Update("yes!", nil)
+	})
+
+	err := NewSimpleSend("3", "sure?").Components(set).Send(ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := customIDs(t, ses.Requests()[0])
+	if len(ids) != 2 || ids[0] == "yes" || ids[0] == ids[1] {
+		t.Fatalf("sent custom IDs %v; want two unique to the set", ids)
+	}
+	if set.Message() == nil {
+		t.Errorf("set wasn't given its message")
+	}
+
+	typ, content := useComponent(t, ses, "5", ids[0])
+	if content != ComponentNotYours || used != "" {
+		t.Errorf("another user using the button got %d %q and called %q", typ, content, used)
+	}
+
+	useComponent(t, ses, "4", ids[0])
+	if used != "yes" {
+		t.Errorf("using the button called %q; want yes", used)
+	}
+
+	set.Close()
+	if _, content = useComponent(t, ses, "4", ids[0]); content != ComponentExpired {
+		t.Errorf("using a closed button got %q; want %q", content, ComponentExpired)
+	}
+
+	// timeout
+	set = NewComponentSet().Row(NewButton(ButtonPrimary, "Go", "go"))
+	set.Timeout = 10 * time.Millisecond
+	if err = NewSimpleSend("3", "go?").Components(set).Send(ses); err != nil {
+		t.Fatal(err)
+	}
+	reqs := ses.Requests()
+	ids = customIDs(t, reqs[len(reqs)-1])
+
+	time.Sleep(50 * time.Millisecond)
+	if _, content = useComponent(t, ses, "4", ids[0]); content != ComponentExpired {
+		t.Errorf("using a timed out button got %q; want %q", content, ComponentExpired)
+	}
+}
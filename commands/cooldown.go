@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CooldownScope is who shares a command's cooldown
+type CooldownScope int
+
+const (
+	// CooldownUser gives each user their own cooldown
+	CooldownUser CooldownScope = iota
+	// CooldownChannel shares the cooldown between everyone in a channel
+	CooldownChannel
+	// CooldownGlobal shares the cooldown between everyone everywhere
+	CooldownGlobal
+
+	// cooldownIndex is the db index cooldowns are persisted under
+	cooldownIndex = "cooldown"
+)
+
+// Cooldowner is implemented by commands that are rate limited
+//
+// The command can't be called again by the same scope until the duration has passed.
+type Cooldowner interface {
+	Cooldown() (time.Duration, CooldownScope)
+}
+
+// Cooldowns keeps track of when commands can next be called
+//
+// Cooldowns live in memory, and in the db as well if persisted so they survive restarts.
+type Cooldowns struct {
+	Bypass []string // roles, permissions or groups that ignore cooldowns
+
+	persist bool
+	mu      sync.Mutex
+	until   map[string]time.Time // indexed by cooldownKey
+}
+
+// NewCooldowns returns an empty Cooldowns that mods bypass, backed by the db if persist is set
+func NewCooldowns(persist bool) *Cooldowns {
+	return &Cooldowns{
+		Bypass:  []string{"mod"},
+		persist: persist,
+		until:   make(map[string]time.Time),
+	}
+}
+
+// cooldownKey returns the key for the command's cooldown in the scope the message is in
+func cooldownKey(com Command, scope CooldownScope, msg *discordgo.Message) string {
+	key := com.Aliases()[0]
+	switch scope {
+	case CooldownUser:
+		key += ":user:" + msg.Author.ID
+	case CooldownChannel:
+		key += ":channel:" + msg.ChannelID
+	default:
+		key += ":global"
+	}
+	return key
+}
+
+// Take starts the command's cooldown for the message's scope,
+// or returns how long is left if it's already cooling down.
+//
+// Commands that aren't Cooldowners can always be taken.
+func (c *Cooldowns) Take(com Command, msg *discordgo.Message) (wait time.Duration) {
+	cdr, ok := com.(Cooldowner)
+	if !ok {
+		return 0
+	}
+	dur, scope := cdr.Cooldown()
+	if dur <= 0 {
+		return 0
+	}
+	key := cooldownKey(com, scope, msg)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[key]
+	if !ok && c.persist {
+		until, ok = c.load(key)
+	}
+	if ok && now.Before(until) {
+		return until.Sub(now)
+	}
+
+	// forget expired cooldowns so the map doesn't grow forever
+	for k, u := range c.until {
+		if !now.Before(u) {
+			delete(c.until, k)
+		}
+	}
+
+	c.until[key] = now.Add(dur)
+	if c.persist {
+		c.save(key, now.Add(dur), dur)
+	}
+	return 0
+}
+
+// load gets a cooldown from the db, must be called with the lock held
+func (c *Cooldowns) load(key string) (time.Time, bool) {
+	if DB == nil {
+		return time.Time{}, false
+	}
+
+	var until time.Time
+	err := DB.View(func(tx StoreTx) error {
+		val, err := tx.Get(cooldownIndex + ":" + key)
+		if err != nil {
+			return err
+		}
+		until, err = time.Parse(time.RFC3339Nano, val)
+		return err
+	})
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	c.until[key] = until
+	return until, true
+}
+
+// save puts a cooldown in the db, expiring when it ends. Must be called with the lock held
+func (c *Cooldowns) save(key string, until time.Time, dur time.Duration) {
+	if DB == nil {
+		return
+	}
+
+	// cooldowns are best effort, a failed save only means it's forgotten on restart
+	DB.Update(func(tx StoreTx) error {
+		return tx.Set(cooldownIndex+":"+key, until.Format(time.RFC3339Nano), dur)
+	})
+}
+
+// Cooldown stops commands being called while they're cooling down, telling the user how long is left.
+// Users with one of cds.Bypass's roles or permissions are let through.
+func Cooldown(cds *Cooldowns) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			if len(cds.Bypass) > 0 {
+				has, err := HasRoles(ctx.Session, ctx.Message, cds.Bypass)
+				if err == nil && has {
+					return next(ctx)
+				}
+			}
+
+			wait := cds.Take(ctx.Command, ctx.Message)
+			if wait > 0 {
+				// round up so we never say 0s
+				wait = (wait + time.Second - 1).Truncate(time.Second)
+				return &CheckError{"Slow down! You can use this command again in " + wait.String()}
+			}
+			return next(ctx)
+		}
+	}
+}
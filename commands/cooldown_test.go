@@ -0,0 +1,112 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+/* preamble */
+
+type Slow struct {
+	scope CooldownScope
+}
+
+func (s *Slow) Aliases() []string { return []string{"slow"} }
+
+func (s *Slow) Desc() string { return "Slow!" }
+
+func (s *Slow) Subcommands() []Command { return nil }
+
+func (s *Slow) Roles() []string { return nil }
+
+func (s *Slow) Chans() []string { return nil }
+
+func (s *Slow) Cooldown() (time.Duration, CooldownScope) { return time.Minute, s.scope }
+
+func (s *Slow) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	return NewSimpleSend(msg.ChannelID, "done"), nil
+}
+
+// slowMessage returns a message from the user in the channel
+func slowMessage(userID, channelID string) *discordgo.Message {
+	return &discordgo.Message{ChannelID: channelID, GuildID: "1", Author: &discordgo.User{ID: userID}}
+}
+
+/* tests */
+
+// TestCooldownTake verifies that Take will:
+// - Let commands without cooldowns through
+// - Only share cooldowns within the command's scope
+// - Load cooldowns from the db when persisted
+func TestCooldownTake(t *testing.T) {
+	cds := NewCooldowns(false)
+	if wait := cds.Take(NewPing(), slowMessage("4", "3")); wait != 0 {
+		t.Errorf("Take on a command without a cooldown = %v; want 0", wait)
+	}
+
+	tests := []struct {
+		scope CooldownScope
+		other *discordgo.Message // shares the cooldown
+		apart *discordgo.Message // doesn't share the cooldown
+	}{
+		{CooldownUser, slowMessage("4", "5"), slowMessage("6", "3")},
+		{CooldownChannel, slowMessage("6", "3"), slowMessage("4", "5")},
+		{CooldownGlobal, slowMessage("6", "5"), nil},
+	}
+	for _, tt := range tests {
+		cds := NewCooldowns(false)
+		com := &Slow{tt.scope}
+
+		if wait := cds.Take(com, slowMessage("4", "3")); wait != 0 {
+			t.Errorf("first Take in scope %d = %v; want 0", tt.scope, wait)
+		}
+		if wait := cds.Take(com, tt.other); wait <= 0 || wait > time.Minute {
+			t.Errorf("Take in the same scope %d = %v; want (0, 1m]", tt.scope, wait)
+		}
+		if tt.apart != nil {
+			if wait := cds.Take(com, tt.apart); wait != 0 {
+				t.Errorf("Take outside scope %d = %v; want 0", tt.scope, wait)
+			}
+		}
+	}
+
+	// restart
+	NewCooldowns(true).Take(&Slow{CooldownUser}, slowMessage("7", "3"))
+	if wait := NewCooldowns(true).Take(&Slow{CooldownUser}, slowMessage("7", "3")); wait <= 0 {
+		t.Errorf("persisted cooldown was forgotten")
+	}
+}
+
+// TestCooldownMiddleware verifies that the Cooldown middleware replies with the wait, and lets mods through
+func TestCooldownMiddleware(t *testing.T) {
+	ses := newFake()
+	ses.State().MemberAdd(&discordgo.Member{GuildID: "1", User: &discordgo.User{ID: "8"}, Roles: []string{"2"}})
+
+	cds := NewCooldowns(false)
+	route := func(argv []string) (Command, int) { return &Slow{CooldownGlobal}, 1 }
+	logf := func(format string, v ...interface{}) {}
+	dis := NewDispatcher(DefaultMiddleware(route, nil, nil, NewHistory(), cds, logf, logf)...)
+
+	if err := dispatch(dis, ses, "4", false, Prefix+"slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dispatch(dis, ses, "4", false, Prefix+"slow"); err == nil {
+		t.Errorf("second call during cooldown didn't throw an error")
+	}
+	last := ses.LastMessage("3").Content
+	if !strings.Contains(last, "again in 1m0s") {
+		t.Errorf("cooldown reply = %q; want the wait", last)
+	}
+
+	if err := dispatch(dis, ses, "8", false, Prefix+"slow"); err != nil {
+		t.Errorf("mod call during cooldown threw error: %v", err)
+	}
+	if last := ses.LastMessage("3").Content; last != "done" {
+		t.Errorf("mod call during cooldown sent %q; want %q", last, "done")
+	}
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	keyDisabled = "disabled"
+)
+
+var (
+	// ErrDisabledInGuild means a command can't be enabled in a channel because it's disabled in the whole guild
+	ErrDisabledInGuild = errors.New("that command is disabled on the whole server, enable it there first")
+
+	disabledCache     = make(map[string]*DisabledCommands) // indexed by guild id
+	disabledCacheGen  = 0                                  // bumped on every change, so reads from before it aren't cached
+	disabledCacheLock = &sync.Mutex{}
+)
+
+// DisabledCommands are the commands a guild has turned off, by first alias
+//
+// Disabling a command also disables its subcommands.
+type DisabledCommands struct {
+	Guild    []string            // disabled everywhere in the guild
+	Channels map[string][]string // disabled in a channel, indexed by channel id
+}
+
+// Index implements the Storer interface
+func (d *DisabledCommands) Index() string { return "disabled" }
+
+// GetDisabled returns the commands the guild has disabled
+func GetDisabled(guildID string) (*DisabledCommands, error) {
+	var dis DisabledCommands
+	err := DBGet(&dis, GuildKey(guildID, keyDisabled), &dis)
+	if err != nil && err != ErrDBNotFound {
+		return nil, err
+	}
+	if dis.Channels == nil {
+		dis.Channels = make(map[string][]string)
+	}
+	return &dis, nil
+}
+
+// cachedDisabled is GetDisabled from memory after the first read, the result mustn't be changed
+func cachedDisabled(guildID string) (*DisabledCommands, error) {
+	disabledCacheLock.Lock()
+	dis, ok := disabledCache[guildID]
+	gen := disabledCacheGen
+	disabledCacheLock.Unlock()
+	if ok {
+		return dis, nil
+	}
+
+	dis, err := GetDisabled(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	disabledCacheLock.Lock()
+	if gen == disabledCacheGen {
+		disabledCache[guildID] = dis
+	}
+	disabledCacheLock.Unlock()
+	return dis, nil
+}
+
+// forgetDisabled drops the guild's disabled commands from the cache, or every guild's if guildID is empty
+func forgetDisabled(guildID string) {
+	disabledCacheLock.Lock()
+	defer disabledCacheLock.Unlock()
+
+	disabledCacheGen++
+	if len(guildID) == 0 {
+		disabledCache = make(map[string]*DisabledCommands)
+	} else {
+		delete(disabledCache, guildID)
+	}
+}
+
+// SetDisabled disables or enables the command, named by its first alias, in the channel or the whole guild if channelID is empty
+func SetDisabled(guildID string, channelID string, name string, disabled bool) error {
+	defer forgetDisabled(guildID)
+	return DBUpdate(&DisabledCommands{}, GuildKey(guildID, keyDisabled), func(v Storer) error {
+		dis := v.(*DisabledCommands)
+		if len(channelID) == 0 {
+			dis.Guild = toggle(dis.Guild, name, disabled)
+			return nil
+		}
+
+		if !disabled && contains(dis.Guild, name) {
+			return ErrDisabledInGuild
+		}
+		if dis.Channels == nil {
+			dis.Channels = make(map[string][]string)
+		}
+		dis.Channels[channelID] = toggle(dis.Channels[channelID], name, disabled)
+		if len(dis.Channels[channelID]) == 0 {
+			delete(dis.Channels, channelID)
+		}
+		return nil
+	})
+}
+
+// Disabled returns whether the command, or one it's a subcommand of, is disabled in the channel
+//
+// Parent commands are found by routing the start of the command's first alias e.g. tags for tags add.
+func (d *DisabledCommands) Disabled(route RouteFunc, channelID string, com Command) bool {
+	argv := strings.Split(com.Aliases()[0], " ")
+	for i := len(argv); i > 0; i-- {
+		name := strings.Join(argv[:i], " ")
+		if i < len(argv) {
+			// only parents that are commands
+			par, ind := route(argv[:i])
+			if par == nil || ind != i {
+				continue
+			}
+			name = par.Aliases()[0]
+		}
+		if contains(d.Guild, name) || contains(d.Channels[channelID], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEnabled ignores commands that are disabled in the message's channel, using route to find parent commands
+func CheckEnabled(route RouteFunc) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			dis, err := cachedDisabled(ctx.Message.GuildID)
+			if err != nil {
+				return err
+			}
+			if dis.Disabled(route, ctx.Message.ChannelID, ctx.Command) {
+				return nil
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// contains returns whether the list has the string
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// toggle returns the list with or without the string
+func toggle(list []string, s string, in bool) []string {
+	out := []string{}
+	for _, l := range list {
+		if l != s {
+			out = append(out, l)
+		}
+	}
+	if in {
+		out = append(out, s)
+	}
+	return out
+}
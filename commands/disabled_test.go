@@ -0,0 +1,103 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+/* preamble */
+
+// Named is a Say with other aliases
+type Named struct {
+	Say
+	names []string
+}
+
+func (n *Named) Aliases() []string { return n.names }
+
+// namedRoute routes say, say twice and other
+func namedRoute(argv []string) (Command, int) {
+	switch {
+	case len(argv) > 1 && argv[0] == "say" && argv[1] == "twice":
+		return &Named{names: []string{"say twice"}}, 2
+	case argv[0] == "say":
+		return &Named{names: []string{"say"}}, 1
+	case argv[0] == "other":
+		return &Named{names: []string{"other"}}, 1
+	}
+	return nil, 0
+}
+
+/* tests */
+
+// TestDisabled disables commands in a guild and a channel, and verifies that subcommands go with them
+func TestDisabled(t *testing.T) {
+	say, _ := namedRoute([]string{"say"})
+	twice, _ := namedRoute([]string{"say", "twice"})
+	other, _ := namedRoute([]string{"other"})
+
+	err := SetDisabled("1", "3", "say", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dis, err := GetDisabled("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		channel string
+		com     Command
+		want    bool
+	}{
+		{"3", say, true},
+		{"3", twice, true},
+		{"3", other, false},
+		{"5", say, false},
+	}
+	for _, tt := range tests {
+		if got := dis.Disabled(namedRoute, tt.channel, tt.com); got != tt.want {
+			t.Errorf("Disabled(%s, %s) = %v; want %v", tt.channel, tt.com.Aliases()[0], got, tt.want)
+		}
+	}
+
+	// guild-wide disables can't be undone in a channel
+	SetDisabled("1", "", "other", true)
+	if err = SetDisabled("1", "3", "other", false); err != ErrDisabledInGuild {
+		t.Errorf("enabling a guild-disabled command in a channel threw %v; want %v", err, ErrDisabledInGuild)
+	}
+	SetDisabled("1", "", "other", false)
+	SetDisabled("1", "3", "say", false)
+	dis, _ = GetDisabled("1")
+	if len(dis.Guild) != 0 || len(dis.Channels) != 0 {
+		t.Errorf("enabling everything left %+v", dis)
+	}
+}
+
+// TestCheckEnabled verifies that disabled commands are ignored by dispatch
+func TestCheckEnabled(t *testing.T) {
+	ses := newFake()
+	logf := func(format string, v ...interface{}) {}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+
+	SetDisabled("1", "3", "say", true)
+	defer SetDisabled("1", "3", "say", false)
+	err := dis.Dispatch(ses, &discordgo.Message{
+		ID: "100", ChannelID: "3", GuildID: "1", Content: Prefix + "say 1 hi", Author: &discordgo.User{ID: "4"},
+	})
+	if err != nil || ses.LastMessage("3") != nil {
+		t.Errorf("disabled !say threw %v and sent %v; want neither", err, ses.LastMessage("3"))
+	}
+
+	// the cached disabled commands are forgotten when they change
+	SetDisabled("1", "3", "say", false)
+	err = dis.Dispatch(ses, &discordgo.Message{
+		ID: "101", ChannelID: "3", GuildID: "1", Content: Prefix + "say 1 hi", Author: &discordgo.User{ID: "4"},
+	})
+	if err != nil || ses.LastMessage("3") == nil {
+		t.Errorf("enabled !say threw %v and sent %v; want it sent", err, ses.LastMessage("3"))
+	}
+}
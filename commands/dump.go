@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveFormat is the format of the archives DBDump writes, bumped when old bots can't read them
+	archiveFormat = 1
+
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+	snapshotLayout = "20060102T150405Z"
+)
+
+var (
+	// ErrArchiveFormat means it isn't an archive DBDump wrote, or it's from a newer bot
+	ErrArchiveFormat = errors.New("not a db archive this bot can read")
+	// ErrArchiveIndex means the archive has nothing in an index being restored
+	ErrArchiveIndex = errors.New("archive has nothing in that index")
+	// ErrArchiveVersion means only some indexes are being restored from an archive of another db version
+	ErrArchiveVersion = errors.New("archive is from another db version, restore all of it instead")
+)
+
+// DBArchive is what DBDump writes, everything in the db as JSON so any Store can restore it
+//
+// Keys that expire, like cooldowns, and values that aren't Storers are left out.
+type DBArchive struct {
+	Format  int                                   `json:"format"`
+	Version int                                   `json:"version"` // of the db, see DBVersion
+	Created time.Time                             `json:"created"`
+	Indexes map[string]map[string]json.RawMessage `json:"indexes"` // Storers by index then key
+}
+
+// DBIndexes returns how many Storers there are in each index of the db
+func DBIndexes() (map[string]int, error) {
+	arc, err := dbArchive(nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int)
+	for index, recs := range arc.Indexes {
+		out[index] = len(recs)
+	}
+	return out, nil
+}
+
+// DBDump writes an archive of the indexes to w, or of everything if there are none
+func DBDump(w io.Writer, indexes ...string) error {
+	arc, err := dbArchive(indexes)
+	if err != nil {
+		return err
+	}
+	mar, err := json.MarshalIndent(arc, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(mar, '\n'))
+	return err
+}
+
+// dbArchive returns an archive of the indexes, or of everything if there are none
+func dbArchive(indexes []string) (*DBArchive, error) {
+	if DB == nil {
+		return nil, ErrDBNotOpen
+	}
+	ver, err := DBVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]bool)
+	for _, index := range indexes {
+		want[index] = true
+	}
+
+	arc := &DBArchive{
+		Format:  archiveFormat,
+		Version: ver,
+		Created: time.Now().UTC(),
+		Indexes: make(map[string]map[string]json.RawMessage),
+	}
+	err = DB.View(func(tx StoreTx) error {
+		var err error
+		ierr := tx.AscendPrefix("", func(key, val string) bool {
+			parts := strings.SplitN(key, ":", 2)
+			if len(parts) != 2 || parts[0] == (&schema{}).Index() || !json.Valid([]byte(val)) {
+				return true
+			}
+			if len(want) > 0 && !want[parts[0]] {
+				return true
+			}
+
+			var ttl time.Duration
+			ttl, err = tx.TTL(key)
+			if err == ErrDBNotFound || ttl > 0 {
+				err = nil
+				return true
+			}
+			if err != nil {
+				return false
+			}
+
+			if arc.Indexes[parts[0]] == nil {
+				arc.Indexes[parts[0]] = make(map[string]json.RawMessage)
+			}
+			arc.Indexes[parts[0]][parts[1]] = json.RawMessage(val)
+			return true
+		})
+		if ierr != nil {
+			return ierr
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return arc, nil
+}
+
+// DBRestore replaces what's in the indexes with what's in the archive from r, and returns how many Storers it restored
+//
+// With no indexes everything in the db is replaced, then migrated if the archive is old.
+// Only some indexes can be restored from an archive of the same version as the db.
+// Nothing changes if there's an error restoring.
+func DBRestore(r io.Reader, indexes ...string) (int, error) {
+	if DB == nil {
+		return 0, ErrDBNotOpen
+	}
+
+	var arc DBArchive
+	err := json.NewDecoder(r).Decode(&arc)
+	if err != nil || arc.Format < 1 || arc.Format > archiveFormat {
+		return 0, ErrArchiveFormat
+	}
+
+	all := len(indexes) == 0
+	if all {
+		migs := Migrations()
+		if len(migs) > 0 && arc.Version > migs[len(migs)-1].Version {
+			return 0, ErrDBTooNew
+		}
+		for index := range arc.Indexes {
+			indexes = append(indexes, index)
+		}
+	} else {
+		ver, err := DBVersion()
+		if err != nil {
+			return 0, err
+		}
+		if ver != arc.Version {
+			return 0, ErrArchiveVersion
+		}
+		for _, index := range indexes {
+			if _, ok := arc.Indexes[index]; !ok {
+				return 0, ErrArchiveIndex
+			}
+		}
+	}
+
+	// clear out what's being replaced
+	prefixes := []string{""}
+	if !all {
+		prefixes = []string{}
+		for _, index := range indexes {
+			prefixes = append(prefixes, index+":")
+		}
+	}
+
+	defer forgetDisabled("")
+	n := 0
+	err = DB.Update(func(tx StoreTx) error {
+		for _, prefix := range prefixes {
+			keys := []string{}
+			tx.AscendPrefix(prefix, func(key, val string) bool {
+				keys = append(keys, key)
+				return true
+			})
+			for _, key := range keys {
+				if err := tx.Delete(key); err != nil && err != ErrDBNotFound {
+					return err
+				}
+			}
+		}
+
+		for _, index := range indexes {
+			for key, val := range arc.Indexes[index] {
+				buf := &bytes.Buffer{}
+				if err := json.Compact(buf, val); err != nil {
+					return err
+				}
+				if err := tx.Set(index+":"+key, buf.String(), 0); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+
+		if all && arc.Version > 0 {
+			return (&DBTx{tx}).Set(&schema{arc.Version}, keySchema)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if all {
+		_, err = DBMigrate(false)
+	}
+	return n, err
+}
+
+/* snapshots */
+
+// DBSnapshot dumps everything in the db to a new timestamped file in dir, and returns its path
+func DBSnapshot(dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, snapshotPrefix+time.Now().UTC().Format(snapshotLayout)+snapshotSuffix)
+	tmp, err := ioutil.TempFile(dir, snapshotPrefix+"*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	// written to a temporary file first so a snapshot is never half written
+	err = DBDump(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// snapshot is a file DBSnapshot wrote
+type snapshot struct {
+	path    string
+	created time.Time
+}
+
+// snapshots returns the snapshots in dir, oldest first
+func snapshots(dir string) ([]snapshot, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := []snapshot{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		created, err := time.Parse(snapshotLayout, strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix))
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snapshot{filepath.Join(dir, name), created})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].created.Before(snaps[j].created) })
+	return snaps, nil
+}
+
+// DBLastSnapshot returns when the newest snapshot in dir was taken, the zero time if there are none
+func DBLastSnapshot(dir string) (time.Time, error) {
+	snaps, err := snapshots(dir)
+	if err != nil || len(snaps) == 0 {
+		return time.Time{}, err
+	}
+	return snaps[len(snaps)-1].created, nil
+}
+
+// PruneSnapshots removes the snapshots in dir past the newest keep, and those older than maxAge, returning their paths
+//
+// A keep or maxAge of 0 doesn't limit them. The newest snapshot is never removed.
+func PruneSnapshots(dir string, keep int, maxAge time.Duration) ([]string, error) {
+	snaps, err := snapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	now := time.Now()
+	for i, snap := range snaps {
+		newer := len(snaps) - 1 - i // snapshots newer than this one
+		if newer == 0 {
+			break
+		}
+		if (keep > 0 && newer >= keep) || (maxAge > 0 && now.Sub(snap.created) > maxAge) {
+			if err = os.Remove(snap.path); err != nil {
+				return removed, err
+			}
+			removed = append(removed, snap.path)
+		}
+	}
+	return removed, nil
+}
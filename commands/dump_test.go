@@ -0,0 +1,120 @@
+package commands_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestDBRestore dumps the db, changes it, then restores one index and then everything
+func TestDBRestore(t *testing.T) {
+	clearDB(t)
+	DBSet(&thing{A: "dumped"}, "a")
+	DBSet(&record{Owner: "dumped"}, RecordKey("1", "a"))
+	DB.Update(func(tx StoreTx) error {
+		return tx.Set("cooldown:1", "soon", time.Hour)
+	})
+
+	buf := &bytes.Buffer{}
+	err := DBDump(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arc := buf.Bytes()
+	if strings.Contains(buf.String(), "cooldown") {
+		t.Errorf("DBDump dumped a key that expires")
+	}
+
+	DBSet(&thing{A: "changed"}, "a")
+	DBSet(&thing{A: "new"}, "b")
+	DBSet(&record{Owner: "changed"}, RecordKey("1", "a"))
+
+	// just things
+	n, err := DBRestore(bytes.NewReader(arc), "thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got thing
+	DBGet(&got, "a", &got)
+	if n != 1 || got.A != "dumped" {
+		t.Errorf("DBRestore(thing) restored %d and %q; want 1 and dumped", n, got.A)
+	}
+	if err = DBGet(&thing{}, "b", &thing{}); err != ErrDBNotFound {
+		t.Errorf("DBRestore(thing) kept a thing that wasn't dumped, got %v", err)
+	}
+	var rec record
+	DBGet(&rec, RecordKey("1", "a"), &rec)
+	if rec.Owner != "changed" {
+		t.Errorf("DBRestore(thing) restored a record")
+	}
+
+	if _, err = DBRestore(bytes.NewReader(arc), "nope"); err != ErrArchiveIndex {
+		t.Errorf("DBRestore of an index that wasn't dumped gave %v; want %v", err, ErrArchiveIndex)
+	}
+	if _, err = DBRestore(strings.NewReader("{}")); err != ErrArchiveFormat {
+		t.Errorf("DBRestore of not an archive gave %v; want %v", err, ErrArchiveFormat)
+	}
+
+	// everything
+	n, err = DBRestore(bytes.NewReader(arc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	DBGet(&rec, RecordKey("1", "a"), &rec)
+	if n != 2 || rec.Owner != "dumped" {
+		t.Errorf("DBRestore restored %d and %q; want 2 and dumped", n, rec.Owner)
+	}
+	idx, err := DBIndexes()
+	if err != nil || len(idx) != 2 || idx["thing"] != 1 {
+		t.Errorf("DBIndexes gave %v, %v; want a thing and a record", idx, err)
+	}
+}
+
+// TestSnapshots takes a snapshot then prunes older ones
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pcsocgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, age := range []time.Duration{240 * time.Hour, 72 * time.Hour, time.Hour} {
+		name := "snapshot-" + time.Now().Add(-age).UTC().Format("20060102T150405Z") + ".json"
+		ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a snapshot"), 0644)
+
+	path, err := DBSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, err := DBLastSnapshot(dir); err != nil || time.Since(last) > time.Minute {
+		t.Errorf("DBLastSnapshot gave %v, %v; want now", last, err)
+	}
+
+	removed, err := PruneSnapshots(dir, 0, 48*time.Hour)
+	if err != nil || len(removed) != 2 {
+		t.Errorf("PruneSnapshots older than 2 days removed %v, %v; want 2", removed, err)
+	}
+	removed, err = PruneSnapshots(dir, 1, 0)
+	if err != nil || len(removed) != 1 {
+		t.Errorf("PruneSnapshots past 1 removed %v, %v; want 1", removed, err)
+	}
+	removed, err = PruneSnapshots(dir, 0, time.Nanosecond)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("PruneSnapshots removed the newest snapshot %v, %v", removed, err)
+	}
+
+	if _, err = os.Stat(path); err != nil {
+		t.Errorf("the newest snapshot is gone: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("PruneSnapshots removed something else: %v", err)
+	}
+}
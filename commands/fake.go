@@ -0,0 +1,483 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// ErrFakeBadHandler means a handler given to FakeSession.AddHandler isn't an event handler
+	ErrFakeBadHandler = errors.New("handler must be a func(*discordgo.Session, *Event)")
+)
+
+// FakeSession is an in-memory Session for tests.
+//
+// Guilds, members, roles, channels and emoji are read from and written to
+// the session's discordgo.State, so tests seed it with State().GuildAdd etc.
+// Sent messages and reactions are recorded and can be checked afterwards.
+type FakeSession struct {
+	mu        sync.Mutex
+	state     *discordgo.State
+	nextID    int
+	sent      []*discordgo.Message
+	reactions map[string][]*discordgo.MessageReaction // indexed by message id
+	handlers  map[int]*fakeHandler
+	status    string
+	requests  []*FakeRequest
+}
+
+// FakeRequest is a raw request made through a FakeSession
+type FakeRequest struct {
+	Method string
+	URL    string
+	Data   interface{}
+}
+
+type fakeHandler struct {
+	fn  reflect.Value
+	typ reflect.Type
+}
+
+// NewFakeSession returns an empty FakeSession with a bot user of the given id.
+func NewFakeSession(botID string) *FakeSession {
+	state := discordgo.NewState()
+	state.User = &discordgo.User{
+		ID:       botID,
+		Username: "pcsocgo",
+		Bot:      true,
+	}
+	state.MaxMessageCount = 100
+
+	return &FakeSession{
+		state:     state,
+		reactions: make(map[string][]*discordgo.MessageReaction),
+		handlers:  make(map[int]*fakeHandler),
+	}
+}
+
+// newID returns a fresh snowflake-ish id, must be called with the lock held
+func (f *FakeSession) newID() string {
+	f.nextID++
+	return strconv.Itoa(f.nextID)
+}
+
+/* test helpers */
+
+// Messages returns all messages sent to the channel, oldest first.
+func (f *FakeSession) Messages(channelID string) []*discordgo.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := []*discordgo.Message{}
+	for _, m := range f.sent {
+		if m.ChannelID == channelID {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// LastMessage returns the last message sent to the channel, or nil if there is none.
+func (f *FakeSession) LastMessage(channelID string) *discordgo.Message {
+	msgs := f.Messages(channelID)
+	if len(msgs) == 0 {
+		return nil
+	}
+	return msgs[len(msgs)-1]
+}
+
+// Reactions returns the reactions currently on a message.
+func (f *FakeSession) Reactions(messageID string) []*discordgo.MessageReaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*discordgo.MessageReaction{}, f.reactions[messageID]...)
+}
+
+// Requests returns the raw requests made with RequestWithBucketID, oldest first.
+func (f *FakeSession) Requests() []*FakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*FakeRequest{}, f.requests...)
+}
+
+// Status returns the last status set with UpdateStatus.
+func (f *FakeSession) Status() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.status
+}
+
+// Emit synchronously calls every handler registered for the event's type.
+//
+// Handlers are given a nil *discordgo.Session, so they should use the
+// Session they were registered on instead.
+func (f *FakeSession) Emit(event interface{}) {
+	f.mu.Lock()
+	ids := []int{}
+	for id := range f.handlers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handlers := []*fakeHandler{}
+	for _, id := range ids {
+		handlers = append(handlers, f.handlers[id])
+	}
+	f.mu.Unlock()
+
+	ev := reflect.ValueOf(event)
+	for _, h := range handlers {
+		if !ev.Type().AssignableTo(h.typ) {
+			continue
+		}
+		h.fn.Call([]reflect.Value{reflect.Zero(reflect.TypeOf(&discordgo.Session{})), ev})
+	}
+}
+
+/* Session */
+
+// State implements Session
+func (f *FakeSession) State() *discordgo.State { return f.state }
+
+// ChannelMessage implements Session
+func (f *FakeSession) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	msg, err := f.state.Message(channelID, messageID)
+	if err == nil {
+		return msg, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range f.sent {
+		if m.ChannelID == channelID && m.ID == messageID {
+			return m, nil
+		}
+	}
+	return nil, discordgo.ErrStateNotFound
+}
+
+// ChannelMessageSend implements Session
+func (f *FakeSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return f.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+// ChannelMessageSendComplex implements Session
+func (f *FakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	msg := &discordgo.Message{
+		ChannelID: channelID,
+		Content:   data.Content,
+		Author:    f.state.User,
+		Embeds:    []*discordgo.MessageEmbed{},
+	}
+	if data.Embed != nil {
+		msg.Embeds = append(msg.Embeds, data.Embed)
+	}
+	files := data.Files
+	if data.File != nil {
+		files = append(files, data.File)
+	}
+	for _, file := range files {
+		msg.Attachments = append(msg.Attachments, &discordgo.MessageAttachment{
+			Filename: file.Name,
+		})
+	}
+	if cha, err := f.state.Channel(channelID); err == nil {
+		msg.GuildID = cha.GuildID
+	}
+
+	f.mu.Lock()
+	msg.ID = f.newID()
+	f.sent = append(f.sent, msg)
+	f.mu.Unlock()
+
+	// only tracked if the channel is in the state
+	f.state.MessageAdd(msg)
+	return msg, nil
+}
+
+// ChannelMessageSendEmbed implements Session
+func (f *FakeSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return f.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embed: embed})
+}
+
+// ChannelMessageEdit implements Session
+func (f *FakeSession) ChannelMessageEdit(channelID, messageID, content string) (*discordgo.Message, error) {
+	msg, err := f.ChannelMessage(channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg.Content = content
+	return msg, nil
+}
+
+// ChannelMessageEditComplex implements Session
+func (f *FakeSession) ChannelMessageEditComplex(edit *discordgo.MessageEdit) (*discordgo.Message, error) {
+	msg, err := f.ChannelMessage(edit.Channel, edit.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if edit.Content != nil {
+		msg.Content = *edit.Content
+	}
+	if edit.Embed != nil {
+		msg.Embeds = []*discordgo.MessageEmbed{edit.Embed}
+	}
+	return msg, nil
+}
+
+// ChannelMessageDelete implements Session, deleted messages are no longer in Messages
+func (f *FakeSession) ChannelMessageDelete(channelID, messageID string) error {
+	f.state.MessageRemove(&discordgo.Message{ID: messageID, ChannelID: channelID})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, m := range f.sent {
+		if m.ChannelID == channelID && m.ID == messageID {
+			f.sent = append(f.sent[:i], f.sent[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// ChannelTyping implements Session
+func (f *FakeSession) ChannelTyping(channelID string) error { return nil }
+
+// MessageReactionAdd implements Session, the reaction is made by the bot user
+func (f *FakeSession) MessageReactionAdd(channelID, messageID, emojiID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reactions[messageID] = append(f.reactions[messageID], &discordgo.MessageReaction{
+		UserID:    f.state.User.ID,
+		MessageID: messageID,
+		ChannelID: channelID,
+		Emoji:     discordgo.Emoji{Name: emojiID},
+	})
+	return nil
+}
+
+// MessageReactionRemove implements Session
+func (f *FakeSession) MessageReactionRemove(channelID, messageID, emojiID, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kept := []*discordgo.MessageReaction{}
+	for _, r := range f.reactions[messageID] {
+		if r.UserID == userID && r.Emoji.APIName() == emojiID {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	f.reactions[messageID] = kept
+	return nil
+}
+
+// MessageReactionsRemoveAll implements Session
+func (f *FakeSession) MessageReactionsRemoveAll(channelID, messageID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.reactions, messageID)
+	return nil
+}
+
+// UserGuilds implements Session, guilds are ordered by id and paged with afterID
+func (f *FakeSession) UserGuilds(limit int, beforeID, afterID string) ([]*discordgo.UserGuild, error) {
+	guilds := append([]*discordgo.Guild{}, f.state.Guilds...)
+	sort.Slice(guilds, func(i, j int) bool { return snowflakeLess(guilds[i].ID, guilds[j].ID) })
+
+	out := []*discordgo.UserGuild{}
+	for _, g := range guilds {
+		if len(afterID) > 0 && !snowflakeLess(afterID, g.ID) {
+			continue
+		}
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, &discordgo.UserGuild{
+			ID:   g.ID,
+			Name: g.Name,
+		})
+	}
+	return out, nil
+}
+
+// GuildMember implements Session
+func (f *FakeSession) GuildMember(guildID, userID string) (*discordgo.Member, error) {
+	return f.state.Member(guildID, userID)
+}
+
+// GuildMembers implements Session
+func (f *FakeSession) GuildMembers(guildID, after string, limit int) ([]*discordgo.Member, error) {
+	guild, err := f.state.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := append([]*discordgo.Member{}, guild.Members...)
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].User.ID < members[j].User.ID
+	})
+
+	out := []*discordgo.Member{}
+	for _, m := range members {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if m.User.ID > after {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// GuildMemberRoleAdd implements Session
+func (f *FakeSession) GuildMemberRoleAdd(guildID, userID, roleID string) error {
+	mem, err := f.state.Member(guildID, userID)
+	if err != nil {
+		return err
+	}
+	if _, err = f.state.Role(guildID, roleID); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range mem.Roles {
+		if r == roleID {
+			return nil
+		}
+	}
+	mem.Roles = append(mem.Roles, roleID)
+	return nil
+}
+
+// GuildMemberRoleRemove implements Session
+func (f *FakeSession) GuildMemberRoleRemove(guildID, userID, roleID string) error {
+	mem, err := f.state.Member(guildID, userID)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := []string{}
+	for _, r := range mem.Roles {
+		if r != roleID {
+			kept = append(kept, r)
+		}
+	}
+	mem.Roles = kept
+	return nil
+}
+
+// GuildRoles implements Session
+func (f *FakeSession) GuildRoles(guildID string) ([]*discordgo.Role, error) {
+	guild, err := f.state.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*discordgo.Role{}, guild.Roles...), nil
+}
+
+// GuildRoleDelete implements Session
+func (f *FakeSession) GuildRoleDelete(guildID, roleID string) error {
+	return f.state.RoleRemove(guildID, roleID)
+}
+
+// GuildEmojis implements Session
+func (f *FakeSession) GuildEmojis(guildID string) ([]*discordgo.Emoji, error) {
+	guild, err := f.state.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*discordgo.Emoji{}, guild.Emojis...), nil
+}
+
+// GuildChannels implements Session
+func (f *FakeSession) GuildChannels(guildID string) ([]*discordgo.Channel, error) {
+	guild, err := f.state.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*discordgo.Channel{}, guild.Channels...), nil
+}
+
+// Channel implements Session
+func (f *FakeSession) Channel(channelID string) (*discordgo.Channel, error) {
+	return f.state.Channel(channelID)
+}
+
+// User implements Session, users are looked up from guild members
+func (f *FakeSession) User(userID string) (*discordgo.User, error) {
+	if f.state.User.ID == userID {
+		return f.state.User, nil
+	}
+	for _, g := range f.state.Guilds {
+		if mem, err := f.state.Member(g.ID, userID); err == nil {
+			return mem.User, nil
+		}
+	}
+	return nil, discordgo.ErrStateNotFound
+}
+
+// AddHandler implements Session, handlers are only called by Emit
+func (f *FakeSession) AddHandler(handler interface{}) func() {
+	fn := reflect.ValueOf(handler)
+	ft := fn.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 2 || ft.In(0) != reflect.TypeOf(&discordgo.Session{}) {
+		panic(ErrFakeBadHandler)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := f.nextID
+	f.handlers[id] = &fakeHandler{fn, ft.In(1)}
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.handlers, id)
+	}
+}
+
+// UpdateStatus implements Session
+func (f *FakeSession) UpdateStatus(idle int, game string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.status = game
+	return nil
+}
+
+// RequestWithBucketID implements Session, the request is recorded and answered with a fresh id
+func (f *FakeSession) RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, &FakeRequest{method, urlStr, data})
+	return []byte(`{"id":"` + f.newID() + `"}`), nil
+}
+
+// snowflakeLess returns whether snowflake a is older than b
+func snowflakeLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
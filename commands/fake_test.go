@@ -0,0 +1,122 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+/* preamble */
+
+func newFake() *FakeSession {
+	ses := NewFakeSession("0")
+	ses.State().GuildAdd(&discordgo.Guild{
+		ID:   "1",
+		Name: "guild",
+		Roles: []*discordgo.Role{
+			&discordgo.Role{ID: "2", Name: "Mod"},
+		},
+		Channels: []*discordgo.Channel{
+			&discordgo.Channel{ID: "3", GuildID: "1", Name: "general"},
+		},
+		Members: []*discordgo.Member{
+			&discordgo.Member{GuildID: "1", User: &discordgo.User{ID: "4", Username: "bob"}},
+		},
+	})
+	return ses
+}
+
+/* tests */
+
+// TestFakeSend sends through a FakeSession
+// and verifies that the messages are recorded in order
+func TestFakeSend(t *testing.T) {
+	ses := newFake()
+
+	err := NewSimpleSend("3", "one").Message("two").Send(ses)
+	if err != nil {
+		t.Errorf("Send threw error: %v", err)
+	}
+
+	got := ses.Messages("3")
+	if len(got) != 2 {
+		t.Fatalf("Messages(\"3\") has %d messages; want 2", len(got))
+	}
+	if got[0].Content != "one" || got[1].Content != "two" {
+		t.Errorf("Messages(\"3\") = [%s, %s]; want [one, two]", got[0].Content, got[1].Content)
+	}
+	if got[0].GuildID != "1" {
+		t.Errorf("sent message has guild %s; want 1", got[0].GuildID)
+	}
+
+	// sent messages can be looked up and edited
+	_, err = ses.ChannelMessageEdit("3", got[1].ID, "three")
+	if err != nil {
+		t.Errorf("ChannelMessageEdit threw error: %v", err)
+	}
+	if ses.LastMessage("3").Content != "three" {
+		t.Errorf("LastMessage(\"3\") = %s; want three", ses.LastMessage("3").Content)
+	}
+}
+
+// TestFakeRoles toggles a role on a member
+func TestFakeRoles(t *testing.T) {
+	ses := newFake()
+
+	err := ses.GuildMemberRoleAdd("1", "4", "2")
+	if err != nil {
+		t.Errorf("GuildMemberRoleAdd threw error: %v", err)
+	}
+
+	mem, _ := ses.GuildMember("1", "4")
+	if len(mem.Roles) != 1 || mem.Roles[0] != "2" {
+		t.Errorf("member has roles %v; want [2]", mem.Roles)
+	}
+
+	err = ses.GuildMemberRoleRemove("1", "4", "2")
+	if err != nil {
+		t.Errorf("GuildMemberRoleRemove threw error: %v", err)
+	}
+	if len(mem.Roles) != 0 {
+		t.Errorf("member has roles %v; want []", mem.Roles)
+	}
+
+	// unknown roles are rejected
+	if ses.GuildMemberRoleAdd("1", "4", "42") == nil {
+		t.Errorf("GuildMemberRoleAdd with unknown role didn't throw error")
+	}
+
+	// guild lookups work through the state
+	cha, err := ses.Channel("3")
+	if err != nil || cha.Name != "general" {
+		t.Errorf("Channel(\"3\") = %v, %v; want general", cha, err)
+	}
+}
+
+// TestFakeEmit registers handlers and verifies that
+// Emit only calls handlers of the right type until they are removed
+func TestFakeEmit(t *testing.T) {
+	ses := newFake()
+
+	adds := 0
+	removes := 0
+	kill := ses.AddHandler(func(_ *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		adds++
+	})
+	ses.AddHandler(func(_ *discordgo.Session, r *discordgo.MessageReactionRemove) {
+		removes++
+	})
+
+	ses.Emit(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{}})
+	if adds != 1 || removes != 0 {
+		t.Errorf("Emit(MessageReactionAdd) called (%d, %d) handlers; want (1, 0)", adds, removes)
+	}
+
+	kill()
+	ses.Emit(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{}})
+	if adds != 1 {
+		t.Errorf("Emit called a removed handler")
+	}
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// userGuildsLimit is the most guilds discord will return per request
+	userGuildsLimit = 100
+)
+
+var (
+	guilds        = make(map[string]*GuildConfig) // indexed by guild id
+	guildPrefixes = make(map[string]string)       // indexed by guild id
+	guildsLock    = &sync.RWMutex{}
+)
+
+// GuildConfig is the configuration for a guild the bot is in
+type GuildConfig struct {
+	ID       string
+	Name     string
+	Channels map[string]string // channel ids by what they're for e.g. "log"
+}
+
+// Channel returns the id of the channel used for the purpose, empty if there isn't one
+func (g *GuildConfig) Channel(purpose string) string {
+	if g == nil {
+		return ""
+	}
+	guildsLock.RLock()
+	defer guildsLock.RUnlock()
+	return g.Channels[purpose]
+}
+
+// SetChannel sets the channel used for the purpose, an empty id unsets it
+func (g *GuildConfig) SetChannel(purpose string, channelID string) {
+	guildsLock.Lock()
+	defer guildsLock.Unlock()
+
+	if g.Channels == nil {
+		g.Channels = make(map[string]string)
+	}
+	if len(channelID) == 0 {
+		delete(g.Channels, purpose)
+		return
+	}
+	g.Channels[purpose] = channelID
+}
+
+// AddGuild adds or replaces the config for a guild
+func AddGuild(conf *GuildConfig) {
+	guildsLock.Lock()
+	defer guildsLock.Unlock()
+
+	if conf.Channels == nil {
+		conf.Channels = make(map[string]string)
+	}
+	guilds[conf.ID] = conf
+}
+
+// RemoveGuild forgets a guild's config
+func RemoveGuild(guildID string) {
+	guildsLock.Lock()
+	defer guildsLock.Unlock()
+	delete(guilds, guildID)
+}
+
+// GetGuild returns the config for a guild, nil if the bot isn't in it
+func GetGuild(guildID string) *GuildConfig {
+	guildsLock.RLock()
+	defer guildsLock.RUnlock()
+	return guilds[guildID]
+}
+
+// Guilds returns the configs of all the guilds the bot is in, ordered by id
+func Guilds() []*GuildConfig {
+	guildsLock.RLock()
+	defer guildsLock.RUnlock()
+
+	out := []*GuildConfig{}
+	for _, g := range guilds {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// InitGuilds adds configs for all the guilds the bot is in, and keeps them up to date as it joins and leaves guilds
+//
+// Guilds that already have a config keep it.
+// Guild is set to the first guild for anything that still expects only one.
+func InitGuilds(ses Session) error {
+	after := ""
+	all := []*discordgo.UserGuild{}
+	for {
+		page, err := ses.UserGuilds(userGuildsLimit, "", after)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if len(page) < userGuildsLimit {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+
+	for _, ug := range all {
+		if GetGuild(ug.ID) == nil {
+			AddGuild(&GuildConfig{ID: ug.ID, Name: ug.Name})
+		}
+	}
+	if len(all) > 0 {
+		Guild = all[0]
+	}
+
+	ses.AddHandler(func(_ *discordgo.Session, g *discordgo.GuildCreate) {
+		if GetGuild(g.ID) == nil {
+			AddGuild(&GuildConfig{ID: g.ID, Name: g.Name})
+		}
+	})
+	ses.AddHandler(func(_ *discordgo.Session, g *discordgo.GuildDelete) {
+		// unavailable guilds are only in an outage, not gone
+		if !g.Unavailable {
+			RemoveGuild(g.ID)
+		}
+	})
+	return nil
+}
+
+/* prefixes */
+
+// SetPrefix sets the prefix guilds use if they haven't set their own
+func SetPrefix(prefix string) {
+	guildsLock.Lock()
+	defer guildsLock.Unlock()
+	Prefix = prefix
+}
+
+// DefaultPrefix returns the prefix guilds use if they haven't set their own
+func DefaultPrefix() string {
+	guildsLock.RLock()
+	defer guildsLock.RUnlock()
+	return Prefix
+}
+
+// SetGuildPrefixes replaces the prefixes that guilds use instead of Prefix, indexed by guild id
+func SetGuildPrefixes(prefixes map[string]string) {
+	guildsLock.Lock()
+	defer guildsLock.Unlock()
+
+	guildPrefixes = make(map[string]string)
+	for gid, pre := range prefixes {
+		if len(pre) > 0 {
+			guildPrefixes[gid] = pre
+		}
+	}
+}
+
+// GuildPrefix returns the prefix the guild uses, Prefix if it hasn't set one
+func GuildPrefix(guildID string) string {
+	guildsLock.RLock()
+	defer guildsLock.RUnlock()
+
+	if pre, ok := guildPrefixes[guildID]; ok {
+		return pre
+	}
+	return Prefix
+}
+
+/* db namespacing */
+
+// GuildKey namespaces a db key by guild, so each guild has its own Storers
+//
+//	commands.DBGet(&tgs, commands.GuildKey(msg.GuildID, tagsKey), &tgs)
+func GuildKey(guildID string, key string) string {
+	return guildID + ":" + key
+}
+
+// DBMoveToGuild moves the Storer at key to the guild's namespace, from before the bot had more than one guild
+//
+// Does nothing if there's nothing at key or the guild already has something there.
+func DBMoveToGuild(s Storer, key string, guildID string) error {
+	if DB == nil {
+		return ErrDBNotOpen
+	}
+
+	from := s.Index() + ":" + key
+	to := s.Index() + ":" + GuildKey(guildID, key)
+	return DB.Update(func(tx StoreTx) error {
+		val, err := tx.Get(from)
+		if err == ErrDBNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Get(to)
+		if err == nil {
+			return nil
+		}
+		if err != ErrDBNotFound {
+			return err
+		}
+
+		err = tx.Set(to, val, 0)
+		if err != nil {
+			return err
+		}
+		return tx.Delete(from)
+	})
+}
@@ -0,0 +1,112 @@
+package commands_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestInitGuilds verifies that InitGuilds adds every guild, past the first page
+// and keeps up with guilds being joined and left
+func TestInitGuilds(t *testing.T) {
+	ses := NewFakeSession("0")
+	for i := 1; i <= 150; i++ {
+		ses.State().GuildAdd(&discordgo.Guild{ID: strconv.Itoa(i), Name: "guild"})
+	}
+	defer func() {
+		for _, g := range Guilds() {
+			RemoveGuild(g.ID)
+		}
+	}()
+
+	// configs set before init are kept
+	AddGuild(&GuildConfig{ID: "5", Channels: map[string]string{"log": "6"}})
+
+	err := InitGuilds(ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(Guilds()); n != 150 {
+		t.Fatalf("InitGuilds added %d guilds; want 150", n)
+	}
+	if Guild.ID != "1" {
+		t.Errorf("Guild is %s; want the first guild", Guild.ID)
+	}
+	if cha := GetGuild("5").Channel("log"); cha != "6" {
+		t.Errorf("InitGuilds replaced the config for guild 5, log channel is %q", cha)
+	}
+	if cha := GetGuild("404").Channel("log"); cha != "" {
+		t.Errorf("unknown guild has log channel %q; want none", cha)
+	}
+
+	ses.Emit(&discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "200", Name: "new"}})
+	if GetGuild("200") == nil {
+		t.Errorf("joining a guild didn't add it")
+	}
+
+	ses.Emit(&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: "200", Unavailable: true}})
+	if GetGuild("200") == nil {
+		t.Errorf("guild going unavailable removed it")
+	}
+
+	ses.Emit(&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: "200"}})
+	if GetGuild("200") != nil {
+		t.Errorf("leaving a guild didn't remove it")
+	}
+}
+
+// TestDBMoveToGuild moves a Storer into a guild's namespace, without overwriting the guild's own
+func TestDBMoveToGuild(t *testing.T) {
+	_, _, err := DBSet(&thing{"old", 1}, "move")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DBMoveToGuild(&thing{}, "move", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got thing
+	if err = DBGet(&thing{}, GuildKey("1", "move"), &got); err != nil || got.A != "old" {
+		t.Errorf("moved thing is %v, %v; want old", got, err)
+	}
+	if err = DBGet(&thing{}, "move", &got); err != ErrDBNotFound {
+		t.Errorf("old key still has a thing, got %v", err)
+	}
+
+	// the guild's thing wins
+	DBSet(&thing{"stale", 2}, "move")
+	err = DBMoveToGuild(&thing{}, "move", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = DBGet(&thing{}, GuildKey("1", "move"), &got); err != nil || got.A != "old" {
+		t.Errorf("moving over a guild's thing gave %v, %v; want old", got, err)
+	}
+}
+
+// TestSetPrefix changes the default prefix while it's being read, see go test -race
+func TestSetPrefix(t *testing.T) {
+	defer SetPrefix("!")
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			GuildPrefix("1")
+			GetUsage(&Say{})
+		}
+	}()
+	SetPrefix("?")
+	wg.Wait()
+
+	if pre := GuildPrefix("1"); pre != "?" || DefaultPrefix() != "?" {
+		t.Errorf("GuildPrefix after SetPrefix(?) = %q; want ?", pre)
+	}
+}
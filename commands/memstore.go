@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errReadOnly = errors.New("can't change the db in a read only transaction")
+
+// memoryItem is a value in a memoryStore
+type memoryItem struct {
+	val     string
+	expires time.Time // zero if it never expires
+}
+
+func (m *memoryItem) expired(now time.Time) bool {
+	return !m.expires.IsZero() && !now.Before(m.expires)
+}
+
+// memoryStore implements the Store interface with a map, for tests and bots that don't keep anything
+//
+// Nothing is saved, the path it's opened with is ignored.
+type memoryStore struct {
+	items map[string]*memoryItem
+	lock  *sync.RWMutex
+}
+
+func openMemoryStore(path string) (Store, error) {
+	return &memoryStore{
+		items: make(map[string]*memoryItem),
+		lock:  &sync.RWMutex{},
+	}, nil
+}
+
+func (m *memoryStore) View(view func(tx StoreTx) error) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return view(&memoryTx{store: m, now: time.Now()})
+}
+
+func (m *memoryStore) Update(update func(tx StoreTx) error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	tx := &memoryTx{
+		store:    m,
+		now:      time.Now(),
+		writable: true,
+		undo:     make(map[string]*memoryItem),
+	}
+	err := update(tx)
+	if err != nil {
+		// put back everything it changed
+		for key, item := range tx.undo {
+			if item == nil {
+				delete(m.items, key)
+			} else {
+				m.items[key] = item
+			}
+		}
+	}
+	return err
+}
+
+func (m *memoryStore) Close() error { return nil }
+
+// memoryTx implements the StoreTx interface
+type memoryTx struct {
+	store    *memoryStore
+	now      time.Time
+	writable bool
+	undo     map[string]*memoryItem // what was at each key before the transaction changed it, nil if nothing
+}
+
+func (m *memoryTx) get(key string) *memoryItem {
+	item, ok := m.store.items[key]
+	if !ok || item.expired(m.now) {
+		return nil
+	}
+	return item
+}
+
+// change remembers what's at the key so it can be put back
+func (m *memoryTx) change(key string) {
+	if _, ok := m.undo[key]; !ok {
+		m.undo[key] = m.store.items[key]
+	}
+}
+
+func (m *memoryTx) Get(key string) (string, error) {
+	item := m.get(key)
+	if item == nil {
+		return "", ErrDBNotFound
+	}
+	return item.val, nil
+}
+
+func (m *memoryTx) Set(key string, val string, ttl time.Duration) error {
+	if !m.writable {
+		return errReadOnly
+	}
+	item := &memoryItem{val: val}
+	if ttl > 0 {
+		item.expires = m.now.Add(ttl)
+	}
+	m.change(key)
+	m.store.items[key] = item
+	return nil
+}
+
+func (m *memoryTx) Delete(key string) error {
+	if !m.writable {
+		return errReadOnly
+	}
+	if m.get(key) == nil {
+		return ErrDBNotFound
+	}
+	m.change(key)
+	delete(m.store.items, key)
+	return nil
+}
+
+func (m *memoryTx) TTL(key string) (time.Duration, error) {
+	item := m.get(key)
+	if item == nil {
+		return 0, ErrDBNotFound
+	}
+	if item.expires.IsZero() {
+		return 0, nil
+	}
+	return item.expires.Sub(m.now), nil
+}
+
+func (m *memoryTx) AscendPrefix(prefix string, iter func(key, val string) bool) error {
+	keys := []string{}
+	for key := range m.store.items {
+		if strings.HasPrefix(key, prefix) && m.get(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		item, ok := m.store.items[key]
+		if ok && !iter(key, item.val) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,428 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+// Context is a single command invocation as it goes through a middleware chain
+//
+// Middleware fill it in as they go, e.g. Command and Args are set by Route.
+type Context struct {
+	Session Session
+	Message *discordgo.Message
+	Prefix  string            // the prefix used in the guild, set by Parse
+	Tokens  *Tokens           // the message without its prefix, set by Parse
+	Command Command           // the command being called, set by Route
+	Args    *Tokens           // the args after the command's name, set by Route
+	Options map[string]string // slash command options, used instead of Args if set
+	Send    *CommandSend      // what the command returned, set by Call
+}
+
+// Handler handles a command invocation
+type Handler func(*Context) error
+
+// Middleware wraps a Handler, doing things before and/or after calling next.
+// Middleware can stop the chain by returning without calling next.
+type Middleware func(next Handler) Handler
+
+// suggestInterval is how often DefaultMiddleware suggests commands in a channel
+const suggestInterval = 30 * time.Second
+
+// Logf is a printf-style logging func e.g. log.Printf
+type Logf func(format string, v ...interface{})
+
+// RouteFunc finds the command for a message's args, returning the number of args that named it
+type RouteFunc func(argv []string) (Command, int)
+
+// GuildRouteFunc finds one of the guild's own commands for a message's args e.g. a custom command,
+// returning the number of args that named it
+type GuildRouteFunc func(guildID string, argv []string) (Command, int)
+
+// UsageError means the args given to a command couldn't fill it
+type UsageError struct {
+	Command Command
+	Err     error
+	Prefix  string // shown in the usage, Prefix if empty
+}
+
+func (e *UsageError) Error() string {
+	if len(e.Prefix) == 0 {
+		return "Usage: " + GetUsage(e.Command)
+	}
+	return "Usage: " + GetUsagePrefix(e.Command, e.Prefix)
+}
+
+// CheckError means the user can't call the command here, Reason is shown to the user
+type CheckError struct {
+	Reason string
+}
+
+func (e *CheckError) Error() string {
+	return e.Reason
+}
+
+// Chain wraps the handler in the middleware, with the first middleware on the outside
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Dispatcher sends messages through a middleware chain to commands
+type Dispatcher struct {
+	handler Handler
+}
+
+// NewDispatcher returns a Dispatcher that runs the middleware then calls the command with Call
+func NewDispatcher(mws ...Middleware) *Dispatcher {
+	return &Dispatcher{Chain(Call, mws...)}
+}
+
+// Dispatch sends a message through the chain
+func (d *Dispatcher) Dispatch(ses Session, msg *discordgo.Message) error {
+	return d.handler(&Context{
+		Session: ses,
+		Message: msg,
+	})
+}
+
+// DefaultMiddleware returns the middleware pcsocgo has always used, in order:
+//
+//	ReplyErrors, Parse, DidYouMean, RouteGuild, Route, CheckEnabled, CheckChannels, CheckRoles, Remember, Fill, Cooldown, Log, Typing
+//
+// guild can be nil if guilds don't have their own commands, suggest can be nil to not suggest commands.
+func DefaultMiddleware(route RouteFunc, guild GuildRouteFunc, suggest SuggestFunc, hist *History, cds *Cooldowns, logs Logf, errs Logf) []Middleware {
+	return []Middleware{
+		ReplyErrors(errs),
+		Parse(""),
+		DidYouMean(suggest, suggestInterval),
+		RouteGuild(guild),
+		Route(route, hist),
+		CheckEnabled(route),
+		CheckChannels,
+		CheckRoles,
+		Remember(hist),
+		Fill,
+		Cooldown(cds),
+		Log(logs),
+		Typing,
+	}
+}
+
+/* handlers and middleware */
+
+// Call is the end of the chain, it calls the command's handler and sends what it returns
+func Call(ctx *Context) error {
+	snd, err := ctx.Command.MsgHandle(ctx.Session, ctx.Message)
+	if err != nil {
+		return err
+	}
+
+	ctx.Send = snd
+	if snd != nil {
+		return snd.Send(ctx.Session)
+	}
+	return nil
+}
+
+// ReplyErrors replies to the user with errors from the rest of the chain and logs them to errs
+//
+// Usage errors are shown with the command's usage, other errors in italics.
+func ReplyErrors(errs Logf) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			err := next(ctx)
+			if err == nil {
+				return nil
+			}
+
+			switch e := err.(type) {
+			case *UsageError:
+				usage := e.Error()
+				if argErr, ok := e.Err.(*ArgError); ok {
+					usage = utils.Italics("Error: "+argErr.Error()) + "\n" + usage
+				}
+				ctx.Session.ChannelMessageSend(ctx.Message.ChannelID, usage)
+				errs("Usage error on command %#v: %#v\n", ctx.Command, e.Err)
+			default:
+				ctx.Session.ChannelMessageSend(ctx.Message.ChannelID, utils.Italics("Error: "+err.Error()))
+				errs("%#v threw error: %#v\n", ctx.Command, err)
+			}
+			return err
+		}
+	}
+}
+
+// Recover catches panics from the rest of the chain and logs them to errs
+func Recover(errs Logf) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					errs("Caught panic: %#v\n", r)
+					err = fmt.Errorf("caught panic: %v", r)
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// Parse ignores bots and messages without the prefix or a mention of the bot, and tokenizes the rest
+//
+// Contexts that already have Tokens (e.g. slash commands) aren't parsed again.
+// An empty prefix uses the guild's prefix from GuildPrefix, as it is when the message comes in.
+func Parse(prefix string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			m := ctx.Message
+			ctx.Prefix = prefix
+			if len(ctx.Prefix) == 0 {
+				ctx.Prefix = GuildPrefix(m.GuildID)
+			}
+
+			self := ctx.Session.State().User.ID
+			if m.Author == nil || m.Author.ID == self || m.Author.Bot {
+				return nil
+			}
+			if ctx.Tokens != nil {
+				return next(ctx)
+			}
+
+			trm := strings.TrimSpace(m.Content)
+			rest, ok := trimMention(trm, self)
+			if !ok {
+				if !strings.HasPrefix(trm, ctx.Prefix) {
+					return nil
+				}
+				rest = trm[len(ctx.Prefix):]
+			}
+			if len(strings.TrimSpace(rest)) == 0 {
+				return nil
+			}
+
+			ctx.Tokens = Tokenize(rest)
+			if len(ctx.Tokens.Args) == 0 {
+				return nil
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// trimMention returns the content after a leading mention of the user, and whether it had one
+func trimMention(content string, userID string) (string, bool) {
+	for _, men := range []string{"<@" + userID + ">", "<@!" + userID + ">"} {
+		if strings.HasPrefix(content, men) {
+			return strings.TrimSpace(content[len(men):]), true
+		}
+	}
+	return "", false
+}
+
+// RouteGuild finds the message's guild's own command with route, leaving the rest for Route
+//
+// A nil route does nothing.
+func RouteGuild(route GuildRouteFunc) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			if route == nil || ctx.Command != nil || ctx.Tokens.Args[0] == ctx.Prefix {
+				return next(ctx)
+			}
+
+			com, ind := route(ctx.Message.GuildID, ctx.Tokens.Args)
+			if com != nil {
+				ctx.Command = Clone(com)
+				ctx.Args = ctx.Tokens.From(ind)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Route finds the command with route, or the user's last command from hist for !!
+//
+// !! is the prefix twice, e.g. ?? in a guild whose prefix is ?.
+// Commands are cloned so that concurrent calls don't share args.
+// Contexts that already have a command, e.g. from RouteGuild, aren't routed again.
+// Messages that don't name a command are ignored.
+func Route(route RouteFunc, hist *History) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			if ctx.Command != nil {
+				return next(ctx)
+			}
+
+			var com Command
+			var ind int
+			if ctx.Tokens.Args[0] == ctx.Prefix {
+				// !! args...
+				com = hist.Get(ctx.Message.Author.ID)
+				ind = 1
+			} else {
+				com, ind = route(ctx.Tokens.Args)
+			}
+			if com == nil {
+				return nil
+			}
+
+			// get a fresh instance so concurrent calls don't share args
+			ctx.Command = Clone(com)
+			ctx.Args = ctx.Tokens.From(ind)
+			return next(ctx)
+		}
+	}
+}
+
+// CheckChannels stops commands being called outside their channels, or in channels the guild's rules deny
+func CheckChannels(next Handler) Handler {
+	return func(ctx *Context) error {
+		rul := commandRules(ctx)
+		den, err := InChannels(ctx.Session, ctx.Message, rul.DenyChans)
+		if err != nil {
+			return err
+		}
+		if len(rul.DenyChans) > 0 && den {
+			return &CheckError{"This command can't be used in this channel"}
+		}
+
+		alw, err := InChannels(ctx.Session, ctx.Message, rul.AllowChans)
+		if err != nil {
+			return err
+		}
+		if len(rul.AllowChans) > 0 && alw {
+			return next(ctx)
+		}
+
+		chans := ctx.Command.Chans()
+		has, err := InChannels(ctx.Session, ctx.Message, chans)
+		if err != nil {
+			return err
+		}
+		if !has {
+			out := "You must be in " + utils.Code(chans[0])
+			for _, oth := range chans[1:] {
+				out += " or " + utils.Code(oth)
+			}
+			return &CheckError{out + " to use this command"}
+		}
+		return next(ctx)
+	}
+}
+
+// CheckRoles stops commands being called by users without their roles, or with roles the guild's rules deny
+func CheckRoles(next Handler) Handler {
+	return func(ctx *Context) error {
+		rul := commandRules(ctx)
+		den, err := HasRoles(ctx.Session, ctx.Message, rul.DenyRoles)
+		if err != nil {
+			return err
+		}
+		if len(rul.DenyRoles) > 0 && den {
+			return &CheckError{"You aren't allowed to use this command"}
+		}
+
+		alw, err := HasRoles(ctx.Session, ctx.Message, rul.AllowRoles)
+		if err != nil {
+			return err
+		}
+		if len(rul.AllowRoles) > 0 && alw {
+			return next(ctx)
+		}
+
+		roles := ctx.Command.Roles()
+		has, err := HasRoles(ctx.Session, ctx.Message, roles)
+		if err != nil {
+			return err
+		}
+		if !has {
+			out := "You must be a " + utils.Code(roles[0])
+			for _, oth := range roles[1:] {
+				out += " or a " + utils.Code(oth)
+			}
+			return &CheckError{out + " to use this command"}
+		}
+		return next(ctx)
+	}
+}
+
+// Remember saves the command in hist for !!, before its args are checked
+//
+// hist gets its own copy, since the rest of the chain fills ctx.Command while !! may be cloning it.
+func Remember(hist *History) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			hist.Set(ctx.Message.Author.ID, Clone(ctx.Command))
+			return next(ctx)
+		}
+	}
+}
+
+// Fill fills the command's args or options, returning a *UsageError if they don't fit
+func Fill(next Handler) Handler {
+	return func(ctx *Context) error {
+		var err error
+		if ctx.Options != nil {
+			err = FillOptions(ctx.Session, ctx.Message, ctx.Command, ctx.Options)
+		} else {
+			err = FillTokens(ctx.Session, ctx.Message, ctx.Command, ctx.Args)
+		}
+		if err != nil {
+			return &UsageError{Command: ctx.Command, Err: err, Prefix: ctx.Prefix}
+		}
+		return next(ctx)
+	}
+}
+
+// Log logs commands before they're called
+func Log(logs Logf) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			logs("Calling command handler: %s%s %+v", ctx.Prefix, ctx.Command.Aliases()[0], ctx.Command)
+			return next(ctx)
+		}
+	}
+}
+
+// Typing shows the typing indicator while the command runs
+func Typing(next Handler) Handler {
+	return func(ctx *Context) error {
+		ctx.Session.ChannelTyping(ctx.Message.ChannelID)
+		return next(ctx)
+	}
+}
+
+/* !! */
+
+// History remembers each user's last command for !!
+type History struct {
+	mu   sync.Mutex
+	last map[string]Command // indexed by user id
+}
+
+// NewHistory returns an empty History
+func NewHistory() *History {
+	return &History{last: make(map[string]Command)}
+}
+
+// Get returns the user's last command, or nil if they haven't used one
+func (h *History) Get(userID string) Command {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last[userID]
+}
+
+// Set sets the user's last command
+func (h *History) Set(userID string, com Command) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last[userID] = com
+}
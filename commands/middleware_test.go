@@ -0,0 +1,276 @@
+package commands_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+/* preamble */
+
+type Say struct {
+	roles []string
+	Times int    `arg:"times"`
+	Words string `arg:"words" rest:"true"`
+}
+
+func (s *Say) Aliases() []string { return []string{"say"} }
+
+func (s *Say) Desc() string { return "Say!" }
+
+func (s *Say) Subcommands() []Command { return nil }
+
+func (s *Say) Roles() []string { return s.roles }
+
+func (s *Say) Chans() []string { return nil }
+
+func (s *Say) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	if s.Words == "panic" {
+		panic("said panic")
+	}
+	return NewSimpleSend(msg.ChannelID, strings.Repeat(s.Words, s.Times)), nil
+}
+
+// newSayRoute routes !say to a Say command with the roles, and !modsay to one needing mod
+func newSayRoute(roles []string) RouteFunc {
+	return func(argv []string) (Command, int) {
+		switch argv[0] {
+		case "say":
+			return &Say{roles: roles}, 1
+		case "modsay":
+			return &Say{roles: []string{"mod"}}, 1
+		}
+		return nil, 0
+	}
+}
+
+// dispatch sends content from the user through the dispatcher
+func dispatch(dis *Dispatcher, ses *FakeSession, userID string, bot bool, content string) error {
+	return dis.Dispatch(ses, &discordgo.Message{
+		ID:        "100",
+		ChannelID: "3",
+		GuildID:   "1",
+		Content:   content,
+		Author:    &discordgo.User{ID: userID, Bot: bot},
+	})
+}
+
+/* tests */
+
+// TestChain verifies that the first middleware runs first and can stop the chain
+func TestChain(t *testing.T) {
+	got := ""
+	mw := func(name string, stop bool) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx *Context) error {
+				got += name
+				if stop {
+					return nil
+				}
+				return next(ctx)
+			}
+		}
+	}
+	end := func(ctx *Context) error {
+		got += "end"
+		return nil
+	}
+
+	Chain(end, mw("a", false), mw("b", false))(&Context{})
+	if got != "abend" {
+		t.Errorf("Chain ran %q; want %q", got, "abend")
+	}
+
+	got = ""
+	Chain(end, mw("a", true), mw("b", false))(&Context{})
+	if got != "a" {
+		t.Errorf("stopped Chain ran %q; want %q", got, "a")
+	}
+}
+
+// TestDispatch sends messages through the default middleware and verifies that
+// - Bots and messages without the prefix are ignored
+// - Commands are called and reply
+// - Bad args reply with usage, and missing roles reply with an error
+// - !! calls the user's last command with new args
+func TestDispatch(t *testing.T) {
+	ses := newFake()
+	logs := []string{}
+	logf := func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+
+	tests := []struct {
+		user    string
+		bot     bool
+		content string
+		exp     string // last message after, empty for none
+		err     bool
+	}{
+		{"4", false, "say 2 hi", "", false},
+		{"4", true, Prefix + "say 2 hi", "", false},
+		{"4", false, Prefix + "nothing", "", false},
+		{"4", false, Prefix + "say 2 hi", "hihi", false},
+		{"4", false, Prefix + "say two hi", "*Error: `two` is not a valid", true},
+		{"4", false, Prefix + "modsay 1 hi", "*Error: You must be a `mod` to use this command*", true},
+		{"4", false, Prefix + "! 3 ho", "hohoho", false},
+	}
+	for _, tt := range tests {
+		before := len(ses.Messages("3"))
+		err := dispatch(dis, ses, tt.user, tt.bot, tt.content)
+		if (err != nil) != tt.err {
+			t.Errorf("Dispatch(%q) threw error: %v", tt.content, err)
+		}
+
+		msgs := ses.Messages("3")
+		if tt.exp == "" {
+			if len(msgs) != before {
+				t.Errorf("Dispatch(%q) sent %q; want nothing", tt.content, msgs[len(msgs)-1].Content)
+			}
+			continue
+		}
+		if len(msgs) == before {
+			t.Errorf("Dispatch(%q) sent nothing; want %q", tt.content, tt.exp)
+			continue
+		}
+		if got := msgs[len(msgs)-1].Content; !strings.HasPrefix(got, tt.exp) {
+			t.Errorf("Dispatch(%q) sent %q; want %q", tt.content, got, tt.exp)
+		}
+	}
+
+	// usage is shown with bad args
+	last := ses.Messages("3")
+	if !strings.Contains(last[len(last)-3].Content, GetUsage(&Say{})) {
+		t.Errorf("bad args didn't reply with usage, sent %q", last[len(last)-3].Content)
+	}
+	if len(logs) == 0 {
+		t.Errorf("Dispatch didn't log anything")
+	}
+}
+
+// TestDispatchReplay calls !! concurrently, which shouldn't share the remembered command, see go test -race
+func TestDispatchReplay(t *testing.T) {
+	ses := newFake()
+	logf := func(format string, v ...interface{}) {}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+
+	dispatch(dis, ses, "4", false, Prefix+"say 1 hi")
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := dispatch(dis, ses, "4", false, fmt.Sprintf("%s! %d ho", Prefix, i+1))
+			if err != nil {
+				t.Errorf("concurrent !! threw error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(ses.Messages("3")); got != 9 {
+		t.Errorf("concurrent !! sent %d messages; want 9", got)
+	}
+}
+
+// TestRouteGuild verifies that guilds' own commands are only routed in those guilds, before the others
+func TestRouteGuild(t *testing.T) {
+	ses := newFake()
+	logf := func(format string, v ...interface{}) {}
+	guild := func(guildID string, argv []string) (Command, int) {
+		if guildID == "1" && (argv[0] == "hey" || argv[0] == "say") {
+			return &Say{Words: "guild"}, 1
+		}
+		return nil, 0
+	}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), guild, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+
+	send := func(guildID string, content string) string {
+		before := len(ses.Messages("3"))
+		dis.Dispatch(ses, &discordgo.Message{
+			ID: "100", ChannelID: "3", GuildID: guildID, Content: content, Author: &discordgo.User{ID: "4"},
+		})
+		msgs := ses.Messages("3")
+		if len(msgs) == before {
+			return ""
+		}
+		return msgs[len(msgs)-1].Content
+	}
+
+	if got := send("1", Prefix+"hey 2 a"); got != "aa" {
+		t.Errorf("guild's own !hey sent %q; want aa", got)
+	}
+	if got := send("2", Prefix+"hey 2 a"); got != "" {
+		t.Errorf("another guild's !hey sent %q; want nothing", got)
+	}
+	if got := send("2", Prefix+"say 1 b"); got != "b" {
+		t.Errorf("!say in a guild without its own sent %q; want b", got)
+	}
+}
+
+// TestDispatchPrefix verifies that guilds can use their own prefix, and mentioning the bot works as one
+func TestDispatchPrefix(t *testing.T) {
+	ses := newFake()
+	logf := func(format string, v ...interface{}) {}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+
+	SetGuildPrefixes(map[string]string{"1": "?"})
+	defer SetGuildPrefixes(nil)
+	if GuildPrefix("2") != Prefix {
+		t.Errorf("GuildPrefix of a guild without one is %q; want %q", GuildPrefix("2"), Prefix)
+	}
+
+	tests := []struct {
+		content string
+		exp     string // last message after, empty for none
+	}{
+		{"?say 1 a", "a"},
+		{"?? 2 a", "aa"},
+		{Prefix + "say 1 b", ""},
+		{"<@0> say 1 c", "c"},
+		{"<@!0>say 1 d", "d"},
+		{"<@0>", ""},
+		{"<@5> say 1 e", ""},
+		{"?say one f", "*Error: `one` is not a valid"},
+	}
+	for _, tt := range tests {
+		before := len(ses.Messages("3"))
+		dispatch(dis, ses, "4", false, tt.content)
+
+		msgs := ses.Messages("3")
+		if tt.exp == "" {
+			if len(msgs) != before {
+				t.Errorf("Dispatch(%q) sent %q; want nothing", tt.content, msgs[len(msgs)-1].Content)
+			}
+			continue
+		}
+		if len(msgs) == before || !strings.HasPrefix(msgs[len(msgs)-1].Content, tt.exp) {
+			t.Errorf("Dispatch(%q) didn't send %q", tt.content, tt.exp)
+		}
+	}
+
+	// usage uses the guild's prefix
+	last := ses.Messages("3")
+	if !strings.Contains(last[len(last)-1].Content, GetUsagePrefix(&Say{}, "?")) {
+		t.Errorf("usage didn't use the guild's prefix, sent %q", last[len(last)-1].Content)
+	}
+}
+
+// TestRecover verifies that Recover stops panics and returns them as errors
+func TestRecover(t *testing.T) {
+	ses := newFake()
+	logf := func(format string, v ...interface{}) {}
+	mws := append([]Middleware{Recover(logf)}, DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+	dis := NewDispatcher(mws...)
+
+	err := dispatch(dis, ses, "4", false, Prefix+"say 1 panic")
+	if err == nil || !strings.Contains(err.Error(), "said panic") {
+		t.Errorf("Dispatch of a panicking command threw error: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	keySchema = "version"
+)
+
+var (
+	// ErrMigrationVersion means a migration's version is below 1 or already taken
+	ErrMigrationVersion = errors.New("migration version must be positive and not taken")
+	// ErrMigrationNil means a migration has no Up func
+	ErrMigrationNil = errors.New("migration has no up func")
+	// ErrDBTooNew means the db has been migrated past the migrations this bot knows
+	ErrDBTooNew = errors.New("db schema is newer than this bot, update it")
+
+	// DBDryRun makes DBOpen try the migrations the db needs then roll them back, leaving the db as it was
+	DBDryRun bool
+	// MigrateLogf logs what DBOpen migrates, nothing if it's nil
+	MigrateLogf Logf
+
+	errDryRun = errors.New("dry run")
+
+	migrations     = make(map[int]*Migration) // indexed by version
+	migrationsLock = &sync.RWMutex{}
+)
+
+// Migration changes what's stored in the db from the version before it to its Version
+//
+// Migrations run once each, oldest first, so changes to a Storer can ship as one instead of a program.
+type Migration struct {
+	Version int
+	Desc    string
+	Up      func(tx *DBTx) error // everything it does is undone if it returns an error
+}
+
+// schema implements the Storer interface, it's the version of everything else in the db
+type schema struct {
+	Version int
+}
+
+func (s *schema) Index() string { return "schema" }
+
+// AddMigration adds a migration for DBOpen to run on dbs older than it
+func AddMigration(m *Migration) error {
+	if m.Up == nil {
+		return ErrMigrationNil
+	}
+
+	migrationsLock.Lock()
+	defer migrationsLock.Unlock()
+
+	if _, ok := migrations[m.Version]; ok || m.Version < 1 {
+		return ErrMigrationVersion
+	}
+	migrations[m.Version] = m
+	return nil
+}
+
+// Migrations returns every migration, oldest first
+func Migrations() []*Migration {
+	migrationsLock.RLock()
+	defer migrationsLock.RUnlock()
+
+	migs := []*Migration{}
+	for _, m := range migrations {
+		migs = append(migs, m)
+	}
+	sort.Slice(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+	return migs
+}
+
+// DBVersion returns the version of the db, 0 if it has never been migrated
+func DBVersion() (int, error) {
+	var sch schema
+	err := DBGet(&sch, keySchema, &sch)
+	if err == ErrDBNotFound {
+		return 0, nil
+	}
+	return sch.Version, err
+}
+
+// DBPending returns the migrations the db hasn't had yet, oldest first
+func DBPending() ([]*Migration, error) {
+	ver, err := DBVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	migs := Migrations()
+	if len(migs) > 0 && ver > migs[len(migs)-1].Version {
+		return nil, ErrDBTooNew
+	}
+
+	pen := []*Migration{}
+	for _, m := range migs {
+		if m.Version > ver {
+			pen = append(pen, m)
+		}
+	}
+	return pen, nil
+}
+
+// DBMigrate runs the migrations the db hasn't had yet, each in its own transaction with the version it leaves the db at
+//
+// A dry run runs them all in one transaction then rolls it back, so only errors are seen.
+// Returns the migrations that were (or would have been) run before any error.
+func DBMigrate(dry bool) ([]*Migration, error) {
+	pen, err := DBPending()
+	if err != nil {
+		return nil, err
+	}
+
+	ran := []*Migration{}
+	up := func(tx *DBTx, m *Migration) error {
+		if err := m.Up(tx); err != nil {
+			return fmt.Errorf("migration %d (%s): %v", m.Version, m.Desc, err)
+		}
+		if err := tx.Set(&schema{m.Version}, keySchema); err != nil {
+			return err
+		}
+		ran = append(ran, m)
+		return nil
+	}
+
+	if dry {
+		err = DBTransact(func(tx *DBTx) error {
+			for _, m := range pen {
+				if err := up(tx, m); err != nil {
+					return err
+				}
+			}
+			return errDryRun
+		})
+		if err == errDryRun {
+			err = nil
+		}
+		return ran, err
+	}
+
+	for _, m := range pen {
+		err = DBTransact(func(tx *DBTx) error {
+			return up(tx, m)
+		})
+		if err != nil {
+			return ran, err
+		}
+	}
+	return ran, nil
+}
+
+// DBBackup saves a copy of the db to the file at path, in the same Store as the db
+func DBBackup(path string) error {
+	if DB == nil {
+		return ErrDBNotOpen
+	}
+
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	bak, err := OpenStore(dbStore, path)
+	if err != nil {
+		return err
+	}
+	_, err = CopyStore(bak, DB)
+	if cerr := bak.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// migrateOnOpen runs the migrations the db at path needs, backing it up first unless it's in memory or a dry run
+func migrateOnOpen(path string) error {
+	pen, err := DBPending()
+	if err != nil || len(pen) == 0 {
+		return err
+	}
+
+	logf := MigrateLogf
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+
+	if !DBDryRun && path != ":memory:" && dbStore != "memory" {
+		ver, _ := DBVersion()
+		bak := fmt.Sprintf("%s.v%d.bak", path, ver)
+		if err = DBBackup(bak); err != nil {
+			return err
+		}
+		logf("Backed up the db to %s", bak)
+	}
+
+	ran, err := DBMigrate(DBDryRun)
+	for _, m := range ran {
+		if DBDryRun {
+			logf("Would migrate the db to version %d: %s", m.Version, m.Desc)
+		} else {
+			logf("Migrated the db to version %d: %s", m.Version, m.Desc)
+		}
+	}
+	return err
+}
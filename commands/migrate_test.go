@@ -0,0 +1,104 @@
+package commands_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestMigrate dry runs two migrations, runs them, then runs one that fails
+func TestMigrate(t *testing.T) {
+	up := func(name string) func(tx *DBTx) error {
+		return func(tx *DBTx) error {
+			return tx.Set(&thing{A: name}, "migrated")
+		}
+	}
+
+	err := AddMigration(&Migration{Version: 2, Desc: "second", Up: up("second")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddMigration(&Migration{Version: 1, Desc: "first", Up: up("first")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = AddMigration(&Migration{Version: 1, Up: up("again")}); err != ErrMigrationVersion {
+		t.Errorf("AddMigration with a taken version gave %v; want %v", err, ErrMigrationVersion)
+	}
+	if err = AddMigration(&Migration{Version: 3}); err != ErrMigrationNil {
+		t.Errorf("AddMigration without Up gave %v; want %v", err, ErrMigrationNil)
+	}
+
+	ran, err := DBMigrate(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0].Desc != "first" {
+		t.Errorf("dry run ran %v; want first then second", ran)
+	}
+	if ver, _ := DBVersion(); ver != 0 {
+		t.Errorf("dry run left the db at version %d; want 0", ver)
+	}
+	if err = DBGet(&thing{}, "migrated", &thing{}); err != ErrDBNotFound {
+		t.Errorf("dry run left a thing, got %v", err)
+	}
+
+	ran, err = DBMigrate(false)
+	if err != nil || len(ran) != 2 {
+		t.Fatalf("DBMigrate ran %v, %v; want 2 migrations", ran, err)
+	}
+	var got thing
+	DBGet(&got, "migrated", &got)
+	if ver, _ := DBVersion(); ver != 2 || got.A != "second" {
+		t.Errorf("DBMigrate left version %d and %q; want 2 and second", ver, got.A)
+	}
+
+	// nothing left to do
+	ran, err = DBMigrate(false)
+	if err != nil || len(ran) != 0 {
+		t.Errorf("DBMigrate again ran %v, %v; want nothing", ran, err)
+	}
+
+	// failures are undone
+	bad := errors.New("bad")
+	AddMigration(&Migration{Version: 3, Desc: "bad", Up: func(tx *DBTx) error {
+		tx.Set(&thing{A: "bad"}, "migrated")
+		return bad
+	}})
+	if _, err = DBMigrate(false); err == nil {
+		t.Errorf("DBMigrate with a bad migration didn't fail")
+	}
+	DBGet(&got, "migrated", &got)
+	if ver, _ := DBVersion(); ver != 2 || got.A != "second" {
+		t.Errorf("failed migration left version %d and %q; want 2 and second", ver, got.A)
+	}
+}
+
+// TestDBBackup saves the db to a file
+func TestDBBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pcsocgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	DBSet(&thing{A: "backed up"}, "backup")
+	bak := filepath.Join(dir, "bot.db.bak")
+	err = DBBackup(bak)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(bak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "backed up") {
+		t.Errorf("DBBackup wrote %q", got)
+	}
+}
@@ -0,0 +1,356 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// DefaultPageTimeout is how long a Paginator's controls last without being used
+	DefaultPageTimeout = 2 * time.Minute
+)
+
+var (
+	// ErrNoPages means a Paginator was started without any pages
+	ErrNoPages = errors.New("nothing to show")
+
+	// Paginator control emoji, these can be swapped for custom emoji in APIName format
+	PageFirst = "⏮️"
+	PagePrev  = "⬅️"
+	PageNext  = "➡️"
+	PageLast  = "⏭️"
+	PageJump  = "🔢"
+)
+
+// page is a single page of a Paginator, either content or an embed
+type page struct {
+	content string
+	embed   *discordgo.MessageEmbed
+}
+
+// Paginator is a message that can be paged through with reactions
+//
+// Build it up with AddPage, AddEmbed or AddLines, then Start it.
+// Controls are removed after Timeout passes without them being used, or on Close.
+// A Paginator with a single page is sent without controls.
+type Paginator struct {
+	Owner   string        // if set, only this user can change pages
+	Timeout time.Duration // how long the controls last between uses, DefaultPageTimeout if 0
+
+	ses       Session
+	channelID string
+	pages     []page
+
+	mu      sync.Mutex
+	page    int
+	msg     *discordgo.Message
+	jumping map[string]*discordgo.Message // users we're waiting on for a page number -> prompt
+	removes []func()
+	timer   *time.Timer
+	done    chan struct{}
+	closed  bool
+}
+
+// NewPaginator returns an empty Paginator for the channel
+func NewPaginator(ses Session, channelID string) *Paginator {
+	return &Paginator{
+		ses:       ses,
+		channelID: channelID,
+		pages:     []page{},
+		jumping:   make(map[string]*discordgo.Message),
+		done:      make(chan struct{}),
+	}
+}
+
+// AddPage adds a page of text
+func (p *Paginator) AddPage(content string) *Paginator {
+	p.pages = append(p.pages, page{content: content})
+	return p
+}
+
+// AddEmbed adds a page with an embed
+func (p *Paginator) AddEmbed(emb *discordgo.MessageEmbed) *Paginator {
+	p.pages = append(p.pages, page{embed: emb})
+	return p
+}
+
+// AddLines adds pages of at most perPage lines each, under the title
+//
+// Pages are started early if they would go over MessageLimit, and lines that are
+// too long on their own are cut short.
+func (p *Paginator) AddLines(title string, lines []string, perPage int) *Paginator {
+	// leave room for the page number
+	limit := MessageLimit - len("\n`Page 1000/1000`")
+
+	cur := title
+	count := 0
+	for _, line := range lines {
+		if len(title)+1+len(line) > limit {
+			cut := limit - len(title) - 1
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			line = line[:cut]
+		}
+		if count == perPage || len(cur)+1+len(line) > limit {
+			p.AddPage(cur)
+			cur = title
+			count = 0
+		}
+		cur += "\n" + line
+		count++
+	}
+	if count > 0 {
+		p.AddPage(cur)
+	}
+	return p
+}
+
+// Pages returns the number of pages
+func (p *Paginator) Pages() int { return len(p.pages) }
+
+// Find returns the index of the first page containing s in its content, or -1 if there isn't one
+func (p *Paginator) Find(s string) int {
+	for i, pg := range p.pages {
+		if strings.Contains(pg.content, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Page returns the index of the page being shown
+func (p *Paginator) Page() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.page
+}
+
+// Message returns the message the paginator is shown in, nil if it hasn't been started
+func (p *Paginator) Message() *discordgo.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.msg
+}
+
+// Done is closed once the paginator's controls are removed
+func (p *Paginator) Done() <-chan struct{} { return p.done }
+
+// Start sends the page at index start (or the first page if it's out of range) and adds the controls
+//
+// Start returns straight away, controls are handled in the background.
+// The paginator is closed if it couldn't be started.
+func (p *Paginator) Start(start int) error {
+	if len(p.pages) == 0 {
+		return ErrNoPages
+	}
+	if start < 0 || start >= len(p.pages) {
+		start = 0
+	}
+
+	err := p.start(start)
+	if err != nil {
+		// nothing else would close it, leaving Done open and the controls up
+		p.Close()
+	}
+	return err
+}
+
+// start sends the page and adds the controls for Start
+func (p *Paginator) start(start int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.page = start
+	data := p.render()
+	msg, err := p.ses.ChannelMessageSendComplex(p.channelID, &discordgo.MessageSend{
+		Content: *data.Content,
+		Embed:   data.Embed,
+	})
+	if err != nil {
+		return err
+	}
+	p.msg = msg
+
+	if len(p.pages) == 1 {
+		p.closed = true
+		close(p.done)
+		return nil
+	}
+
+	controls := []string{PagePrev, PageNext}
+	if len(p.pages) > 2 {
+		controls = []string{PageFirst, PagePrev, PageNext, PageLast, PageJump}
+	}
+	for _, emo := range controls {
+		err = p.ses.MessageReactionAdd(p.channelID, msg.ID, emo)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.removes = append(p.removes,
+		p.ses.AddHandler(func(_ *discordgo.Session, r *discordgo.MessageReactionAdd) {
+			p.handleReaction(r.MessageReaction)
+		}),
+		p.ses.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+			p.handleJump(m.Message)
+		}),
+	)
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultPageTimeout
+	}
+	p.timer = time.AfterFunc(timeout, p.Close)
+	return nil
+}
+
+// Close removes the paginator's controls, it is safe to call more than once
+func (p *Paginator) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if p.msg != nil {
+		p.ses.MessageReactionsRemoveAll(p.channelID, p.msg.ID)
+	}
+	for _, prompt := range p.jumping {
+		p.ses.ChannelMessageDelete(p.channelID, prompt.ID)
+	}
+	removes := p.removes
+	p.mu.Unlock()
+
+	// handlers might be waiting on the lock, so remove them without it
+	for _, remove := range removes {
+		remove()
+	}
+	close(p.done)
+}
+
+// render returns the edit for the current page, must be called with the lock held
+func (p *Paginator) render() *discordgo.MessageEdit {
+	pg := p.pages[p.page]
+	num := fmt.Sprintf("Page %d/%d", p.page+1, len(p.pages))
+
+	content := pg.content
+	if len(p.pages) > 1 && pg.embed == nil {
+		content += "\n`" + num + "`"
+	}
+
+	var emb *discordgo.MessageEmbed
+	if pg.embed != nil {
+		cpy := *pg.embed
+		if len(p.pages) > 1 {
+			cpy.Footer = &discordgo.MessageEmbedFooter{Text: num}
+		}
+		emb = &cpy
+	}
+
+	edit := &discordgo.MessageEdit{
+		Content: &content,
+		Embed:   emb,
+	}
+	if p.msg != nil {
+		edit.ID = p.msg.ID
+		edit.Channel = p.channelID
+	}
+	return edit
+}
+
+// turn shows the page at index to, must be called with the lock held
+func (p *Paginator) turn(to int) {
+	if to < 0 {
+		to = len(p.pages) - 1
+	} else if to >= len(p.pages) {
+		to = 0
+	}
+	p.page = to
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultPageTimeout
+	}
+	p.timer.Reset(timeout)
+
+	p.ses.ChannelMessageEditComplex(p.render())
+}
+
+// handleReaction handles a reaction to any message, ignoring those not on the paginator
+func (p *Paginator) handleReaction(r *discordgo.MessageReaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || r.MessageID != p.msg.ID || r.UserID == p.ses.State().User.ID {
+		return
+	}
+
+	emo := r.Emoji.APIName()
+	if emo != PageFirst && emo != PagePrev && emo != PageNext && emo != PageLast && emo != PageJump {
+		return
+	}
+
+	// take the reaction off so it can be used again
+	p.ses.MessageReactionRemove(r.ChannelID, r.MessageID, emo, r.UserID)
+
+	if len(p.Owner) > 0 && r.UserID != p.Owner {
+		return
+	}
+
+	switch emo {
+	case PageFirst:
+		p.turn(0)
+	case PagePrev:
+		p.turn(p.page - 1)
+	case PageNext:
+		p.turn(p.page + 1)
+	case PageLast:
+		p.turn(len(p.pages) - 1)
+	case PageJump:
+		if _, ok := p.jumping[r.UserID]; ok {
+			return
+		}
+		prompt, err := p.ses.ChannelMessageSend(p.channelID,
+			fmt.Sprintf("<@%s> which page? (1-%d)", r.UserID, len(p.pages)))
+		if err == nil {
+			p.jumping[r.UserID] = prompt
+		}
+	}
+}
+
+// handleJump handles a page number from a user that asked to jump
+func (p *Paginator) handleJump(m *discordgo.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || m.Author == nil || m.ChannelID != p.channelID {
+		return
+	}
+	prompt, ok := p.jumping[m.Author.ID]
+	if !ok {
+		return
+	}
+
+	num, err := strconv.Atoi(strings.TrimSpace(m.Content))
+	if err != nil || num < 1 || num > len(p.pages) {
+		return
+	}
+
+	delete(p.jumping, m.Author.ID)
+	p.ses.ChannelMessageDelete(p.channelID, prompt.ID)
+	p.ses.ChannelMessageDelete(p.channelID, m.ID)
+	p.turn(num - 1)
+}
@@ -0,0 +1,183 @@
+package commands_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// react emits a reaction from the user on the message
+func react(ses *FakeSession, msg *discordgo.Message, uid string, emo string) {
+	ses.Emit(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+		UserID:    uid,
+		MessageID: msg.ID,
+		ChannelID: msg.ChannelID,
+		Emoji:     discordgo.Emoji{Name: emo},
+	}})
+}
+
+func newLines(n int) []string {
+	lines := []string{}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	return lines
+}
+
+// TestPaginator verifies that a Paginator will:
+// - Split lines into pages and show the page number
+// - Change pages with the controls, wrapping around
+// - Only let the owner change pages
+// - Jump to a page number the owner replies with
+// - Remove its controls and handlers on Close
+func TestPaginator(t *testing.T) {
+	ses := newFake()
+
+	pag := NewPaginator(ses, "3").AddLines("Title", newLines(25), 10)
+	pag.Owner = "4"
+	if pag.Pages() != 3 {
+		t.Fatalf("AddLines(25 lines, 10) made %d pages; want 3", pag.Pages())
+	}
+
+	err := pag.Start(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pag.Close()
+
+	msg := pag.Message()
+	if !strings.HasPrefix(msg.Content, "Title\nline 10\n") || !strings.HasSuffix(msg.Content, "`Page 2/3`") {
+		t.Errorf("Start(1) sent %q", msg.Content)
+	}
+	if len(ses.Reactions(msg.ID)) != 5 {
+		t.Errorf("Start added %d controls; want 5", len(ses.Reactions(msg.ID)))
+	}
+
+	moves := []struct {
+		uid  string
+		emo  string
+		page int
+	}{
+		{"4", PageNext, 2},
+		{"4", PageNext, 0},
+		{"4", PagePrev, 2},
+		{"4", PageFirst, 0},
+		{"99", PageLast, 0}, // not the owner
+		{"4", PageLast, 2},
+	}
+	for _, mv := range moves {
+		react(ses, msg, mv.uid, mv.emo)
+		if pag.Page() != mv.page {
+			t.Errorf("%s from %s went to page %d; want %d", mv.emo, mv.uid, pag.Page(), mv.page)
+		}
+	}
+	if !strings.Contains(msg.Content, "line 20") {
+		t.Errorf("last page shows %q", msg.Content)
+	}
+
+	// jump
+	react(ses, msg, "4", PageJump)
+	prompt := ses.LastMessage("3")
+	if prompt.ID == msg.ID {
+		t.Fatalf("%s didn't ask for a page", PageJump)
+	}
+	ses.Emit(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID: "100", ChannelID: "3", Content: "2", Author: &discordgo.User{ID: "4"},
+	}})
+	if pag.Page() != 1 {
+		t.Errorf("jumping to 2 went to page %d; want 1", pag.Page())
+	}
+	if ses.LastMessage("3").ID != msg.ID {
+		t.Errorf("jumping didn't clean up the prompt")
+	}
+
+	pag.Close()
+	<-pag.Done()
+	if len(ses.Reactions(msg.ID)) != 0 {
+		t.Errorf("Close left controls on the message")
+	}
+	react(ses, msg, "4", PageNext)
+	if pag.Page() != 1 {
+		t.Errorf("%s after Close changed the page", PageNext)
+	}
+}
+
+// TestPaginatorTimeout removes controls after the timeout and sends single pages plainly
+func TestPaginatorTimeout(t *testing.T) {
+	ses := newFake()
+
+	pag := NewPaginator(ses, "3").
+		AddEmbed(&discordgo.MessageEmbed{Title: "one"}).
+		AddEmbed(&discordgo.MessageEmbed{Title: "two"})
+	pag.Timeout = 10 * time.Millisecond
+	err := pag.Start(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := pag.Message()
+	if len(msg.Embeds) != 1 || msg.Embeds[0].Footer == nil || msg.Embeds[0].Footer.Text != "Page 1/2" {
+		t.Errorf("Start sent %#v; want a numbered embed", msg.Embeds)
+	}
+
+	select {
+	case <-pag.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("paginator didn't time out")
+	}
+	if len(ses.Reactions(msg.ID)) != 0 {
+		t.Errorf("timing out left controls on the message")
+	}
+
+	single := NewPaginator(ses, "3").AddPage("only")
+	err = single.Start(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-single.Done()
+	if got := ses.LastMessage("3").Content; got != "only" {
+		t.Errorf("single page sent %q; want %q", got, "only")
+	}
+
+	err = NewPaginator(ses, "3").Start(0)
+	if err != ErrNoPages {
+		t.Errorf("Start with no pages threw %v; want %v", err, ErrNoPages)
+	}
+}
+
+// failingSession is a FakeSession that can't add reactions
+type failingSession struct {
+	*FakeSession
+}
+
+func (f *failingSession) MessageReactionAdd(channelID, messageID, emojiID string) error {
+	return fmt.Errorf("can't react")
+}
+
+// TestPaginatorStartFails verifies that a Paginator that couldn't add its controls is closed
+func TestPaginatorStartFails(t *testing.T) {
+	ses := newFake()
+	pag := NewPaginator(&failingSession{ses}, "3").AddLines("Title", newLines(25), 10)
+
+	err := pag.Start(0)
+	if err == nil {
+		t.Fatalf("Start without reactions didn't throw an error")
+	}
+	select {
+	case <-pag.Done():
+	default:
+		t.Errorf("Start that failed left the paginator open")
+	}
+
+	// its handlers are gone
+	msg := pag.Message()
+	react(ses, msg, "4", PageNext)
+	if got := ses.LastMessage("3").Content; !strings.Contains(got, "Page 1/3") {
+		t.Errorf("paginator that failed to start changed page to %q", got)
+	}
+}
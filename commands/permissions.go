@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Commands' Roles and Chans, permission groups and rules are lists of permissions, each of which can be:
+//  - a role or channel id
+//  - a role or channel name, matched case-insensitively
+//  - a discord permission e.g. "ManageMessages", for roles only
+//  - the name of a permission group from SetPermissionGroups e.g. "mod", which can hold any of these
+//
+// Users need any one of them. A name with a permission group is only checked against the group, not role names.
+
+const (
+	// lookupCacheTTL is how long members, roles and channels from the api are cached
+	lookupCacheTTL = time.Minute
+
+	keyPermRules = "rules"
+)
+
+var (
+	// ErrNoCommandRules means the guild has no rules for the command
+	ErrNoCommandRules = errors.New("no rules for that command")
+
+	// Permissions are the discord permissions by name, for use in Roles
+	Permissions = map[string]int{
+		"CreateInstantInvite": discordgo.PermissionCreateInstantInvite,
+		"KickMembers":         discordgo.PermissionKickMembers,
+		"BanMembers":          discordgo.PermissionBanMembers,
+		"Administrator":       discordgo.PermissionAdministrator,
+		"ManageChannels":      discordgo.PermissionManageChannels,
+		"ManageServer":        discordgo.PermissionManageServer,
+		"AddReactions":        discordgo.PermissionAddReactions,
+		"ViewAuditLogs":       discordgo.PermissionViewAuditLogs,
+		"ViewChannel":         discordgo.PermissionViewChannel,
+		"SendMessages":        discordgo.PermissionSendMessages,
+		"SendTTSMessages":     discordgo.PermissionSendTTSMessages,
+		"ManageMessages":      discordgo.PermissionManageMessages,
+		"EmbedLinks":          discordgo.PermissionEmbedLinks,
+		"AttachFiles":         discordgo.PermissionAttachFiles,
+		"ReadMessageHistory":  discordgo.PermissionReadMessageHistory,
+		"MentionEveryone":     discordgo.PermissionMentionEveryone,
+		"UseExternalEmojis":   discordgo.PermissionUseExternalEmojis,
+		"ChangeNickname":      discordgo.PermissionChangeNickname,
+		"ManageNicknames":     discordgo.PermissionManageNicknames,
+		"ManageRoles":         discordgo.PermissionManageRoles,
+		"ManageWebhooks":      discordgo.PermissionManageWebhooks,
+		"ManageEmojis":        discordgo.PermissionManageEmojis,
+	}
+
+	// permGroups are the permission groups, indexed by guild id then name, "" for groups in every guild
+	permGroups     = make(map[string]map[string][]string)
+	permGroupsLock = &sync.RWMutex{}
+
+	lookupCache     = make(map[string]*lookupEntry)
+	lookupCacheLock = &sync.Mutex{}
+)
+
+// SetPermissionGroups replaces the permission groups, indexed by guild id then name
+//
+// Groups under "" are in every guild, a guild's own groups take precedence.
+func SetPermissionGroups(groups map[string]map[string][]string) {
+	permGroupsLock.Lock()
+	defer permGroupsLock.Unlock()
+
+	permGroups = make(map[string]map[string][]string)
+	for gid, grps := range groups {
+		permGroups[gid] = make(map[string][]string)
+		for name, perms := range grps {
+			permGroups[gid][name] = append([]string{}, perms...)
+		}
+	}
+}
+
+// PermissionGroup returns the permissions in the guild's group, and whether there is one
+func PermissionGroup(guildID string, name string) ([]string, bool) {
+	permGroupsLock.RLock()
+	defer permGroupsLock.RUnlock()
+
+	if perms, ok := permGroups[guildID][name]; ok {
+		return perms, true
+	}
+	perms, ok := permGroups[""][name]
+	return perms, ok
+}
+
+/* cached lookups */
+
+type lookupEntry struct {
+	val interface{}
+	exp time.Time
+}
+
+// cachedLookup returns the value at key in the lookup cache, calling get if it isn't there
+func cachedLookup(key string, get func() (interface{}, error)) (interface{}, error) {
+	lookupCacheLock.Lock()
+	ent, ok := lookupCache[key]
+	lookupCacheLock.Unlock()
+	if ok && time.Now().Before(ent.exp) {
+		return ent.val, nil
+	}
+
+	val, err := get()
+	if err != nil {
+		return nil, err
+	}
+
+	lookupCacheLock.Lock()
+	lookupCache[key] = &lookupEntry{val, time.Now().Add(lookupCacheTTL)}
+	lookupCacheLock.Unlock()
+	return val, nil
+}
+
+// lookupMember returns the member from the state, or the api if the state doesn't have them
+func lookupMember(ses Session, guildID string, userID string) (*discordgo.Member, error) {
+	if mem, err := ses.State().Member(guildID, userID); err == nil {
+		return mem, nil
+	}
+	val, err := cachedLookup("member:"+guildID+":"+userID, func() (interface{}, error) {
+		return ses.GuildMember(guildID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*discordgo.Member), nil
+}
+
+// lookupRoles returns the guild's roles from the state, or the api if the state doesn't have them
+func lookupRoles(ses Session, guildID string) ([]*discordgo.Role, error) {
+	if gld, err := ses.State().Guild(guildID); err == nil && len(gld.Roles) > 0 {
+		return gld.Roles, nil
+	}
+	val, err := cachedLookup("roles:"+guildID, func() (interface{}, error) {
+		return ses.GuildRoles(guildID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.([]*discordgo.Role), nil
+}
+
+// lookupChannel returns the channel from the state, or the api if the state doesn't have it
+func lookupChannel(ses Session, channelID string) (*discordgo.Channel, error) {
+	if cha, err := ses.State().Channel(channelID); err == nil {
+		return cha, nil
+	}
+	val, err := cachedLookup("channel:"+channelID, func() (interface{}, error) {
+		return ses.Channel(channelID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*discordgo.Channel), nil
+}
+
+// memberPermissions returns the member's discord permissions in the channel
+func memberPermissions(ses Session, guildID string, cha *discordgo.Channel, mem *discordgo.Member, roles []*discordgo.Role) int {
+	if gld, err := ses.State().Guild(guildID); err == nil && gld.OwnerID == mem.User.ID {
+		return discordgo.PermissionAll
+	}
+
+	has := map[string]bool{guildID: true} // everyone has @everyone
+	for _, rid := range mem.Roles {
+		has[rid] = true
+	}
+
+	perms := 0
+	for _, rol := range roles {
+		if has[rol.ID] {
+			perms |= rol.Permissions
+		}
+	}
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return discordgo.PermissionAll
+	}
+	if cha == nil {
+		return perms
+	}
+
+	// overwrites go @everyone, then roles, then the member
+	allow, deny := 0, 0
+	for _, ow := range cha.PermissionOverwrites {
+		if ow.ID == guildID {
+			perms &^= ow.Deny
+			perms |= ow.Allow
+		} else if ow.Type == "role" && has[ow.ID] {
+			deny |= ow.Deny
+			allow |= ow.Allow
+		}
+	}
+	perms = perms&^deny | allow
+	for _, ow := range cha.PermissionOverwrites {
+		if ow.Type == "member" && ow.ID == mem.User.ID {
+			perms = perms&^ow.Deny | ow.Allow
+		}
+	}
+	return perms
+}
+
+/* checks */
+
+// HasRoles returns whether the message's author has any of the permissions, see the top of this file
+func HasRoles(ses Session, msg *discordgo.Message, perms []string) (bool, error) {
+	if len(perms) == 0 {
+		return true, nil
+	}
+	if msg.Author == nil {
+		return false, nil
+	}
+
+	mem, err := lookupMember(ses, msg.GuildID, msg.Author.ID)
+	if err != nil {
+		return false, err
+	}
+	roles, err := lookupRoles(ses, msg.GuildID)
+	if err != nil {
+		return false, err
+	}
+	cha, _ := lookupChannel(ses, msg.ChannelID)
+
+	// member's role ids and lowercase names
+	has := make(map[string]bool)
+	for _, rid := range mem.Roles {
+		has[rid] = true
+		for _, rol := range roles {
+			if rol.ID == rid {
+				has[strings.ToLower(rol.Name)] = true
+			}
+		}
+	}
+
+	// permission bits are only worked out if needed
+	bits := -1
+	seen := make(map[string]bool)
+	var check func(perms []string) bool
+	check = func(perms []string) bool {
+		for _, perm := range perms {
+			// a configured group decides on its own, so a role can't pass by sharing its name
+			if grp, ok := PermissionGroup(msg.GuildID, perm); ok {
+				if !seen[perm] {
+					seen[perm] = true
+					if check(grp) {
+						return true
+					}
+				}
+				continue
+			}
+
+			if bit, ok := Permissions[perm]; ok {
+				if bits < 0 {
+					bits = memberPermissions(ses, msg.GuildID, cha, mem, roles)
+				}
+				if bits&bit != 0 {
+					return true
+				}
+				continue
+			}
+
+			if has[perm] || has[strings.ToLower(perm)] {
+				return true
+			}
+		}
+		return false
+	}
+	return check(perms), nil
+}
+
+// InChannels returns whether the message was sent in any of the channels, see the top of this file
+func InChannels(ses Session, msg *discordgo.Message, chans []string) (bool, error) {
+	if len(chans) == 0 {
+		return true, nil
+	}
+
+	cha, err := lookupChannel(ses, msg.ChannelID)
+	if err != nil {
+		return false, err
+	}
+
+	seen := make(map[string]bool)
+	var check func(chans []string) bool
+	check = func(chans []string) bool {
+		for _, c := range chans {
+			if c == cha.ID || strings.EqualFold(c, cha.Name) {
+				return true
+			}
+			if grp, ok := PermissionGroup(msg.GuildID, c); ok && !seen[c] {
+				seen[c] = true
+				if check(grp) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return check(chans), nil
+}
+
+/* rules */
+
+// CommandRules are a guild's grants and restrictions on a command, on top of its Roles and Chans
+//
+// Denials win over grants, and the lists take permissions like Roles and Chans do.
+type CommandRules struct {
+	AllowRoles []string // users with these can use the command without its Roles
+	DenyRoles  []string // users with these can't use the command
+	AllowChans []string // the command can be used in these without its Chans
+	DenyChans  []string // the command can't be used in these
+}
+
+// Empty returns whether there are no rules
+func (r *CommandRules) Empty() bool {
+	return len(r.AllowRoles)+len(r.DenyRoles)+len(r.AllowChans)+len(r.DenyChans) == 0
+}
+
+// permRules implements the Storer interface
+type permRules struct {
+	Commands map[string]*CommandRules // indexed by the command's first alias
+}
+
+func (p *permRules) Index() string { return "perms" }
+
+// GuildCommandRules returns all of the guild's rules, indexed by the command's first alias
+func GuildCommandRules(guildID string) (map[string]*CommandRules, error) {
+	var rls permRules
+	err := DBGet(&rls, GuildKey(guildID, keyPermRules), &rls)
+	if err == ErrDBNotFound {
+		return map[string]*CommandRules{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rls.Commands == nil {
+		rls.Commands = make(map[string]*CommandRules)
+	}
+	return rls.Commands, nil
+}
+
+// GetCommandRules returns the guild's rules for the command, named by its first alias
+func GetCommandRules(guildID string, name string) (*CommandRules, error) {
+	rls, err := GuildCommandRules(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	rul, ok := rls[name]
+	if !ok || rul == nil {
+		return nil, ErrNoCommandRules
+	}
+	return rul, nil
+}
+
+// SetCommandRules replaces the guild's rules for the command, named by its first alias, empty rules are removed
+func SetCommandRules(guildID string, name string, rules *CommandRules) error {
+	return DBUpdate(&permRules{}, GuildKey(guildID, keyPermRules), func(v Storer) error {
+		rls := v.(*permRules)
+		if rls.Commands == nil {
+			rls.Commands = make(map[string]*CommandRules)
+		}
+
+		if rules == nil || rules.Empty() {
+			delete(rls.Commands, name)
+		} else {
+			rls.Commands[name] = rules
+		}
+		return nil
+	})
+}
+
+// commandRules returns the rules for the context's command, empty if there aren't any or the db can't be read
+func commandRules(ctx *Context) *CommandRules {
+	rul, err := GetCommandRules(ctx.Message.GuildID, ctx.Command.Aliases()[0])
+	if err != nil {
+		return &CommandRules{}
+	}
+	return rul
+}
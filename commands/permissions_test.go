@@ -0,0 +1,177 @@
+package commands_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+/* preamble */
+
+// newPermsFake returns a fake session where user 4 is a Helper, who can manage messages except in #quiet
+func newPermsFake() *FakeSession {
+	ses := NewFakeSession("0")
+	ses.State().GuildAdd(&discordgo.Guild{
+		ID:   "1",
+		Name: "guild",
+		Roles: []*discordgo.Role{
+			&discordgo.Role{ID: "1", Name: "@everyone", Permissions: discordgo.PermissionSendMessages},
+			&discordgo.Role{ID: "2", Name: "Mod", Permissions: discordgo.PermissionAdministrator},
+			&discordgo.Role{ID: "5", Name: "Helper", Permissions: discordgo.PermissionManageMessages},
+		},
+		Channels: []*discordgo.Channel{
+			&discordgo.Channel{ID: "3", GuildID: "1", Name: "general"},
+			&discordgo.Channel{ID: "6", GuildID: "1", Name: "quiet", PermissionOverwrites: []*discordgo.PermissionOverwrite{
+				&discordgo.PermissionOverwrite{ID: "5", Type: "role", Deny: discordgo.PermissionManageMessages},
+			}},
+		},
+		Members: []*discordgo.Member{
+			&discordgo.Member{GuildID: "1", User: &discordgo.User{ID: "4", Username: "bob"}, Roles: []string{"5"}},
+			&discordgo.Member{GuildID: "1", User: &discordgo.User{ID: "7", Username: "alice"}, Roles: []string{"2"}},
+		},
+	})
+	return ses
+}
+
+// permsMessage returns a message from the user in the channel
+func permsMessage(userID, channelID string) *discordgo.Message {
+	return &discordgo.Message{ChannelID: channelID, GuildID: "1", Author: &discordgo.User{ID: userID}}
+}
+
+/* tests */
+
+// TestHasRoles checks role ids, names, permissions and groups
+func TestHasRoles(t *testing.T) {
+	ses := newPermsFake()
+	SetPermissionGroups(map[string]map[string][]string{
+		"":  {"staff": {"helper", "mod"}, "loop": {"loop"}},
+		"1": {"mod": {"2", "KickMembers"}},
+	})
+	defer SetPermissionGroups(nil)
+
+	tests := []struct {
+		user, channel string
+		perms         []string
+		want          bool
+	}{
+		{"4", "3", nil, true},
+		{"4", "3", []string{"5"}, true},
+		{"4", "3", []string{"2"}, false},
+		{"4", "3", []string{"helper"}, true},
+		{"4", "3", []string{"mod"}, false},
+		{"4", "3", []string{"ManageMessages"}, true},
+		{"4", "6", []string{"ManageMessages"}, false}, // overwritten in #quiet
+		{"4", "3", []string{"BanMembers"}, false},
+		{"7", "3", []string{"BanMembers"}, true}, // administrators have everything
+		{"4", "3", []string{"staff"}, true},
+		{"7", "3", []string{"staff"}, true},
+		{"4", "3", []string{"loop"}, false},
+	}
+	for _, tt := range tests {
+		got, err := HasRoles(ses, permsMessage(tt.user, tt.channel), tt.perms)
+		if err != nil {
+			t.Errorf("HasRoles(%s in %s, %q) threw %v", tt.user, tt.channel, tt.perms, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("HasRoles(%s in %s, %q) = %v; want %v", tt.user, tt.channel, tt.perms, got, tt.want)
+		}
+	}
+
+	for _, chans := range [][]string{{"3"}, {"General"}, {"quiet", "general"}} {
+		if in, _ := InChannels(ses, permsMessage("4", "3"), chans); !in {
+			t.Errorf("InChannels(#general, %q) = false; want true", chans)
+		}
+	}
+	if in, _ := InChannels(ses, permsMessage("4", "3"), []string{"quiet"}); in {
+		t.Errorf("InChannels(#general, [quiet]) = true; want false")
+	}
+}
+
+// TestHasRolesGroupNames only matches role names when there's no group with the name
+func TestHasRolesGroupNames(t *testing.T) {
+	ses := newPermsFake()
+	msg := permsMessage("7", "3") // has the role named Mod
+
+	if ok, _ := HasRoles(ses, msg, []string{"mod"}); !ok {
+		t.Errorf("HasRoles(Mod, [mod]) without groups = false; want true")
+	}
+
+	SetPermissionGroups(map[string]map[string][]string{"1": {"mod": {"99"}}})
+	defer SetPermissionGroups(nil)
+	if ok, _ := HasRoles(ses, msg, []string{"mod"}); ok {
+		t.Errorf("HasRoles(Mod, [mod]) with mod group of other ids = true; want false")
+	}
+	if ok, _ := HasRoles(ses, msg, []string{"2"}); !ok {
+		t.Errorf("HasRoles(Mod, [2]) with mod group = false; want true")
+	}
+}
+
+// TestCommandRules grants and denies commands to roles and channels at runtime
+func TestCommandRules(t *testing.T) {
+	ses := newPermsFake()
+	logf := func(format string, v ...interface{}) {}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+	defer SetCommandRules("1", "say", nil)
+
+	say := func(user, channel string) string {
+		err := dis.Dispatch(ses, &discordgo.Message{
+			ID:        "100",
+			ChannelID: channel,
+			GuildID:   "1",
+			Content:   Prefix + "say 1 hi",
+			Author:    &discordgo.User{ID: user},
+		})
+		if err != nil {
+			return err.Error()
+		}
+		return ses.LastMessage(channel).Content
+	}
+
+	tests := []struct {
+		rules         *CommandRules
+		user, channel string
+		want          string
+	}{
+		{nil, "4", "3", "hi"},
+		{&CommandRules{DenyRoles: []string{"helper"}}, "4", "3", "You aren't allowed to use this command"},
+		{&CommandRules{DenyRoles: []string{"helper"}}, "7", "3", "hi"},
+		{&CommandRules{DenyChans: []string{"quiet"}}, "4", "6", "This command can't be used in this channel"},
+		{&CommandRules{DenyChans: []string{"quiet"}}, "4", "3", "hi"},
+	}
+	for i, tt := range tests {
+		err := SetCommandRules("1", "say", tt.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := say(tt.user, tt.channel); got != tt.want {
+			t.Errorf("%d: !say from %s in %s = %q; want %q", i, tt.user, tt.channel, got, tt.want)
+		}
+	}
+
+	// allowing lets users without the command's roles use it, !modsay is also a say
+	modsay := func(user string) error {
+		return dis.Dispatch(ses, &discordgo.Message{
+			ID: "100", ChannelID: "3", GuildID: "1", Content: Prefix + "modsay 1 hi", Author: &discordgo.User{ID: user},
+		})
+	}
+	if err := modsay("4"); err == nil {
+		t.Errorf("!modsay from a helper didn't throw an error")
+	}
+	SetCommandRules("1", "say", &CommandRules{AllowRoles: []string{"ManageMessages"}})
+	if err := modsay("4"); err != nil {
+		t.Errorf("!modsay from a helper after allowing ManageMessages threw %v", err)
+	}
+
+	rul, err := GetCommandRules("1", "say")
+	if err != nil || fmt.Sprint(rul.AllowRoles) != "[ManageMessages]" {
+		t.Errorf("GetCommandRules = %+v, %v; want AllowRoles [ManageMessages]", rul, err)
+	}
+	SetCommandRules("1", "say", &CommandRules{})
+	if _, err = GetCommandRules("1", "say"); err != ErrNoCommandRules {
+		t.Errorf("GetCommandRules after emptying the rules threw %v; want ErrNoCommandRules", err)
+	}
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// ErrPromptTimeout means the user didn't answer a prompt in time
+	ErrPromptTimeout = errors.New("timed out waiting for an answer")
+	// ErrPromptCancelled means the prompt was cancelled, by the user or by a newer prompt
+	ErrPromptCancelled = errors.New("cancelled")
+
+	// PromptConfirm and PromptDeny are the reactions used by Confirm
+	PromptConfirm = string(rune(0x2705))
+	PromptDeny    = string(rune(0x274C))
+	// PromptCancel is the reply that cancels AwaitReply
+	PromptCancel = "cancel"
+
+	// prompts maps channel+user to the cancel channel of the prompt they're answering
+	prompts     = make(map[string]chan struct{})
+	promptsLock = &sync.Mutex{}
+)
+
+// startPrompt registers a prompt for the user in the channel, cancelling any older one
+//
+// The returned channel is closed if the prompt is cancelled, call done when finished
+func startPrompt(channelID, userID string) (cancelled <-chan struct{}, done func()) {
+	key := channelID + ":" + userID
+	cancel := make(chan struct{})
+
+	promptsLock.Lock()
+	if old, ok := prompts[key]; ok {
+		close(old)
+	}
+	prompts[key] = cancel
+	promptsLock.Unlock()
+
+	return cancel, func() {
+		promptsLock.Lock()
+		defer promptsLock.Unlock()
+		if prompts[key] == cancel {
+			delete(prompts, key)
+		}
+	}
+}
+
+// CancelPrompt cancels the prompt the user is answering in the channel, if any
+func CancelPrompt(channelID, userID string) {
+	key := channelID + ":" + userID
+
+	promptsLock.Lock()
+	defer promptsLock.Unlock()
+	if cancel, ok := prompts[key]; ok {
+		close(cancel)
+		delete(prompts, key)
+	}
+}
+
+// Confirm asks the user to react with PromptConfirm or PromptDeny to the prompt
+//
+// Returns false with ErrPromptTimeout if the user doesn't answer in time,
+// or ErrPromptCancelled if the prompt is cancelled.
+func Confirm(ses Session, channelID, userID, prompt string, timeout time.Duration) (bool, error) {
+	choice, err := Choose(ses, channelID, userID, prompt, []string{PromptConfirm, PromptDeny}, timeout)
+	if err != nil {
+		return false, err
+	}
+	return choice == 0, nil
+}
+
+// Choose asks the user to react to the prompt with one of the choices, and returns the index of their choice
+//
+// Choices are emoji in APIName format. Only the user's reactions count,
+// and the choices are removed from the prompt when done.
+// A newer prompt for the same user in the same channel cancels this one.
+func Choose(ses Session, channelID, userID, prompt string, choices []string, timeout time.Duration) (int, error) {
+	cancelled, done := startPrompt(channelID, userID)
+	defer done()
+
+	msg, err := ses.ChannelMessageSend(channelID, prompt)
+	if err != nil {
+		return -1, err
+	}
+	defer ses.MessageReactionsRemoveAll(channelID, msg.ID)
+
+	// listen before reacting so quick answers aren't missed
+	chosen := make(chan int, 1)
+	remove := ses.AddHandler(func(_ *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		if r.MessageID != msg.ID || r.UserID != userID {
+			return
+		}
+		for i, cho := range choices {
+			if r.Emoji.APIName() == cho {
+				select {
+				case chosen <- i:
+				default:
+				}
+				return
+			}
+		}
+	})
+	defer remove()
+
+	for _, cho := range choices {
+		err = ses.MessageReactionAdd(channelID, msg.ID, cho)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	select {
+	case i := <-chosen:
+		return i, nil
+	case <-cancelled:
+		return -1, ErrPromptCancelled
+	case <-time.After(timeout):
+		return -1, ErrPromptTimeout
+	}
+}
+
+// AwaitReply sends the prompt (if not empty) and waits for the user's next message in the channel
+//
+// Replying with PromptCancel cancels the prompt.
+// A newer prompt for the same user in the same channel cancels this one.
+func AwaitReply(ses Session, channelID, userID, prompt string, timeout time.Duration) (*discordgo.Message, error) {
+	cancelled, done := startPrompt(channelID, userID)
+	defer done()
+
+	replies := make(chan *discordgo.Message, 1)
+	remove := ses.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Author == nil || m.Author.ID != userID || m.ChannelID != channelID {
+			return
+		}
+		select {
+		case replies <- m.Message:
+		default:
+		}
+	})
+	defer remove()
+
+	if len(prompt) > 0 {
+		_, err := ses.ChannelMessageSend(channelID, prompt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	select {
+	case m := <-replies:
+		if strings.EqualFold(strings.TrimSpace(m.Content), PromptCancel) {
+			return nil, ErrPromptCancelled
+		}
+		return m, nil
+	case <-cancelled:
+		return nil, ErrPromptCancelled
+	case <-time.After(timeout):
+		return nil, ErrPromptTimeout
+	}
+}
@@ -0,0 +1,125 @@
+package commands_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// waitForPrompt waits until a message with the content is sent and the bot has reacted to it if it should
+func waitForPrompt(t *testing.T, ses *FakeSession, content string, reactions int) *discordgo.Message {
+	for i := 0; i < 1000; i++ {
+		last := ses.LastMessage("3")
+		if last != nil && last.Content == content && len(ses.Reactions(last.ID)) >= reactions {
+			return last
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("prompt %q was never sent", content)
+	return nil
+}
+
+// TestConfirm verifies that Confirm will:
+// - Only take answers from the user
+// - Return the user's answer and clean up the reactions
+// - Time out
+func TestConfirm(t *testing.T) {
+	ses := newFake()
+
+	tests := []struct {
+		emo string
+		exp bool
+	}{
+		{PromptConfirm, true},
+		{PromptDeny, false},
+	}
+	for _, tt := range tests {
+		go func(emo string) {
+			msg := waitForPrompt(t, ses, "sure?", 2)
+			if msg == nil {
+				return
+			}
+			react(ses, msg, "99", PromptConfirm)
+			react(ses, msg, "4", emo)
+		}(tt.emo)
+
+		got, err := Confirm(ses, "3", "4", "sure?", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.exp {
+			t.Errorf("Confirm with %s = %v; want %v", tt.emo, got, tt.exp)
+		}
+		if len(ses.Reactions(ses.LastMessage("3").ID)) != 0 {
+			t.Errorf("Confirm left reactions on the prompt")
+		}
+	}
+
+	_, err := Confirm(ses, "3", "4", "sure?", 10*time.Millisecond)
+	if err != ErrPromptTimeout {
+		t.Errorf("Confirm without an answer threw %v; want %v", err, ErrPromptTimeout)
+	}
+}
+
+// TestChooseCancel cancels a prompt with a newer one
+func TestChooseCancel(t *testing.T) {
+	ses := newFake()
+
+	errs := make(chan error)
+	go func() {
+		_, err := Choose(ses, "3", "4", "first", []string{"a", "b"}, time.Second)
+		errs <- err
+	}()
+	waitForPrompt(t, ses, "first", 2)
+
+	go func() {
+		msg := waitForPrompt(t, ses, "second", 2)
+		if msg == nil {
+			return
+		}
+		react(ses, msg, "4", "b")
+	}()
+	got, err := Choose(ses, "3", "4", "second", []string{"a", "b"}, time.Second)
+	if err != nil || got != 1 {
+		t.Errorf("Choose = %d, %v; want 1", got, err)
+	}
+
+	if err = <-errs; err != ErrPromptCancelled {
+		t.Errorf("older prompt threw %v; want %v", err, ErrPromptCancelled)
+	}
+}
+
+// TestAwaitReply waits for a reply from the user, which can cancel it
+func TestAwaitReply(t *testing.T) {
+	ses := newFake()
+
+	reply := func(prompt string, uid string, content string) {
+		if waitForPrompt(t, ses, prompt, 0) == nil {
+			return
+		}
+		ses.Emit(&discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: "3", Content: content, Author: &discordgo.User{ID: uid},
+		}})
+	}
+
+	go func() {
+		reply("name?", "99", "not me")
+		reply("name?", "4", "bob")
+	}()
+	got, err := AwaitReply(ses, "3", "4", "name?", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != "bob" {
+		t.Errorf("AwaitReply got %q; want bob", got.Content)
+	}
+
+	go reply("name again?", "4", "Cancel")
+	_, err = AwaitReply(ses, "3", "4", "name again?", time.Second)
+	if err != ErrPromptCancelled {
+		t.Errorf("AwaitReply with cancel threw %v; want %v", err, ErrPromptCancelled)
+	}
+}
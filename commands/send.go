@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// FileLimit is the length past which message content is sent as a .txt attachment instead
+	FileLimit = 4 * MessageLimit
+
+	// embed limits, see https://discord.com/developers/docs/resources/channel#embed-limits
+	EmbedTitleLimit       = 256
+	EmbedDescriptionLimit = 4096
+	EmbedFieldsLimit      = 25
+	EmbedFieldNameLimit   = 256
+	EmbedFieldValueLimit  = 1024
+	EmbedFooterLimit      = 2048
+	EmbedAuthorLimit      = 256
+	EmbedTotalLimit       = 6000
+
+	// codeFence opens and closes code blocks
+	codeFence = "```"
+)
+
+// EmbedLimitError means part of an embed is over discord's limits
+type EmbedLimitError struct {
+	Part   string // which part of the embed e.g. "title" or "field 2 value"
+	Length int    // length of the part
+	Limit  int    // discord's limit for the part
+}
+
+func (e *EmbedLimitError) Error() string {
+	return fmt.Sprintf("embed %s is %d long, over the limit of %d", e.Part, e.Length, e.Limit)
+}
+
+// ValidateEmbed checks an embed against discord's length limits
+//
+// Returns an *EmbedLimitError for the first part that is too long
+func ValidateEmbed(emb *discordgo.MessageEmbed) error {
+	if emb == nil {
+		return nil
+	}
+
+	total := 0
+	check := func(part string, text string, limit int) error {
+		n := utf8.RuneCountInString(text)
+		total += n
+		if n > limit {
+			return &EmbedLimitError{part, n, limit}
+		}
+		return nil
+	}
+
+	if err := check("title", emb.Title, EmbedTitleLimit); err != nil {
+		return err
+	}
+	if err := check("description", emb.Description, EmbedDescriptionLimit); err != nil {
+		return err
+	}
+	if emb.Footer != nil {
+		if err := check("footer", emb.Footer.Text, EmbedFooterLimit); err != nil {
+			return err
+		}
+	}
+	if emb.Author != nil {
+		if err := check("author", emb.Author.Name, EmbedAuthorLimit); err != nil {
+			return err
+		}
+	}
+
+	if len(emb.Fields) > EmbedFieldsLimit {
+		return &EmbedLimitError{"fields", len(emb.Fields), EmbedFieldsLimit}
+	}
+	for i, fld := range emb.Fields {
+		if err := check(fmt.Sprintf("field %d name", i), fld.Name, EmbedFieldNameLimit); err != nil {
+			return err
+		}
+		if err := check(fmt.Sprintf("field %d value", i), fld.Value, EmbedFieldValueLimit); err != nil {
+			return err
+		}
+	}
+
+	if total > EmbedTotalLimit {
+		return &EmbedLimitError{"total", total, EmbedTotalLimit}
+	}
+	return nil
+}
+
+// SplitMessage splits content into chunks of at most limit bytes
+//
+// Content is split between lines where it can, and long lines are split between words.
+// Code blocks that are split are closed at the end of a chunk and reopened
+// (with the same language) at the start of the next, so ``` fences stay balanced.
+func SplitMessage(content string, limit int) []string {
+	if len(content) <= limit {
+		return []string{content}
+	}
+
+	chunks := []string{}
+	var cur strings.Builder
+	started := false // whether cur has any lines, which could be empty
+	fence := ""      // line that opened the code block we're in, empty if not in one
+
+	for _, line := range strings.Split(content, "\n") {
+		// work out if this line opens or closes a code block
+		next := fence
+		if strings.Count(line, codeFence)%2 == 1 {
+			if fence == "" {
+				next = openingFence(line)
+			} else {
+				next = ""
+			}
+		}
+
+		// leave room to close and reopen the block
+		size := limit - 2*(len(fence)+len(codeFence)+2)
+		if size < limit/2 {
+			size = limit / 2
+		}
+		pieces := splitLine(line, size)
+		for i, piece := range pieces {
+			after := fence
+			if i == len(pieces)-1 {
+				after = next
+			}
+
+			need := len(piece)
+			if started {
+				need++
+			}
+			if after != "" {
+				need += 1 + len(codeFence)
+			}
+
+			if started && cur.Len()+need > limit {
+				// flush, closing and reopening any open block
+				if fence != "" {
+					cur.WriteString("\n" + codeFence)
+				}
+				chunks = append(chunks, cur.String())
+				cur.Reset()
+				started = false
+				if fence != "" {
+					cur.WriteString(fence)
+					started = true
+				}
+			}
+
+			if started {
+				cur.WriteString("\n")
+			}
+			cur.WriteString(piece)
+			started = true
+		}
+		fence = next
+	}
+
+	if started {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+// openingFence returns the fence and language that a line opens a code block with e.g. ```go
+func openingFence(line string) string {
+	lang := line[strings.LastIndex(line, codeFence)+len(codeFence):]
+	if len(lang) == 0 || strings.ContainsAny(lang, " \t`") {
+		return codeFence
+	}
+	return codeFence + lang
+}
+
+// splitLine splits a line into pieces of at most limit bytes, between words if possible
+func splitLine(line string, limit int) []string {
+	pieces := []string{}
+	for len(line) > limit {
+		cut := strings.LastIndex(line[:limit], " ")
+		if cut > limit/2 {
+			pieces = append(pieces, line[:cut])
+			line = line[cut+1:]
+			continue
+		}
+
+		// no good space, don't cut a rune in half
+		cut = limit
+		for cut > 1 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		pieces = append(pieces, line[:cut])
+		line = line[cut:]
+	}
+	return append(pieces, line)
+}
@@ -0,0 +1,126 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestSplitMessage verifies that SplitMessage will:
+// - Not split short messages
+// - Split between lines, keeping every line
+// - Split long lines between words
+// - Close and reopen code blocks across chunks
+func TestSplitMessage(t *testing.T) {
+	got := SplitMessage("short", 10)
+	if len(got) != 1 || got[0] != "short" {
+		t.Errorf("SplitMessage(short) = %q", got)
+	}
+
+	lines := []string{}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, strings.Repeat("x", i%30))
+	}
+	in := strings.Join(lines, "\n")
+	got = SplitMessage(in, 100)
+	for _, chunk := range got {
+		if len(chunk) > 100 {
+			t.Errorf("SplitMessage gave a chunk of length %d; limit 100", len(chunk))
+		}
+	}
+	if strings.Join(got, "\n") != in {
+		t.Errorf("SplitMessage lost content: %q", got)
+	}
+
+	in = strings.Repeat("word ", 100)
+	got = SplitMessage(in, 100)
+	for _, chunk := range got {
+		if len(chunk) > 100 || strings.HasPrefix(chunk, " ") || strings.HasSuffix(chunk, "wor") {
+			t.Errorf("SplitMessage split a word or went over: %q", chunk)
+		}
+	}
+
+	in = "look:\n```go\n" + strings.Repeat("fmt.Println()\n", 20) + "```\ndone"
+	got = SplitMessage(in, 100)
+	if len(got) < 2 {
+		t.Fatalf("SplitMessage didn't split %q", in)
+	}
+	for i, chunk := range got {
+		if len(chunk) > 100 {
+			t.Errorf("SplitMessage gave a chunk of length %d; limit 100", len(chunk))
+		}
+		if strings.Count(chunk, "```")%2 != 0 {
+			t.Errorf("SplitMessage chunk %d has unbalanced fences: %q", i, chunk)
+		}
+		if i > 0 && i < len(got)-1 && !strings.HasPrefix(chunk, "```go\n") {
+			t.Errorf("SplitMessage chunk %d doesn't reopen the block: %q", i, chunk)
+		}
+	}
+}
+
+// TestValidateEmbed checks embed limits
+func TestValidateEmbed(t *testing.T) {
+	ok := &discordgo.MessageEmbed{
+		Title:  "title",
+		Fields: []*discordgo.MessageEmbedField{{Name: "a", Value: "b"}},
+	}
+	if err := ValidateEmbed(ok); err != nil {
+		t.Errorf("ValidateEmbed(ok) threw %v", err)
+	}
+
+	long := strings.Repeat("a", EmbedFieldValueLimit+1)
+	bad := map[string]*discordgo.MessageEmbed{
+		"title":         {Title: strings.Repeat("a", EmbedTitleLimit+1)},
+		"field 1 value": {Fields: []*discordgo.MessageEmbedField{{}, {Value: long}}},
+		"fields":        {Fields: make([]*discordgo.MessageEmbedField, EmbedFieldsLimit+1)},
+		"total": {
+			Description: strings.Repeat("a", EmbedDescriptionLimit),
+			Footer:      &discordgo.MessageEmbedFooter{Text: strings.Repeat("a", EmbedFooterLimit)},
+		},
+	}
+	for part, emb := range bad {
+		err := ValidateEmbed(emb)
+		lerr, ok := err.(*EmbedLimitError)
+		if !ok || lerr.Part != part {
+			t.Errorf("ValidateEmbed(%s) threw %v; want an EmbedLimitError for %s", part, err, part)
+		}
+	}
+}
+
+// TestSendSplit verifies that Send splits and attaches long messages and checks embeds
+func TestSendSplit(t *testing.T) {
+	ses := newFake()
+
+	long := strings.Repeat(strings.Repeat("a", 99)+"\n", 30)
+	err := NewSimpleSend("3", long).Send(ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := ses.Messages("3")
+	if len(msgs) != 2 || msgs[0].Content+"\n"+msgs[1].Content != long {
+		t.Errorf("Send(%d chars) sent %d messages", len(long), len(msgs))
+	}
+
+	huge := strings.Repeat("a", FileLimit+1)
+	err = NewSimpleSend("3", huge).Send(ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := ses.LastMessage("3")
+	if len(ses.Messages("3")) != 3 || len(last.Attachments) != 1 || last.Attachments[0].Filename != "message.txt" {
+		t.Errorf("Send(%d chars) sent %#v; want an attachment", len(huge), last)
+	}
+
+	err = NewSend("3").Message("hi").Embed(&discordgo.MessageEmbed{
+		Title: strings.Repeat("a", EmbedTitleLimit+1),
+	}).Send(ses)
+	if _, ok := err.(*EmbedLimitError); !ok {
+		t.Errorf("Send with a long embed title threw %v; want an EmbedLimitError", err)
+	}
+	if len(ses.Messages("3")) != 3 {
+		t.Errorf("Send with a bad embed still sent messages")
+	}
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the interface for the discord calls that commands make.
+//
+// Use NewSession to wrap a live *discordgo.Session, or NewFakeSession
+// to get an in-memory implementation for tests.
+type Session interface {
+	// State returns the session's state cache
+	State() *discordgo.State
+
+	// messages
+	ChannelMessage(channelID, messageID string) (*discordgo.Message, error)
+	ChannelMessageSend(channelID, content string) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string) (*discordgo.Message, error)
+	ChannelMessageEditComplex(edit *discordgo.MessageEdit) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string) error
+	ChannelTyping(channelID string) error
+
+	// reactions
+	MessageReactionAdd(channelID, messageID, emojiID string) error
+	MessageReactionRemove(channelID, messageID, emojiID, userID string) error
+	MessageReactionsRemoveAll(channelID, messageID string) error
+
+	// guilds, members and roles
+	UserGuilds(limit int, beforeID, afterID string) ([]*discordgo.UserGuild, error)
+	GuildMember(guildID, userID string) (*discordgo.Member, error)
+	GuildMembers(guildID, after string, limit int) ([]*discordgo.Member, error)
+	GuildMemberRoleAdd(guildID, userID, roleID string) error
+	GuildMemberRoleRemove(guildID, userID, roleID string) error
+	GuildRoles(guildID string) ([]*discordgo.Role, error)
+	GuildRoleDelete(guildID, roleID string) error
+	GuildEmojis(guildID string) ([]*discordgo.Emoji, error)
+	GuildChannels(guildID string) ([]*discordgo.Channel, error)
+
+	// channels and users
+	Channel(channelID string) (*discordgo.Channel, error)
+	User(userID string) (*discordgo.User, error)
+
+	// events and status
+	AddHandler(handler interface{}) func()
+	UpdateStatus(idle int, game string) error
+
+	// raw requests, for endpoints discordgo doesn't cover yet e.g. interactions
+	RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error)
+}
+
+// discordSession implements Session with a live discordgo session
+type discordSession struct {
+	*discordgo.Session
+}
+
+// NewSession wraps a discordgo session so it can be used as a Session.
+func NewSession(ses *discordgo.Session) Session {
+	return &discordSession{ses}
+}
+
+func (d *discordSession) State() *discordgo.State { return d.Session.State }
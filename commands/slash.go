@@ -0,0 +1,617 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordgo doesn't know about interactions yet, so the types and endpoints we need are here.
+// See https://discord.com/developers/docs/interactions/slash-commands
+
+// OptionType is the type of an ApplicationCommandOption
+type OptionType int
+
+// option types
+const (
+	OptionSubCommand OptionType = iota + 1
+	OptionSubCommandGroup
+	OptionString
+	OptionInteger
+	OptionBoolean
+	OptionUser
+	OptionChannel
+	OptionRole
+	OptionNumber OptionType = 10
+)
+
+// InteractionType is the type of an Interaction
+type InteractionType int
+
+// interaction types
+const (
+	InteractionPing InteractionType = iota + 1
+	InteractionApplicationCommand
+	InteractionMessageComponent
+)
+
+// interaction response types
+const (
+	responseMessage         = 4
+	responseDeferredMessage = 5
+	responseDeferredUpdate  = 6
+
+	// messageFlagEphemeral makes a response only visible to the user
+	messageFlagEphemeral = 1 << 6
+)
+
+const (
+	// SlashBase is the subcommand name given to commands like !quote that also have subcommands,
+	// since discord doesn't let you call a slash command that has subcommands
+	SlashBase = "run"
+
+	// slash command name and description limits
+	slashNameLimit    = 32
+	slashDescLimit    = 100
+	slashChoicesLimit = 25
+)
+
+var (
+	// endpointSlashAPI is the api version interactions need, discordgo is still on v6
+	endpointSlashAPI = discordgo.EndpointDiscord + "api/v8/"
+)
+
+// ApplicationCommand is a slash command
+type ApplicationCommand struct {
+	ID            string                      `json:"id,omitempty"`
+	ApplicationID string                      `json:"application_id,omitempty"`
+	Name          string                      `json:"name"`
+	Description   string                      `json:"description"`
+	Options       []*ApplicationCommandOption `json:"options,omitempty"`
+}
+
+// ApplicationCommandOption is an option, subcommand or subcommand group of a slash command
+type ApplicationCommandOption struct {
+	Type        OptionType                  `json:"type"`
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Required    bool                        `json:"required,omitempty"`
+	Choices     []*OptionChoice             `json:"choices,omitempty"`
+	Options     []*ApplicationCommandOption `json:"options,omitempty"`
+}
+
+// OptionChoice is one of the fixed values a string option can take
+type OptionChoice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Interaction is an INTERACTION_CREATE event
+type Interaction struct {
+	ID            string            `json:"id"`
+	ApplicationID string            `json:"application_id"`
+	Type          InteractionType   `json:"type"`
+	Data          *InteractionData  `json:"data"`
+	GuildID       string            `json:"guild_id"`
+	ChannelID     string            `json:"channel_id"`
+	Member        *discordgo.Member `json:"member"`
+	User          *discordgo.User   `json:"user"`
+	Token         string            `json:"token"`
+
+	Message *discordgo.Message `json:"message"` // the message a component is on
+}
+
+// InteractionData is the slash command that was used and its options, or the component that was used
+type InteractionData struct {
+	ID      string               `json:"id"`
+	Name    string               `json:"name"`
+	Options []*InteractionOption `json:"options"`
+
+	CustomID      string        `json:"custom_id"`
+	ComponentType ComponentType `json:"component_type"`
+	Values        []string      `json:"values"`
+}
+
+// InteractionOption is an option given to a slash command, or the subcommand used
+type InteractionOption struct {
+	Name    string               `json:"name"`
+	Type    OptionType           `json:"type"`
+	Value   json.RawMessage      `json:"value"`
+	Options []*InteractionOption `json:"options"`
+}
+
+// String returns the option's value as the user would have typed it
+func (o *InteractionOption) String() string {
+	var str string
+	if err := json.Unmarshal(o.Value, &str); err == nil {
+		return str
+	}
+	return string(o.Value)
+}
+
+// ParseInteraction gets the Interaction out of a raw discordgo event
+//
+// Returns false if the event isn't an interaction
+func ParseInteraction(e *discordgo.Event) (*Interaction, bool) {
+	if e.Type != "INTERACTION_CREATE" {
+		return nil, false
+	}
+	var in Interaction
+	if err := json.Unmarshal(e.RawData, &in); err != nil {
+		return nil, false
+	}
+	return &in, true
+}
+
+// Path returns the words of the command the interaction is for, and the options given to it
+//
+// SlashBase is dropped, so /quote run gives the path [quote]
+func (in *Interaction) Path() (path []string, opts map[string]string) {
+	if in.Data == nil {
+		return nil, nil
+	}
+
+	path = []string{in.Data.Name}
+	options := in.Data.Options
+	for len(options) == 1 && (options[0].Type == OptionSubCommand || options[0].Type == OptionSubCommandGroup) {
+		if options[0].Name != SlashBase {
+			path = append(path, options[0].Name)
+		}
+		options = options[0].Options
+	}
+
+	opts = make(map[string]string)
+	for _, opt := range options {
+		opts[opt.Name] = opt.String()
+	}
+	return path, opts
+}
+
+// Author returns the user that used the interaction, in a guild or not
+func (in *Interaction) Author() *discordgo.User {
+	if in.Member != nil && in.Member.User != nil {
+		return in.Member.User
+	}
+	return in.User
+}
+
+// CommandMessage returns a message standing in for the interaction, for command handlers
+func (in *Interaction) CommandMessage() *discordgo.Message {
+	path, _ := in.Path()
+
+	return &discordgo.Message{
+		ID:        in.ID,
+		ChannelID: in.ChannelID,
+		GuildID:   in.GuildID,
+		Author:    in.Author(),
+		Member:    in.Member,
+		Content:   "/" + strings.Join(path, " "),
+	}
+}
+
+/* building slash commands */
+
+// slashNode is a word in the tree of command aliases
+type slashNode struct {
+	com      Command
+	children map[string]*slashNode
+}
+
+// SlashCommands turns commands into slash commands, using each command's first alias
+//
+// Multi-word aliases become subcommands e.g. !tags add is /tags add,
+// and commands that also have subcommands are called with SlashBase e.g. /tags run.
+// Options come from arg and flag fields, in the same order as FillArgs takes them.
+// Aliases that are more than three words or that can't be slash command names are skipped.
+func SlashCommands(coms []Command) []*ApplicationCommand {
+	root := &slashNode{children: make(map[string]*slashNode)}
+	for _, com := range coms {
+		words := strings.Fields(com.Aliases()[0])
+		if len(words) > 3 {
+			continue
+		}
+
+		node := root
+		for _, word := range words {
+			name := slashName(word)
+			if len(name) == 0 {
+				node = nil
+				break
+			}
+			next, ok := node.children[name]
+			if !ok {
+				next = &slashNode{children: make(map[string]*slashNode)}
+				node.children[name] = next
+			}
+			node = next
+		}
+		if node != nil {
+			node.com = com
+		}
+	}
+
+	out := []*ApplicationCommand{}
+	for _, name := range slashSorted(root.children) {
+		node := root.children[name]
+		out = append(out, &ApplicationCommand{
+			Name:        name,
+			Description: slashDesc(node.com, name),
+			Options:     node.options(),
+		})
+	}
+	return out
+}
+
+// options returns the node's subcommands and groups if it has children, or its command's options
+func (n *slashNode) options() []*ApplicationCommandOption {
+	if len(n.children) == 0 {
+		return slashOptions(n.com)
+	}
+
+	opts := []*ApplicationCommandOption{}
+	if n.com != nil {
+		opts = append(opts, &ApplicationCommandOption{
+			Type:        OptionSubCommand,
+			Name:        SlashBase,
+			Description: slashDesc(n.com, SlashBase),
+			Options:     slashOptions(n.com),
+		})
+	}
+	for _, name := range slashSorted(n.children) {
+		child := n.children[name]
+		typ := OptionSubCommand
+		if len(child.children) > 0 {
+			typ = OptionSubCommandGroup
+		}
+		opts = append(opts, &ApplicationCommandOption{
+			Type:        typ,
+			Name:        name,
+			Description: slashDesc(child.com, name),
+			Options:     child.options(),
+		})
+	}
+	return opts
+}
+
+// slashOptions returns the options for a command's arg and flag fields
+func slashOptions(c Command) []*ApplicationCommandOption {
+	val := reflect.ValueOf(c)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	args := []*ApplicationCommandOption{}
+	flags := []*ApplicationCommandOption{}
+	for i := 0; i < val.NumField(); i++ {
+		ft := val.Type().Field(i)
+		tag, isArg := ft.Tag.Lookup("arg")
+		flag, isFlag := ft.Tag.Lookup("flag")
+		if !isArg && !isFlag {
+			continue
+		}
+		if isFlag {
+			tag = flag
+		}
+
+		opt := &ApplicationCommandOption{
+			Type:        OptionString,
+			Name:        slashName(tag),
+			Description: tag + ": " + argTypeName(ft, ft.Type, false),
+			Required:    isArg && !argOptional(ft) && ft.Type.Kind() != reflect.Slice,
+		}
+
+		switch {
+		case ft.Type.Kind() == reflect.Slice:
+			opt.Description = tag + ": " + argTypeName(ft, ft.Type.Elem(), true) + ", separated by spaces"
+		case ft.Type == userType || ft.Type == memberType:
+			opt.Type = OptionUser
+		case ft.Type == channelType:
+			opt.Type = OptionChannel
+		case ft.Type == roleType:
+			opt.Type = OptionRole
+		case ft.Type.Kind() == reflect.Int:
+			opt.Type = OptionInteger
+		case ft.Type.Kind() == reflect.Float64:
+			opt.Type = OptionNumber
+		case ft.Type.Kind() == reflect.Bool:
+			opt.Type = OptionBoolean
+		}
+
+		if enum, ok := ft.Tag.Lookup("enum"); ok && ft.Type.Kind() == reflect.String {
+			vals := strings.Split(enum, ",")
+			if len(vals) <= slashChoicesLimit {
+				for _, v := range vals {
+					opt.Choices = append(opt.Choices, &OptionChoice{v, v})
+				}
+			}
+		}
+
+		if len(opt.Description) > slashDescLimit {
+			opt.Description = truncate(opt.Description, slashDescLimit)
+		}
+		if isFlag {
+			flags = append(flags, opt)
+		} else {
+			args = append(args, opt)
+		}
+	}
+
+	// discord wants required options first, flags are never required
+	return append(args, flags...)
+}
+
+// slashName makes a valid slash command or option name, empty if nothing is left
+func slashName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return '-'
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+	return truncate(name, slashNameLimit)
+}
+
+// slashDesc returns a command's description cut down to size, or a stand-in for groups
+func slashDesc(c Command, name string) string {
+	desc := ""
+	if c != nil {
+		desc = strings.TrimSpace(c.Desc())
+	}
+	if len(desc) == 0 {
+		desc = name + " commands"
+	}
+	return truncate(desc, slashDescLimit)
+}
+
+// slashSorted returns the names of the nodes in order
+func slashSorted(nodes map[string]*slashNode) []string {
+	names := []string{}
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// truncate cuts s down to at most limit runes
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit-1]) + "…"
+}
+
+// RegisterSlashCommands replaces the application's slash commands with coms
+//
+// Commands are registered in the guild if guildID is set, which is instant,
+// otherwise they're global and can take up to an hour to show up.
+func RegisterSlashCommands(ses Session, appID, guildID string, coms []*ApplicationCommand) error {
+	url := endpointSlashAPI + "applications/" + appID + "/commands"
+	if len(guildID) > 0 {
+		url = endpointSlashAPI + "applications/" + appID + "/guilds/" + guildID + "/commands"
+	}
+	_, err := ses.RequestWithBucketID("PUT", url, coms, url)
+	return err
+}
+
+/* filling args */
+
+// FillOptions fills a command's arg and flag fields from slash command options, keyed by slashName
+//
+// Missing options get their field's default, and FillOptions returns
+// ErrNotEnoughArgs if a required option is missing or an *ArgError if one can't be parsed.
+// Slice fields are given space-separated values.
+func FillOptions(ses Session, msg *discordgo.Message, c Command, opts map[string]string) error {
+	val := reflect.ValueOf(c)
+	if val.Kind() == reflect.Ptr {
+		// unroll pointer
+		val = val.Elem()
+		if !val.IsValid() {
+			panic(fmt.Sprintf("FillOptions: %#v is not valid\n", val))
+		}
+	}
+
+	if val.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("FillOptions: %#v is not a struct\n", val))
+	}
+
+	for i := 0; i < val.NumField(); i++ {
+		ft := val.Type().Field(i)
+		fv := val.Field(i)
+		tag, isArg := ft.Tag.Lookup("arg")
+		flag, isFlag := ft.Tag.Lookup("flag")
+		if !isArg && !isFlag {
+			continue
+		}
+		if !fv.CanSet() {
+			panic("FillOptions: using unexported field with arg tag")
+		}
+		if isFlag {
+			tag = flag
+		}
+
+		opt, ok := opts[slashName(tag)]
+		if !ok {
+			if isArg && !argOptional(ft) && fv.Kind() != reflect.Slice {
+				return ErrNotEnoughArgs
+			}
+			fv.Set(argDefault(ft))
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice {
+			elemType := fv.Type().Elem()
+			sv := reflect.MakeSlice(reflect.SliceOf(elemType), 0, 0)
+			for _, arg := range Tokenize(opt).Args {
+				got, err := parseArg(ses, msg, ft, elemType, arg)
+				if err != nil {
+					return err
+				}
+				sv = reflect.Append(sv, got)
+			}
+			fv.Set(sv)
+			continue
+		}
+
+		got, err := parseArg(ses, msg, ft, fv.Type(), opt)
+		if err != nil {
+			return err
+		}
+		fv.Set(got)
+	}
+	return nil
+}
+
+/* replying */
+
+// interactionSession is a Session that replies to an interaction
+// instead of sending messages to the interaction's channel
+//
+// The first message edits the deferred response, later ones are follow ups.
+// Messages with files and messages to other channels are sent as usual.
+type interactionSession struct {
+	Session
+	in *Interaction
+
+	mu      sync.Mutex
+	replied bool
+}
+
+// webhookMessage is the body of an interaction response or follow up, or a message with components
+type webhookMessage struct {
+	Content    string                    `json:"content"`
+	Embeds     []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+	Components []*Component              `json:"components,omitempty"`
+	Flags      int                       `json:"flags,omitempty"`
+}
+
+// newWebhookMessage returns the body for a MessageSend, files are left out
+func newWebhookMessage(data *discordgo.MessageSend) *webhookMessage {
+	body := &webhookMessage{Content: data.Content}
+	if data.Embed != nil {
+		body.Embeds = []*discordgo.MessageEmbed{data.Embed}
+	}
+	return body
+}
+
+// respond sends the first response to an interaction, data can be nil for deferred responses
+func respond(ses Session, in *Interaction, typ int, data *webhookMessage) error {
+	url := endpointSlashAPI + "interactions/" + in.ID + "/" + in.Token + "/callback"
+	body := map[string]interface{}{"type": typ}
+	if data != nil {
+		body["data"] = data
+	}
+	_, err := ses.RequestWithBucketID("POST", url, body, endpointSlashAPI+"interactions/callback")
+	return err
+}
+
+func (s *interactionSession) webhookURL() string {
+	return endpointSlashAPI + "webhooks/" + s.in.ApplicationID + "/" + s.in.Token
+}
+
+// ack tells discord we got the interaction and will reply soon
+func (s *interactionSession) ack() error {
+	return respond(s.Session, s.in, responseDeferredMessage, nil)
+}
+
+// finish removes the deferred response if nothing was sent, so it isn't left thinking forever
+func (s *interactionSession) finish() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.replied {
+		return nil
+	}
+	s.replied = true
+	_, err := s.Session.RequestWithBucketID("DELETE", s.webhookURL()+"/messages/@original", nil,
+		endpointSlashAPI+"webhooks/"+s.in.ApplicationID)
+	return err
+}
+
+func (s *interactionSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (s *interactionSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embed: embed})
+}
+
+func (s *interactionSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if channelID != s.in.ChannelID || data.File != nil || len(data.Files) > 0 {
+		return s.Session.ChannelMessageSendComplex(channelID, data)
+	}
+	return s.send(channelID, newWebhookMessage(data))
+}
+
+// send replies to the interaction with the body
+func (s *interactionSession) send(channelID string, body *webhookMessage) (*discordgo.Message, error) {
+	s.mu.Lock()
+	method, url := "POST", s.webhookURL()+"?wait=true"
+	if !s.replied {
+		method, url = "PATCH", s.webhookURL()+"/messages/@original"
+		s.replied = true
+	}
+	s.mu.Unlock()
+
+	res, err := s.Session.RequestWithBucketID(method, url, body, endpointSlashAPI+"webhooks/"+s.in.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg discordgo.Message
+	err = json.Unmarshal(res, &msg)
+	if err != nil {
+		return nil, err
+	}
+	msg.ChannelID = channelID
+	if len(msg.Content) == 0 {
+		msg.Content = body.Content
+	}
+	return &msg, nil
+}
+
+// DispatchInteraction sends a slash command through the chain, replying to the interaction
+//
+// Components are handed to their ComponentSet, other kinds of interactions are ignored.
+func (d *Dispatcher) DispatchInteraction(ses Session, in *Interaction) error {
+	if in.Data == nil {
+		return nil
+	}
+	if in.Type == InteractionMessageComponent {
+		return handleComponent(ses, in)
+	}
+	if in.Type != InteractionApplicationCommand {
+		return nil
+	}
+
+	isl := &interactionSession{Session: ses, in: in}
+	err := isl.ack()
+	if err != nil {
+		return err
+	}
+
+	path, opts := in.Path()
+	err = d.handler(&Context{
+		Session: isl,
+		Message: in.CommandMessage(),
+		Tokens:  newTokens(path),
+		Options: opts,
+	})
+
+	if ferr := isl.finish(); err == nil {
+		err = ferr
+	}
+	return err
+}
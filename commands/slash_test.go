@@ -0,0 +1,173 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestSlashCommands verifies that SlashCommands will:
+// - Make multi-word aliases into subcommands and groups
+// - Call commands that have subcommands with SlashBase
+// - Turn arg and flag fields into options of the right type
+func TestSlashCommands(t *testing.T) {
+	coms := []Command{
+		&Role{name: "tags"},
+		&Role{name: "tags add"},
+		&Role{name: "tags mod remove"},
+		&Role{name: "weeb"},
+		&Rich{},
+		&Flagged{},
+	}
+	got := SlashCommands(coms)
+
+	names := []string{}
+	for _, ac := range got {
+		names = append(names, ac.Name)
+	}
+	exp := []string{"flagged", "rich", "tags", "weeb"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatalf("SlashCommands made %v; want %v", names, exp)
+	}
+
+	tags := got[2]
+	if len(tags.Options) != 3 {
+		t.Fatalf("/tags has %d options; want 3", len(tags.Options))
+	}
+	subs := []struct {
+		name string
+		typ  OptionType
+	}{
+		{SlashBase, OptionSubCommand},
+		{"add", OptionSubCommand},
+		{"mod", OptionSubCommandGroup},
+	}
+	for i, sub := range subs {
+		if tags.Options[i].Name != sub.name || tags.Options[i].Type != sub.typ {
+			t.Errorf("/tags option %d is %s (%d); want %s (%d)", i, tags.Options[i].Name, tags.Options[i].Type, sub.name, sub.typ)
+		}
+	}
+	if rm := tags.Options[2].Options; len(rm) != 1 || rm[0].Name != "remove" || len(rm[0].Options) != 1 {
+		t.Errorf("/tags mod has options %#v; want remove with one option", rm)
+	}
+
+	types := []OptionType{OptionUser, OptionChannel, OptionRole, OptionString, OptionString, OptionNumber, OptionString, OptionString}
+	rich := got[1].Options
+	if len(rich) != len(types) {
+		t.Fatalf("/rich has %d options; want %d", len(rich), len(types))
+	}
+	for i, typ := range types {
+		if rich[i].Type != typ {
+			t.Errorf("/rich option %s has type %d; want %d", rich[i].Name, rich[i].Type, typ)
+		}
+	}
+	if len(rich[6].Choices) != 2 {
+		t.Errorf("/rich enum option has choices %v; want on and off", rich[6].Choices)
+	}
+
+	// flags come last and aren't required
+	flagged := got[0].Options
+	last := flagged[len(flagged)-1]
+	if last.Required || !flagged[0].Required {
+		t.Errorf("/flagged options are %#v; want required args before flags", flagged)
+	}
+}
+
+// TestDispatchInteraction sends slash commands through the default middleware and verifies that
+// - The interaction is acknowledged then replied to
+// - Missing options reply with usage
+// - Interactions that don't reply have their response removed
+func TestDispatchInteraction(t *testing.T) {
+	logf := func(format string, v ...interface{}) {}
+	dis := NewDispatcher(DefaultMiddleware(newSayRoute(nil), nil, nil, NewHistory(), NewCooldowns(false), logf, logf)...)
+
+	tests := []struct {
+		raw     string
+		methods []string
+		exp     string // content of the reply
+	}{
+		{
+			`{"name":"say","options":[{"name":"times","type":4,"value":2},{"name":"words","type":3,"value":"hi "}]}`,
+			[]string{"POST", "PATCH"},
+			"hi hi ",
+		},
+		{
+			`{"name":"say","options":[{"name":"words","type":3,"value":"hi"}]}`,
+			[]string{"POST", "PATCH"},
+			"Usage: ",
+		},
+		{
+			`{"name":"nothing"}`,
+			[]string{"POST", "DELETE"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		ses := newFake()
+		in := &Interaction{
+			ID:            "100",
+			ApplicationID: "0",
+			Type:          InteractionApplicationCommand,
+			GuildID:       "1",
+			ChannelID:     "3",
+			Member:        &discordgo.Member{User: &discordgo.User{ID: "4"}},
+			Token:         "token",
+		}
+		if err := json.Unmarshal([]byte(tt.raw), &in.Data); err != nil {
+			t.Fatal(err)
+		}
+
+		dis.DispatchInteraction(ses, in)
+		reqs := ses.Requests()
+		if len(reqs) != len(tt.methods) {
+			t.Errorf("/%s made %d requests; want %d", in.Data.Name, len(reqs), len(tt.methods))
+			continue
+		}
+		for i, req := range reqs {
+			if req.Method != tt.methods[i] {
+				t.Errorf("/%s request %d is %s %s; want %s", in.Data.Name, i, req.Method, req.URL, tt.methods[i])
+			}
+		}
+		if len(ses.Messages("3")) != 0 {
+			t.Errorf("/%s sent to the channel instead of replying", in.Data.Name)
+		}
+
+		if tt.exp == "" {
+			continue
+		}
+		mar, _ := json.Marshal(reqs[1].Data)
+		var body struct{ Content string }
+		json.Unmarshal(mar, &body)
+		if !strings.Contains(body.Content, tt.exp) {
+			t.Errorf("/%s replied %q; want %q", in.Data.Name, body.Content, tt.exp)
+		}
+	}
+}
+
+// TestInteractionPath verifies that subcommands are followed and SlashBase is dropped
+func TestInteractionPath(t *testing.T) {
+	in := &Interaction{}
+	raw := `{"name":"tags","options":[{"name":"mod","type":2,"options":[{"name":"remove","type":1,"options":[{"name":"user","type":6,"value":"4"}]}]}]}`
+	if err := json.Unmarshal([]byte(raw), &in.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	path, opts := in.Path()
+	if !reflect.DeepEqual(path, []string{"tags", "mod", "remove"}) || opts["user"] != "4" {
+		t.Errorf("Path() = %v, %v; want [tags mod remove], map[user:4]", path, opts)
+	}
+
+	raw = `{"name":"quote","options":[{"name":"run","type":1,"options":[{"name":"index","type":4,"value":3}]}]}`
+	if err := json.Unmarshal([]byte(raw), &in.Data); err != nil {
+		t.Fatal(err)
+	}
+	path, opts = in.Path()
+	if !reflect.DeepEqual(path, []string{"quote"}) || opts["index"] != "3" {
+		t.Errorf("Path() = %v, %v; want [quote], map[index:3]", path, opts)
+	}
+}
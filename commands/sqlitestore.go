@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+)
+
+const (
+	sqliteSchema = `CREATE TABLE IF NOT EXISTS kv (
+		key     TEXT PRIMARY KEY,
+		val     TEXT NOT NULL,
+		expires INTEGER NOT NULL DEFAULT 0
+	)`
+)
+
+// sqliteStore implements the Store interface with a sqlite file, one row for each key
+//
+// Keys use sqlite's default bytewise collation so they're in the same order as the other Stores.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// one connection, so transactions take turns and ":memory:" is one db
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db}, nil
+}
+
+func (s *sqliteStore) View(view func(tx StoreTx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return view(&sqliteTx{tx: tx, now: time.Now()})
+}
+
+func (s *sqliteStore) Update(update func(tx StoreTx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = update(&sqliteTx{tx: tx, now: time.Now(), writable: true})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Close() error { return s.db.Close() }
+
+// sqliteTx implements the StoreTx interface
+type sqliteTx struct {
+	tx       *sql.Tx
+	now      time.Time
+	writable bool
+}
+
+// live is the condition for rows that haven't expired
+const live = `(expires = 0 OR expires > ?)`
+
+func (s *sqliteTx) Get(key string) (string, error) {
+	var val string
+	err := s.tx.QueryRow(`SELECT val FROM kv WHERE key = ? AND `+live, key, s.now.UnixNano()).Scan(&val)
+	if err == sql.ErrNoRows {
+		return "", ErrDBNotFound
+	}
+	return val, err
+}
+
+func (s *sqliteTx) Set(key string, val string, ttl time.Duration) error {
+	if !s.writable {
+		return errReadOnly
+	}
+	var expires int64
+	if ttl > 0 {
+		expires = s.now.Add(ttl).UnixNano()
+	}
+	_, err := s.tx.Exec(`INSERT OR REPLACE INTO kv (key, val, expires) VALUES (?, ?, ?)`, key, val, expires)
+	return err
+}
+
+func (s *sqliteTx) Delete(key string) error {
+	if !s.writable {
+		return errReadOnly
+	}
+	res, err := s.tx.Exec(`DELETE FROM kv WHERE key = ? AND `+live, key, s.now.UnixNano())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDBNotFound
+	}
+	return nil
+}
+
+func (s *sqliteTx) TTL(key string) (time.Duration, error) {
+	var expires int64
+	err := s.tx.QueryRow(`SELECT expires FROM kv WHERE key = ? AND `+live, key, s.now.UnixNano()).Scan(&expires)
+	if err == sql.ErrNoRows {
+		return 0, ErrDBNotFound
+	}
+	if err != nil || expires == 0 {
+		return 0, err
+	}
+	return time.Unix(0, expires).Sub(s.now), nil
+}
+
+func (s *sqliteTx) AscendPrefix(prefix string, iter func(key, val string) bool) error {
+	rows, err := s.tx.Query(`SELECT key, val FROM kv WHERE key >= ? AND `+live+` ORDER BY key`, prefix, s.now.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	// read them all first, the connection is busy until rows is closed
+	type kv struct{ key, val string }
+	kvs := []kv{}
+	for rows.Next() {
+		var k kv
+		if err = rows.Scan(&k.key, &k.val); err != nil {
+			rows.Close()
+			return err
+		}
+		if !strings.HasPrefix(k.key, prefix) {
+			break
+		}
+		kvs = append(kvs, k)
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range kvs {
+		if !iter(k.key, k.val) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStore is the Store DBOpen uses
+	DefaultStore = "buntdb"
+)
+
+var (
+	// ErrDBStore means there's no Store with that name, see AddStore
+	ErrDBStore = errors.New("no db store with that name")
+
+	dbStore    = "" // name of the store DB is, empty if it's not open
+	stores     = make(map[string]func(path string) (Store, error))
+	storesLock = &sync.RWMutex{}
+)
+
+func init() {
+	AddStore("buntdb", openBuntStore)
+	AddStore("sqlite", openSQLiteStore)
+	AddStore("memory", openMemoryStore)
+}
+
+/* stores */
+
+// Store is what keeps the db's keys and values, see DBOpenStore
+//
+// Keys are ordered bytewise so everything with a prefix is together.
+// Transactions are all or nothing, and only one that changes things runs at a time.
+type Store interface {
+	View(view func(tx StoreTx) error) error     // read only transaction
+	Update(update func(tx StoreTx) error) error // read-write transaction, rolled back if update returns an error
+	Close() error
+}
+
+// StoreTx is a transaction on a Store
+type StoreTx interface {
+	Get(key string) (string, error)                                    // ErrDBNotFound if there's nothing or it's expired
+	Set(key string, val string, ttl time.Duration) error               // expires after ttl, never if it's 0
+	Delete(key string) error                                           // ErrDBNotFound if there's nothing
+	TTL(key string) (time.Duration, error)                             // time until it expires, 0 if it never does
+	AscendPrefix(prefix string, iter func(key, val string) bool) error // in key order until iter returns false
+}
+
+// IndexStore is a Store that can keep secondary indexes on a JSON field of the values at keys with a prefix
+//
+// Collections use them if the Store has them, otherwise they look at every record.
+type IndexStore interface {
+	Store
+	CreateIndex(name string, prefix string, field string) error // does nothing if it's there already
+}
+
+// IndexStoreTx is a transaction on an IndexStore
+type IndexStoreTx interface {
+	StoreTx
+	AscendIndex(name string, field string, value string, iter func(key, val string) bool) error
+}
+
+// AddStore adds a Store for DBOpenStore to open by name, replacing any with that name
+func AddStore(name string, open func(path string) (Store, error)) {
+	storesLock.Lock()
+	defer storesLock.Unlock()
+	stores[name] = open
+}
+
+// Stores returns the names of the Stores, ordered
+func Stores() []string {
+	storesLock.RLock()
+	defer storesLock.RUnlock()
+
+	names := []string{}
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenStore opens the named Store at path, without making it the db
+//
+// Use ":memory:" as the path to keep everything in memory.
+func OpenStore(name string, path string) (Store, error) {
+	storesLock.RLock()
+	open, ok := stores[name]
+	storesLock.RUnlock()
+	if !ok {
+		return nil, ErrDBStore
+	}
+	return open(path)
+}
+
+// CopyStore copies everything in from into to, keeping when it expires, and returns how many keys it copied
+//
+// Keys already in to are overwritten, everything is copied or nothing is.
+func CopyStore(to Store, from Store) (int, error) {
+	n := 0
+	err := from.View(func(ftx StoreTx) error {
+		return to.Update(func(ttx StoreTx) error {
+			var err error
+			ierr := ftx.AscendPrefix("", func(key, val string) bool {
+				var ttl time.Duration
+				ttl, err = ftx.TTL(key)
+				if err == ErrDBNotFound {
+					// expired while copying
+					err = nil
+					return true
+				}
+				if err != nil {
+					return false
+				}
+				if err = ttx.Set(key, val, ttl); err != nil {
+					return false
+				}
+				n++
+				return true
+			})
+			if ierr != nil {
+				return ierr
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// matchKey returns whether key matches the pattern, where * matches anything
+func matchKey(pattern string, key string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return key == pattern
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	return len(key) >= len(last) && strings.HasSuffix(key, last)
+}
@@ -0,0 +1,153 @@
+package commands_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestStores does the same things in every Store
+func TestStores(t *testing.T) {
+	for _, name := range Stores() {
+		st, err := OpenStore(name, ":memory:")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		testStore(t, name, st)
+		st.Close()
+	}
+
+	if _, err := OpenStore("nope", ":memory:"); err != ErrDBStore {
+		t.Errorf("OpenStore(nope) gave %v; want %v", err, ErrDBStore)
+	}
+}
+
+func testStore(t *testing.T, name string, st Store) {
+	err := st.Update(func(tx StoreTx) error {
+		for _, key := range []string{"a:2", "a:1", "ab", "b:1"} {
+			if err := tx.Set(key, key, 0); err != nil {
+				return err
+			}
+		}
+		return tx.Set("a:3", "gone", time.Nanosecond)
+	})
+	if err != nil {
+		t.Fatal(name, err)
+	}
+
+	// a failed transaction changes nothing
+	bad := errors.New("bad")
+	err = st.Update(func(tx StoreTx) error {
+		tx.Set("a:1", "changed", 0)
+		tx.Set("a:4", "new", 0)
+		tx.Delete("b:1")
+		return bad
+	})
+	if err != bad {
+		t.Errorf("%s: Update gave %v; want %v", name, err, bad)
+	}
+
+	time.Sleep(time.Millisecond)
+	err = st.View(func(tx StoreTx) error {
+		got := ""
+		tx.AscendPrefix("a:", func(key, val string) bool {
+			got += val + " "
+			return true
+		})
+		if exp := "a:1 a:2 "; got != exp {
+			t.Errorf("%s: AscendPrefix(a:) gave %q; want %q", name, got, exp)
+		}
+
+		if val, err := tx.Get("b:1"); err != nil || val != "b:1" {
+			t.Errorf("%s: Get(b:1) gave %q, %v; want b:1", name, val, err)
+		}
+		if _, err := tx.Get("a:3"); err != ErrDBNotFound {
+			t.Errorf("%s: Get on an expired key gave %v; want %v", name, err, ErrDBNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(name, err)
+	}
+
+	err = st.Update(func(tx StoreTx) error {
+		if err := tx.Set("c", "c", time.Hour); err != nil {
+			return err
+		}
+		if ttl, err := tx.TTL("c"); err != nil || ttl <= 0 || ttl > time.Hour {
+			t.Errorf("%s: TTL(c) gave %v, %v; want under an hour", name, ttl, err)
+		}
+		if ttl, err := tx.TTL("ab"); err != nil || ttl != 0 {
+			t.Errorf("%s: TTL(ab) gave %v, %v; want 0", name, ttl, err)
+		}
+		if err := tx.Delete("ab"); err != nil {
+			return err
+		}
+		if err := tx.Delete("ab"); err != ErrDBNotFound {
+			t.Errorf("%s: Delete on a deleted key gave %v; want %v", name, err, ErrDBNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(name, err)
+	}
+}
+
+// TestCopyStore copies between two different Stores, keeping expiry
+func TestCopyStore(t *testing.T) {
+	from, _ := OpenStore("memory", "")
+	to, err := OpenStore("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer to.Close()
+
+	from.Update(func(tx StoreTx) error {
+		tx.Set("thing:1", "one", 0)
+		tx.Set("cooldown:1", "soon", time.Hour)
+		return nil
+	})
+
+	n, err := CopyStore(to, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("CopyStore copied %d keys; want 2", n)
+	}
+
+	to.View(func(tx StoreTx) error {
+		if val, err := tx.Get("thing:1"); val != "one" {
+			t.Errorf("copied thing:1 is %q, %v; want one", val, err)
+		}
+		if ttl, err := tx.TTL("cooldown:1"); ttl <= 0 {
+			t.Errorf("copied cooldown:1 has TTL %v, %v; want it to expire", ttl, err)
+		}
+		return nil
+	})
+}
+
+// TestCollectionNoIndex finds records by field in a Store without indexes
+func TestCollectionNoIndex(t *testing.T) {
+	old := DB
+	DB, _ = OpenStore("memory", "")
+	defer func() { DB = old }()
+
+	DBSet(&record{Owner: "bob", Count: 1}, RecordKey("1", "a"))
+	DBSet(&record{Owner: "alice", Count: 2}, RecordKey("1", "b"))
+	DBSet(&record{Owner: "bob", Count: 4}, RecordKey("2", "a"))
+
+	n := 0
+	err := records.AscendField("Owner", "bob", RecordPrefix("1"), func(key string, v Storer) bool {
+		n += v.(*record).Count
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("AscendField(Owner, bob) counted %d; want 1", n)
+	}
+}
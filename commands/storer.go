@@ -10,8 +10,8 @@ import (
 )
 
 var (
-	// DB is the database
-	DB *buntdb.DB
+	// DB is the database, nil until it's opened
+	DB Store
 
 	// ErrDBNotOpen means db wasn't opened when trying to use it
 	ErrDBNotOpen = errors.New("db not open, use DBOpen()")
@@ -26,6 +26,9 @@ var (
 	// ErrDBNotFound means there is no db
 	ErrDBNotFound = buntdb.ErrNotFound
 
+	// ErrDBKeyCount means you didn't give one key for each Storer
+	ErrDBKeyCount = errors.New("need one key for each storer")
+
 	// ErrStorerNil means you have made bad life decisions
 	ErrStorerNil = errors.New("storer method received nil")
 
@@ -44,12 +47,26 @@ type Storer interface {
 	Index() string // Determines db index
 }
 
-// DBOpen opens the db at the given path
+// DBOpen opens the db at the given path in the DefaultStore, then runs the migrations it needs, see AddMigration
 func DBOpen(path string) error {
-	var err error
-	DB, err = buntdb.Open(path)
-	DB.Shrink()
-	return err
+	return DBOpenStore(DefaultStore, path)
+}
+
+// DBOpenStore is DBOpen with the named Store, see Stores
+func DBOpenStore(name string, path string) error {
+	st, err := OpenStore(name, path)
+	if err != nil {
+		return err
+	}
+	DB = st
+	dbStore = name
+	forgetDisabled("")
+
+	err = createCollectionIndexes()
+	if err != nil {
+		return err
+	}
+	return migrateOnOpen(path)
 }
 
 // DBClose closes the db
@@ -62,6 +79,8 @@ func DBClose() error {
 		return err
 	}
 	DB = nil
+	dbStore = ""
+	forgetDisabled("")
 	return nil
 }
 
@@ -78,40 +97,36 @@ func DBSet(s Storer, key string) (previous string, replaced bool, err error) {
 		return "", false, ErrDBKeyEmpty
 	}
 
-	// Begin RW transaction
-	tx, err := DB.Begin(true)
-	if err != nil {
-		tx.Rollback()
-		return "", false, err
-	}
-
 	// Marshal storer
 	mar, err := json.Marshal(s)
 	if err != nil {
-		tx.Rollback()
 		return "", false, err
 	}
 
-	// Set marshalled key/value pair
-	pre, rep, err := tx.Set(s.Index()+":"+key, string(mar), nil)
+	// Set marshalled key/value pair in a RW transaction
+	var pre string
+	var rep bool
+	err = DB.Update(func(tx StoreTx) error {
+		var err error
+		pre, err = tx.Get(s.Index() + ":" + key)
+		if err != nil && err != ErrDBNotFound {
+			return err
+		}
+		rep = err == nil
+		return tx.Set(s.Index()+":"+key, string(mar), 0)
+	})
 	if err != nil {
-		tx.Rollback()
-		return "", false, err
-	}
-
-	// Commit changes
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
 		return "", false, err
 	}
 
 	return pre, rep, nil
 }
 
-// DBGet gets the Storer at the given key and puts it into got. Ignores expiry.
+// DBGet gets the Storer at the given key and puts it into got.
 //
-// If got is not a pointer, DBGet will throw ErrDBNotPtr
+// If got is not a pointer, DBGet will throw ErrDBNotPtr.
+// Expired values are ErrDBNotFound, unlike before Stores when DBGet returned them,
+// nothing set with DBSet expires so only values given a ttl directly are affected.
 func DBGet(s Storer, key string, got Storer) error {
 	if DB == nil {
 		return ErrDBNotOpen
@@ -123,15 +138,13 @@ func DBGet(s Storer, key string, got Storer) error {
 		return ErrDBNotPtr
 	}
 
-	// Open RO Transaction, defer rollback
-	tx, err := DB.Begin(false)
-	if err != nil {
+	// Get Storer in a RO transaction
+	var res string
+	err := DB.View(func(tx StoreTx) error {
+		var err error
+		res, err = tx.Get(s.Index() + ":" + key)
 		return err
-	}
-	defer tx.Rollback()
-
-	// Get Storer
-	res, err := tx.Get(s.Index()+":"+key, true)
+	})
 	if err != nil {
 		return err
 	}
@@ -140,10 +153,82 @@ func DBGet(s Storer, key string, got Storer) error {
 	return json.Unmarshal([]byte(res), got)
 }
 
+// DBUpdate gets the Storer at the key into s, calls update with it, then sets it back, all in one transaction
+//
+// s is left as it is if there's nothing at the key, so give it any defaults first.
+// Nothing is set if update returns an error, which DBUpdate returns.
+// update mustn't use the other DB functions, they wait for the transaction to finish.
+func DBUpdate(s Storer, key string, update func(v Storer) error) error {
+	return DBUpdateMany([]Storer{s}, []string{key}, func(vs []Storer) error {
+		return update(vs[0])
+	})
+}
+
+// DBUpdateMany is DBUpdate for several Storers at once, each at the key with the same index
+//
+// Either all of them are set or none are, e.g. for moving something from one to another.
+func DBUpdateMany(ss []Storer, keys []string, update func(vs []Storer) error) error {
+	if DB == nil {
+		return ErrDBNotOpen
+	}
+	if len(ss) != len(keys) {
+		return ErrDBKeyCount
+	}
+	if update == nil {
+		return ErrStorerNil
+	}
+	for i, s := range ss {
+		if s == nil {
+			return ErrStorerNil
+		}
+		if reflect.TypeOf(s).Kind() != reflect.Ptr {
+			return ErrDBNotPtr
+		}
+		if len(keys[i]) == 0 {
+			return ErrDBKeyEmpty
+		}
+	}
+
+	// an error rolls back everything
+	return DB.Update(func(tx StoreTx) error {
+		for i, s := range ss {
+			res, err := tx.Get(s.Index() + ":" + keys[i])
+			if err == ErrDBNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err = json.Unmarshal([]byte(res), s); err != nil {
+				return err
+			}
+		}
+
+		if err := update(ss); err != nil {
+			return err
+		}
+
+		for i, s := range ss {
+			mar, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			if err = tx.Set(s.Index()+":"+keys[i], string(mar), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // DBLock locks the db
+//
+// Deprecated: it only keeps out code that also locks, use DBUpdate to change what's in the db.
 func DBLock() { lock.Lock() }
 
 // DBUnlock unlocks the db
+//
+// Deprecated: see DBLock.
 func DBUnlock() { lock.Unlock() }
 
 // DBNewOnce refreshes the once primitive
@@ -2,9 +2,11 @@ package commands_test
 
 import (
 	"encoding/json"
+	"errors"
 	//"fmt"
 	"os"
 	"testing"
+	"time"
 
 	. "github.com/unswpcsoc/pcsocgo/commands"
 )
@@ -24,38 +26,38 @@ func (t *thing) Index() string { return "thing" }
 func TestMain(m *testing.M) {
 	DBOpen(":memory:")
 
-	// Create debug index, ignore errors
-	tx, _ := DB.Begin(true)
-	tx.CreateIndex("debug", "*", func(a, b string) bool {
-		return a < b
-	})
-	tx.Commit()
-
 	res := m.Run()
 	DBClose()
 	os.Exit(res)
 }
 
+// clearDB removes everything from the db
+func clearDB(t *testing.T) {
+	err := DB.Update(func(tx StoreTx) error {
+		keys := []string{}
+		tx.AscendPrefix("", func(key, val string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		for _, key := range keys {
+			if err := tx.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 /* tests */
 
 // TestDBGet directly puts a Storer into the DB
 // and tests whether DBGet can retrieve it without panicking
 func TestDBGet(t *testing.T) {
 	// Setup
-	tx, err := DB.Begin(true)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = tx.DeleteAll()
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		t.Error(err)
-	}
+	clearDB(t)
 
 	// Set expected
 	exp := thing{
@@ -63,31 +65,20 @@ func TestDBGet(t *testing.T) {
 		B: 42,
 	}
 
-	// Open transaction, Set thingy in db
-	tx, err = DB.Begin(true)
-	if err != nil {
-		t.Error(err)
-	}
-
 	// Marshal struct and set it
 	mar, err := json.Marshal(exp)
 	if err != nil {
 		t.Error(err)
 	}
 
-	// Set query
+	// Set thingy in db
 	qry := "0"
-	_, _, err = tx.Set("thing:"+qry, string(mar), nil)
-	if err != nil {
-		t.Error(err)
-	}
-
-	// Write to db
-	err = tx.Commit()
+	err = DB.Update(func(tx StoreTx) error {
+		return tx.Set("thing:"+qry, string(mar), 0)
+	})
 	if err != nil {
 		t.Error(err)
 	}
-	tx = nil
 
 	// Bad queries, make sure nothing panics
 	var got thing
@@ -103,15 +94,12 @@ func TestDBGet(t *testing.T) {
 	}
 
 	/* Get whole db, if you wish
-	tx, err = db.Begin(false)
-	if err != nil {
-		t.Error(err)
-	}
-	defer tx.Rollback()
 	buf := ""
-	tx.Ascend("debug", func(key, value string) bool {
-		buf += "\t" + key + " : " + value + "\n"
-		return true
+	DB.View(func(tx StoreTx) error {
+		return tx.AscendPrefix("", func(key, value string) bool {
+			buf += "\t" + key + " : " + value + "\n"
+			return true
+		})
 	})
 	fmt.Printf("TestDBGet: DB HAS {\n%s}\n", buf)
 	*/
@@ -124,25 +112,33 @@ func TestDBGet(t *testing.T) {
 	}
 }
 
-// TestDBSet uses DBSet to put a Storer in the DB
-// and tests whether the value has been correctly stored
-// without panicking or corrupting
-func TestDBSet(t *testing.T) {
-	// Setup
-	tx, err := DB.Begin(true)
-	if err != nil {
-		t.Error(err)
-	}
+// TestDBGetExpired doesn't return values that have expired
+func TestDBGetExpired(t *testing.T) {
+	clearDB(t)
 
-	err = tx.DeleteAll()
+	err := DB.Update(func(tx StoreTx) error {
+		return tx.Set("thing:old", `{"A": "old"}`, 10*time.Millisecond)
+	})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		t.Error(err)
+	var got thing
+	if err = DBGet(&got, "old", &got); err != nil {
+		t.Errorf("DBGet before expiry threw %v", err)
 	}
+	time.Sleep(50 * time.Millisecond)
+	if err = DBGet(&got, "old", &got); err != ErrDBNotFound {
+		t.Errorf("DBGet after expiry threw %v; want %v", err, ErrDBNotFound)
+	}
+}
+
+// TestDBSet uses DBSet to put a Storer in the DB
+// and tests whether the value has been correctly stored
+// without panicking or corrupting
+func TestDBSet(t *testing.T) {
+	// Setup
+	clearDB(t)
 
 	exp := thing{
 		A: "first thingy",
@@ -157,21 +153,18 @@ func TestDBSet(t *testing.T) {
 	DBSet(nil, "")  // empty everything
 
 	// Set exp in db
-	_, _, err = DBSet(&exp, ind)
+	_, _, err := DBSet(&exp, ind)
 	if err != nil {
 		t.Error(err)
 	}
 
-	// Open RO transaction
-	tx, err = DB.Begin(false)
-	if err != nil {
-		t.Error(err)
-	}
-	defer tx.Rollback()
-
-	// Query db
+	// Query db in a RO transaction
 	qry := INDEX + ":" + ind
-	res, err := tx.Get(qry)
+	var res string
+	err = DB.View(func(tx StoreTx) error {
+		res, err = tx.Get(qry)
+		return err
+	})
 	if err != nil {
 		t.Error(err)
 	}
@@ -185,9 +178,11 @@ func TestDBSet(t *testing.T) {
 
 	/* Get whole db, if you wish
 	buf := ""
-	tx.Ascend("debug", func(key, value string) bool {
-		buf += "\t" + key + " : " + value + "\n"
-		return true
+	DB.View(func(tx StoreTx) error {
+		return tx.AscendPrefix("", func(key, value string) bool {
+			buf += "\t" + key + " : " + value + "\n"
+			return true
+		})
 	})
 	fmt.Printf("TestDBSet: DB HAS {\n%s}\n", buf)
 	*/
@@ -197,3 +192,75 @@ func TestDBSet(t *testing.T) {
 		t.Errorf("DBSet(%[1]s, %#[3]v) set {%[2]s: %#[4]v}; want {%[2]s: %#[5]v}", ind, qry, exp, got, exp)
 	}
 }
+
+// TestDBUpdate increments a thing from many goroutines at once, which loses none of the increments,
+// then checks that an error from the update sets nothing
+func TestDBUpdate(t *testing.T) {
+	const n = 50
+	done := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			var thg thing
+			done <- DBUpdate(&thg, "count", func(v Storer) error {
+				v.(*thing).B++
+				return nil
+			})
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got thing
+	err := DBGet(&got, "count", &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.B != n {
+		t.Errorf("%d concurrent DBUpdates counted to %d", n, got.B)
+	}
+
+	bad := errors.New("bad")
+	err = DBUpdate(&thing{}, "count", func(v Storer) error {
+		v.(*thing).B = 0
+		return bad
+	})
+	if err != bad {
+		t.Errorf("DBUpdate gave %v; want %v", err, bad)
+	}
+	DBGet(&got, "count", &got)
+	if got.B != n {
+		t.Errorf("DBUpdate set %d after an error; want %d", got.B, n)
+	}
+}
+
+// TestDBUpdateMany moves a thing from one key to another
+func TestDBUpdateMany(t *testing.T) {
+	_, _, err := DBSet(&thing{A: "moving", B: 1}, "from")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DBUpdateMany([]Storer{&thing{}}, []string{"from", "to"}, func(vs []Storer) error { return nil })
+	if err != ErrDBKeyCount {
+		t.Errorf("DBUpdateMany with more keys than storers gave %v; want %v", err, ErrDBKeyCount)
+	}
+
+	err = DBUpdateMany([]Storer{&thing{}, &thing{}}, []string{"from", "to"}, func(vs []Storer) error {
+		from, to := vs[0].(*thing), vs[1].(*thing)
+		*to, *from = *from, thing{}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var from, to thing
+	DBGet(&from, "from", &from)
+	DBGet(&to, "to", &to)
+	if (from != thing{}) || to.A != "moving" {
+		t.Errorf("DBUpdateMany left %#v and %#v", from, to)
+	}
+}
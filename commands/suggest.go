@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+const (
+	keySuggest = "off"
+)
+
+// SuggestFunc returns the aliases of commands like the unknown one in argv, best first
+type SuggestFunc func(guildID string, argv []string) []string
+
+// suggestStorer implements the Storer interface
+type suggestStorer struct {
+	Off []string // channel ids
+}
+
+func (s *suggestStorer) Index() string { return "suggest" }
+
+// SuggestionsOn returns whether unknown commands get suggestions in the channel
+func SuggestionsOn(guildID string, channelID string) (bool, error) {
+	var sug suggestStorer
+	err := DBGet(&sug, GuildKey(guildID, keySuggest), &sug)
+	if err == ErrDBNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !contains(sug.Off, channelID), nil
+}
+
+// SetSuggestions turns suggestions for unknown commands on or off in the channel
+func SetSuggestions(guildID string, channelID string, on bool) error {
+	return DBUpdate(&suggestStorer{}, GuildKey(guildID, keySuggest), func(v Storer) error {
+		sug := v.(*suggestStorer)
+		sug.Off = toggle(sug.Off, channelID, !on)
+		return nil
+	})
+}
+
+// DidYouMean replies with suggestions when the rest of the chain doesn't find a command,
+// at most once per channel every interval, and not in channels with suggestions off
+//
+// Commands whose args don't fit get suggestions too if the first arg is a typo of one of their subcommands,
+// e.g. !tags ad. A nil suggest does nothing.
+func DidYouMean(suggest SuggestFunc, every time.Duration) Middleware {
+	last := make(map[string]time.Time) // indexed by channel id
+	lastLock := &sync.Mutex{}
+
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			err := next(ctx)
+			if suggest == nil || ctx.Tokens == nil {
+				return err
+			}
+			argv, subs := suggestArgs(ctx, err)
+			if len(argv) == 0 {
+				return err
+			}
+
+			// the rate limit is in memory, so it's checked before the db
+			m := ctx.Message
+			lastLock.Lock()
+			limited := time.Since(last[m.ChannelID]) < every
+			lastLock.Unlock()
+			if limited {
+				return err
+			}
+
+			on, serr := SuggestionsOn(m.GuildID, m.ChannelID)
+			if serr != nil || !on {
+				return firstErr(err, serr)
+			}
+
+			sug := []string{}
+			for _, s := range suggest(m.GuildID, argv) {
+				if subs == nil || subs[s] {
+					sug = append(sug, s)
+				}
+			}
+			if len(sug) == 0 {
+				return err
+			}
+
+			lastLock.Lock()
+			if time.Since(last[m.ChannelID]) < every {
+				lastLock.Unlock()
+				return err
+			}
+			last[m.ChannelID] = time.Now()
+			lastLock.Unlock()
+
+			out := "Unknown command, did you mean:"
+			for _, s := range sug {
+				out += "\n" + utils.Code(ctx.Prefix+s)
+			}
+			_, serr = ctx.Session.ChannelMessageSend(m.ChannelID, out)
+			return firstErr(err, serr)
+		}
+	}
+}
+
+// suggestArgs returns the args to suggest commands for after the chain returned err, none if it found a command that worked
+//
+// If the command's args didn't fit, they're the command's name and its args, and only the aliases of its subcommands
+// in subs are suggested.
+func suggestArgs(ctx *Context, err error) (argv []string, subs map[string]bool) {
+	if err == nil && ctx.Command == nil {
+		argv = ctx.Tokens.Args
+		if len(argv) == 0 || argv[0] == ctx.Prefix {
+			// nothing for !! to repeat
+			return nil, nil
+		}
+		return argv, nil
+	}
+
+	if _, ok := err.(*UsageError); !ok || ctx.Command == nil || ctx.Args == nil || len(ctx.Args.Args) == 0 {
+		return nil, nil
+	}
+	subs = make(map[string]bool)
+	for _, sub := range ctx.Command.Subcommands() {
+		for _, ali := range sub.Aliases() {
+			subs[ali] = true
+		}
+	}
+	if len(subs) == 0 {
+		return nil, nil
+	}
+
+	// by its alias rather than what was typed, which could be an abbreviation
+	argv = append(strings.Split(ctx.Command.Aliases()[0], " "), ctx.Args.Args...)
+	return argv, subs
+}
+
+// firstErr returns the first error that isn't nil
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
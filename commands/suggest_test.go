@@ -0,0 +1,120 @@
+package commands_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// Greet is a command with a subcommand, for suggesting subcommands
+type Greet struct {
+	Say
+	Times int `arg:"times"`
+}
+
+func (g *Greet) Aliases() []string { return []string{"greet"} }
+
+func (g *Greet) Subcommands() []Command { return []Command{&GreetWave{}} }
+
+// GreetWave is Greet's subcommand
+type GreetWave struct{ Say }
+
+func (g *GreetWave) Aliases() []string { return []string{"greet wave"} }
+
+// TestDidYouMean verifies that unknown commands get suggestions, at most once per interval and not in channels with them off
+func TestDidYouMean(t *testing.T) {
+	ses := newFake()
+	asked := []string{}
+	suggest := func(guildID string, argv []string) []string {
+		asked = append(asked, argv[0])
+		if argv[0] == "sya" {
+			return []string{"say"}
+		}
+		return nil
+	}
+	dis := NewDispatcher(Parse(""), DidYouMean(suggest, time.Hour), Route(newSayRoute(nil), NewHistory()), Fill)
+	send := func(content string) {
+		dis.Dispatch(ses, &discordgo.Message{
+			ID: "100", ChannelID: "3", GuildID: "1", Content: content, Author: &discordgo.User{ID: "4"},
+		})
+	}
+
+	send(Prefix + "say 1 hi")
+	send("sya")
+	send(Prefix + "nothing")
+	if len(asked) != 1 || ses.LastMessage("3").Content != "hi" {
+		t.Errorf("suggested for %q; want only nothing", asked)
+	}
+
+	send(Prefix + "sya 1 hi")
+	if got := ses.LastMessage("3").Content; got != "Unknown command, did you mean:\n`"+Prefix+"say`" {
+		t.Errorf("!sya sent %q", got)
+	}
+
+	// rate limited
+	send(Prefix + "say 1 hi")
+	send(Prefix + "sya 1 hi")
+	if got := ses.LastMessage("3").Content; got != "hi" {
+		t.Errorf("!sya again straight away sent %q", got)
+	}
+
+	// turned off
+	SetSuggestions("1", "5", false)
+	defer SetSuggestions("1", "5", true)
+	if on, _ := SuggestionsOn("1", "5"); on {
+		t.Errorf("SuggestionsOn after turning them off = true")
+	}
+	if on, _ := SuggestionsOn("1", "3"); !on {
+		t.Errorf("SuggestionsOn in another channel = false")
+	}
+	dis.Dispatch(ses, &discordgo.Message{
+		ID: "101", ChannelID: "5", GuildID: "1", Content: Prefix + "sya", Author: &discordgo.User{ID: "4"},
+	})
+	if ses.LastMessage("5") != nil {
+		t.Errorf("suggested in a channel with suggestions off")
+	}
+}
+
+// TestDidYouMeanSubcommand verifies that commands whose args don't fit get suggestions for their subcommands
+func TestDidYouMeanSubcommand(t *testing.T) {
+	ses := newFake()
+	suggest := func(guildID string, argv []string) []string {
+		if len(argv) > 1 && argv[1] == "wvae" {
+			return []string{"greet wave", "say"}
+		}
+		return nil
+	}
+	route := func(argv []string) (Command, int) {
+		switch {
+		case len(argv) > 1 && argv[0] == "greet" && argv[1] == "wave":
+			return &GreetWave{}, 2
+		case argv[0] == "greet" || argv[0] == "gr":
+			return &Greet{}, 1
+		}
+		return nil, 0
+	}
+	dis := NewDispatcher(Parse(""), DidYouMean(suggest, 0), Route(route, NewHistory()), Fill)
+	send := func(content string) error {
+		return dis.Dispatch(ses, &discordgo.Message{
+			ID: "100", ChannelID: "6", GuildID: "1", Content: content, Author: &discordgo.User{ID: "4"},
+		})
+	}
+
+	err := send(Prefix + "gr wvae 1 hi")
+	if _, ok := err.(*UsageError); !ok {
+		t.Errorf("!gr wvae threw %v; want a usage error", err)
+	}
+	if got := ses.LastMessage("6"); got == nil || got.Content != "Unknown command, did you mean:\n`"+Prefix+"greet wave`" {
+		t.Errorf("!gr wvae sent %v; want a suggestion for greet wave only", got)
+	}
+
+	// args that are just wrong don't get any
+	before := len(ses.Messages("6"))
+	send(Prefix + "greet one hi")
+	if len(ses.Messages("6")) != before {
+		t.Errorf("!greet with a bad arg suggested %q", ses.LastMessage("6").Content)
+	}
+}
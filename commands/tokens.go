@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokens is a message split into args by Tokenize
+type Tokens struct {
+	Args []string // args with quotes and escapes resolved
+	raw  []string // each arg as it was typed
+	rest []string // raw text from the start of each arg to the end of the message
+}
+
+// Tokenize splits a message into args
+//
+// Args are separated by any whitespace, including newlines.
+// Double quotes group words into a single arg e.g. "Rocket League", and can be empty.
+// A backslash escapes a following quote, backslash or whitespace, and \n is a newline.
+// Other backslashes are kept as they are, so ¯\_(ツ)_/¯ survives.
+// A quote that is never closed is taken literally.
+func Tokenize(s string) *Tokens {
+	return tokenize(s, map[int]bool{})
+}
+
+// tokenize does the work for Tokenize, treating quotes at the literal indexes as normal chars
+func tokenize(s string, literal map[int]bool) *Tokens {
+	toks := &Tokens{
+		Args: []string{},
+		raw:  []string{},
+		rest: []string{},
+	}
+
+	var cur strings.Builder
+	start := -1 // index where the current arg starts, -1 between args
+	open := -1  // index of the open quote, -1 outside quotes
+	escaped := false
+
+	end := func(i int) {
+		if start < 0 {
+			return
+		}
+		toks.Args = append(toks.Args, cur.String())
+		toks.raw = append(toks.raw, s[start:i])
+		toks.rest = append(toks.rest, strings.TrimRightFunc(s[start:], unicode.IsSpace))
+		cur.Reset()
+		start = -1
+	}
+
+	for i, r := range s {
+		if escaped {
+			escaped = false
+			if r == 'n' {
+				cur.WriteRune('\n')
+			} else {
+				cur.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case r == '\\' && i+1 < len(s) && escapable(s[i+1]):
+			if start < 0 {
+				start = i
+			}
+			escaped = true
+		case r == '"' && !literal[i]:
+			if start < 0 {
+				start = i
+			}
+			if open < 0 {
+				open = i
+			} else {
+				open = -1
+			}
+		case unicode.IsSpace(r) && open < 0:
+			end(i)
+		default:
+			if start < 0 {
+				start = i
+			}
+			cur.WriteRune(r)
+		}
+	}
+
+	if open >= 0 {
+		// never closed, try again with the quote as a normal char
+		literal[open] = true
+		return tokenize(s, literal)
+	}
+
+	end(len(s))
+	return toks
+}
+
+// escapable returns whether a backslash before c is an escape
+func escapable(c byte) bool {
+	switch c {
+	case '"', '\\', 'n', ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+// newTokens makes Tokens out of already split args, as if they were typed with single spaces
+func newTokens(args []string) *Tokens {
+	toks := &Tokens{
+		Args: args,
+		raw:  args,
+		rest: make([]string, len(args)),
+	}
+	for i := range args {
+		toks.rest[i] = strings.Join(args[i:], " ")
+	}
+	return toks
+}
+
+// From returns the tokens from index i onwards e.g. after the command's name
+func (t *Tokens) From(i int) *Tokens {
+	if i > len(t.Args) {
+		i = len(t.Args)
+	}
+	return &Tokens{
+		Args: t.Args[i:],
+		raw:  t.raw[i:],
+		rest: t.rest[i:],
+	}
+}
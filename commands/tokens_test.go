@@ -0,0 +1,118 @@
+package commands_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	. "github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestTokenize verifies that Tokenize handles whitespace, quotes and escapes
+func TestTokenize(t *testing.T) {
+	tests := map[string][]string{
+		"tags add pc bob":                   {"tags", "add", "pc", "bob"},
+		"  tags  add\tpc\nbob ":             {"tags", "add", "pc", "bob"},
+		`tags add "Rocket League" bob`:      {"tags", "add", "Rocket League", "bob"},
+		`say "" x`:                          {"say", "", "x"},
+		`say \"hi\" back\\slash`:            {"say", `"hi"`, `back\slash`},
+		`say a\ b "c\"d" e\nf`:              {"say", "a b", `c"d`, "e\nf"},
+		`shrug ¯\_(ツ)_/¯`:                   {"shrug", `¯\_(ツ)_/¯`},
+		`open "unclosed quote`:              {"open", `"unclosed`, "quote"},
+		`mid"dle of" "it's ok" "never`:      {"middle of", "it's ok", `"never`},
+		"--floor=100 \"--not a flag\" -5 x": {"--floor=100", "--not a flag", "-5", "x"},
+	}
+
+	for in, exp := range tests {
+		got := Tokenize(in).Args
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("Tokenize(%q) = %q; want %q", in, got, exp)
+		}
+	}
+}
+
+type Flagged struct {
+	Floor   int    `flag:"floor" short:"f" default:"10"`
+	Exact   bool   `flag:"exact" short:"e"`
+	Channel string `flag:"channel"`
+	Name    string `arg:"name"`
+	Text    string `arg:"text" rest:"true" optional:"true"`
+}
+
+func (f *Flagged) Aliases() []string { return []string{"flagged"} }
+
+func (f *Flagged) Desc() string { return "Flagged!" }
+
+func (f *Flagged) Subcommands() []Command { return nil }
+
+func (f *Flagged) Roles() []string { return nil }
+
+func (f *Flagged) Chans() []string { return nil }
+
+func (f *Flagged) MsgHandle(ses Session, msg *discordgo.Message) (*CommandSend, error) {
+	return nil, nil
+}
+
+// TestFillTokens verifies that FillTokens will:
+// - Fill flags given before args in all their forms
+// - Stop at -- or the first arg
+// - Give rest args the message as typed
+// - Throw an ArgError for unknown flags and flags without values
+func TestFillTokens(t *testing.T) {
+	tests := map[string]Flagged{
+		`bob`:                                    {Floor: 10, Name: "bob"},
+		`--floor=5 -e "Rocket League"  is "fun"`: {Floor: 5, Exact: true, Name: "Rocket League", Text: `is "fun"`},
+		"-f 5 --channel \"#general\" bob\nhi -e": {Floor: 5, Channel: "#general", Name: "bob", Text: "hi -e"},
+		`-f=-5 --exact=false -- --floor`:         {Floor: -5, Name: "--floor"},
+		`-e -5`:                                  {Floor: 10, Exact: true, Name: "-5"},
+		`bob -f`:                                 {Floor: 10, Name: "bob", Text: "-f"},
+	}
+
+	for in, exp := range tests {
+		got := &Flagged{}
+		err := FillTokens(nil, nil, got, Tokenize(in))
+		if err != nil {
+			t.Errorf("FillTokens(%q) threw %v", in, err)
+			continue
+		}
+		if *got != exp {
+			t.Errorf("FillTokens(%q) set %#v; want %#v", in, *got, exp)
+		}
+	}
+
+	// nil means any ArgError
+	bad := map[string]error{
+		"--flor=5 bob": ErrFlagUnknown,
+		"--floor":      ErrFlagValue,
+		"-f x bob":     nil,
+	}
+	for in, exp := range bad {
+		err := FillTokens(nil, nil, &Flagged{}, Tokenize(in))
+		argErr, ok := err.(*ArgError)
+		if !ok || (exp != nil && argErr.Err != exp) {
+			t.Errorf("FillTokens(%q) threw %v; want an ArgError with %v", in, err, exp)
+		}
+	}
+
+	// CleanArgs resets flags
+	got := &Flagged{Floor: 1, Exact: true}
+	CleanArgs(got)
+	if got.Floor != 10 || got.Exact {
+		t.Errorf("CleanArgs left %#v; want default flags", got)
+	}
+}
+
+// TestGetUsageFlags verifies usage strings list flags
+func TestGetUsageFlags(t *testing.T) {
+	got := strings.Split(GetUsage(&Flagged{}), "\n")
+	exp := []string{
+		"**!flagged** (word) __name__ (text) [__text__]",
+		"Flagged!",
+		"__Flags__ | --floor, -f (number) | --exact, -e (true/false) | --channel (word)",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("GetUsage(&Flagged{}) = %q; want %q", got, exp)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
 
@@ -14,13 +15,16 @@ import (
 )
 
 const (
-	historyLim  = 2000
-	archiveChan = "543714336401784862" // #archive
-	scrollEmoji = string(0x1f4dc)
+	archiveChoices = 25 // most messages to choose from
+	scrollEmoji    = string(rune(0x1f4dc))
 )
 
 var (
-	history = []*qelem{}
+	// ErrNoArchiveChannel means the guild hasn't set a channel to archive to
+	ErrNoArchiveChannel = errors.New("this server has no archive channel")
+
+	history     = make(map[string][]*qelem) // indexed by guild id
+	historyLock = &sync.Mutex{}
 )
 
 type qelem struct {
@@ -28,16 +32,27 @@ type qelem struct {
 	mID string
 }
 
-func enqueue(cid, mid string) {
-	history = append(history, &qelem{cid, mid})
-	if len(history) > historyLim {
-		history = history[1:len(history)]
+func enqueue(gid, cid, mid string) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	hist := append(history[gid], &qelem{cid, mid})
+	if len(hist) > cfg().HistoryLimit {
+		hist = hist[len(hist)-cfg().HistoryLimit:]
 	}
+	history[gid] = hist
+}
+
+// guildHistory returns the messages reacted in the guild, oldest first
+func guildHistory(gid string) []*qelem {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	return history[gid]
 }
 
 type archive struct {
 	nilCommand
-	Index int `arg:"index"`
+	Index int `arg:"index" default:"-1"`
 }
 
 func newArchive() *archive { return &archive{} }
@@ -45,42 +60,105 @@ func newArchive() *archive { return &archive{} }
 func (a *archive) Aliases() []string { return []string{"archive"} }
 
 func (a *archive) Desc() string {
-	return "Generates an embed for archiving a message.\nThe ordering for indexes is based on the order of messages reacted."
+	return "Generates an embed for archiving a message.\nThe ordering for indexes is based on the order of messages reacted, leave it out to choose from a list."
 }
 
 func (a *archive) Roles() []string { return []string{"mod"} }
 
-func (a *archive) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (a *archive) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	var err error
 
-	if len(history) == 0 {
+	if len(guildChannel(msg.GuildID, channelArchive)) == 0 {
+		return nil, ErrNoArchiveChannel
+	}
+
+	hist := guildHistory(msg.GuildID)
+	if len(hist) == 0 {
 		return nil, errors.New("no logged messages have been reacted with " + scrollEmoji)
 	}
 
+	if a.Index < 0 {
+		return a.choose(ses, msg, hist), nil
+	}
+
 	// check index
-	if a.Index >= len(history) || a.Index < 0 {
+	if a.Index >= len(hist) {
 		return nil, errors.New("index not in range")
 	}
 
-	cid := history[len(history)-a.Index-1].cID
-	mid := history[len(history)-a.Index-1].mID
+	err = archiveMessage(ses, msg.GuildID, hist[len(hist)-a.Index-1].cID, hist[len(hist)-a.Index-1].mID)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Archived message!"), nil
+}
+
+// choose lets the user pick a message to archive from a list
+func (a *archive) choose(ses commands.Session, msg *discordgo.Message, hist []*qelem) *commands.CommandSend {
+	opts := []*commands.SelectOption{}
+	for i := 0; i < len(hist) && i < archiveChoices; i++ {
+		el := hist[len(hist)-i-1]
+		opt := &commands.SelectOption{
+			Label: fmt.Sprintf("#%d", i),
+			Value: el.cID + ":" + el.mID,
+		}
+		if arc, err := ses.State().Message(el.cID, el.mID); err == nil {
+			opt.Label += " " + arc.Author.Username
+			opt.Description = archivePreview(arc.Content)
+		}
+		opts = append(opts, opt)
+	}
+
+	set := commands.NewComponentSet()
+	set.Users = []string{msg.Author.ID}
+	set.Row(commands.NewSelectMenu("archive", "Pick a message", opts...))
+	set.Handle("archive", func(ctx *commands.ComponentContext) error {
+		ctx.Close()
+		ids := strings.SplitN(ctx.Values[0], ":", 2)
+		err := archiveMessage(ctx.Session, ctx.Interaction.GuildID, ids[0], ids[1])
+		if err != nil {
+			return ctx.Update("Couldn't archive that: "+err.Error(), nil)
+		}
+		return ctx.Update("Archived message!", nil)
+	})
+
+	return commands.NewSimpleSend(msg.ChannelID, "Which message do you want to archive?").Components(set)
+}
+
+// archivePreview cuts message content down to fit in a select option
+func archivePreview(content string) string {
+	runes := []rune(content)
+	if len(runes) > 100 {
+		return string(runes[:99]) + "…"
+	}
+	return content
+}
+
+// archiveMessage sends the message to the guild's archive channel
+func archiveMessage(ses commands.Session, gid, cid, mid string) error {
+	var err error
 
 	// get archive target
 	var arc *discordgo.Message
-	arc, err = ses.State.Message(cid, mid)
+	arc, err = ses.State().Message(cid, mid)
 	if err != nil {
 		arc, err = ses.ChannelMessage(cid, mid)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
+	arcChan := guildChannel(gid, channelArchive)
+	if len(arcChan) == 0 {
+		return ErrNoArchiveChannel
+	}
+
 	var cha *discordgo.Channel
-	cha, err = ses.State.Channel(arc.ChannelID)
+	cha, err = ses.State().Channel(arc.ChannelID)
 	if err != nil {
-		ses.Channel(arc.ChannelID)
+		cha, err = ses.Channel(arc.ChannelID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
@@ -124,7 +202,7 @@ func (a *archive) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*co
 	// generate archive embed
 	out.Embed = &discordgo.MessageEmbed{
 		Author: &discordgo.MessageEmbedAuthor{
-			URL:     fmt.Sprintf("https://discordapp.com/channels/%s/%s/%s", arc.GuildID, cid, mid),
+			URL:     fmt.Sprintf("https://discordapp.com/channels/%s/%s/%s", gid, cid, mid),
 			IconURL: arc.Author.AvatarURL(""),
 			Name:    arc.Author.String(),
 		},
@@ -135,20 +213,19 @@ func (a *archive) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*co
 			Text: fmt.Sprintf("Archived message from %s | %s", cha.Name, arc.Timestamp),
 		},
 
-		Color: ses.State.UserColor(arc.Author.ID, cid),
+		Color: ses.State().UserColor(arc.Author.ID, cid),
 	}
 
 	// send to archive channel
-	ses.ChannelMessageSendComplex(archiveChan, out)
-
-	return commands.NewSimpleSend(msg.ChannelID, "Archived message!"), nil
+	_, err = ses.ChannelMessageSendComplex(arcChan, out)
+	return err
 }
 
-func initArchive(ses *discordgo.Session) {
-	ses.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+func initArchive(ses commands.Session) {
+	ses.AddHandler(func(_ *discordgo.Session, r *discordgo.MessageReactionAdd) {
 		react := r.MessageReaction
 		if react.Emoji.Name == scrollEmoji {
-			enqueue(react.ChannelID, react.MessageID)
+			enqueue(react.GuildID, react.ChannelID, react.MessageID)
 		}
 	})
 }
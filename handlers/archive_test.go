@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestArchiveChoose picks a message to archive from the list, which only has messages from the guild
+func TestArchiveChoose(t *testing.T) {
+	const arcChan = "25"
+
+	ses := newTestSession()
+	history = make(map[string][]*qelem)
+	defer func() { history = make(map[string][]*qelem) }()
+
+	_, err := run(t, ses, newArchive(), testOther)
+	if err != ErrNoArchiveChannel {
+		t.Errorf("!archive without an archive channel gave %v; want %v", err, ErrNoArchiveChannel)
+	}
+
+	commands.AddGuild(&commands.GuildConfig{ID: testGuild, Channels: map[string]string{channelArchive: arcChan}})
+	defer commands.RemoveGuild(testGuild)
+	enqueue("99", "98", "97")
+
+	for _, id := range []string{"60", "61"} {
+		ses.State().MessageAdd(&discordgo.Message{
+			ID:        id,
+			ChannelID: testChannel,
+			GuildID:   testGuild,
+			Content:   "archive me " + id,
+			Author:    &discordgo.User{ID: testUser, Username: "bob"},
+		})
+		enqueue(testGuild, testChannel, id)
+	}
+
+	_, err = run(t, ses, newArchive(), testOther)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// find the menu that was sent
+	reqs := ses.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("!archive made %d requests; want 1", len(reqs))
+	}
+	mar, _ := json.Marshal(reqs[0].Data)
+	var body struct{ Components []*commands.Component }
+	json.Unmarshal(mar, &body)
+	if len(body.Components) != 1 || len(body.Components[0].Components) != 1 {
+		t.Fatalf("!archive sent components %s; want one menu", mar)
+	}
+	menu := body.Components[0].Components[0]
+	if len(menu.Options) != 2 || menu.Options[0].Description != "archive me 61" {
+		t.Fatalf("!archive menu has options %s; want this guild's messages, latest first", mar)
+	}
+
+	commands.NewDispatcher().DispatchInteraction(ses, &commands.Interaction{
+		ID:            "70",
+		ApplicationID: testBot,
+		Type:          commands.InteractionMessageComponent,
+		Data:          &commands.InteractionData{CustomID: menu.CustomID, Values: []string{menu.Options[1].Value}},
+		GuildID:       testGuild,
+		ChannelID:     testChannel,
+		Member:        &discordgo.Member{User: &discordgo.User{ID: testOther}},
+		Token:         "token",
+	})
+
+	arc := ses.LastMessage(arcChan)
+	if arc == nil || len(arc.Embeds) == 0 || arc.Embeds[0].Description != "archive me 60" {
+		t.Errorf("choosing #1 archived %#v; want the first message", arc)
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	logs "log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+var (
+	// ErrNoArchive means there was no archive attached to restore from
+	ErrNoArchive = errors.New("attach an archive from !db dump to restore from")
+)
+
+/* db */
+
+type dbCommand struct {
+	nilCommand
+}
+
+func newDB() *dbCommand { return &dbCommand{} }
+
+func (d *dbCommand) Aliases() []string { return []string{"db"} }
+
+func (d *dbCommand) Desc() string {
+	return "Lists how many things the bot keeps in each index of its db, for every server. Only for the bot's admins."
+}
+
+func (d *dbCommand) Roles() []string { return []string{"mod"} }
+
+func (d *dbCommand) Subcommands() []commands.Command {
+	return []commands.Command{
+		newDBDump(),
+		newDBRestore(),
+	}
+}
+
+func (d *dbCommand) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	if !cfg().IsAdmin(msg.Author.ID) {
+		return nil, ErrAdminOnly
+	}
+
+	idx, err := commands.DBIndexes()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for index, n := range idx {
+		lines = append(lines, fmt.Sprintf("%s: %d", index, n))
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		return commands.NewSimpleSend(msg.ChannelID, "The db is empty."), nil
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Indexes:\n"+utils.Block(strings.Join(lines, "\n"))), nil
+}
+
+/* db dump */
+
+type dbDump struct {
+	dbCommand
+	Indexes []string `arg:"indexes"`
+}
+
+func newDBDump() *dbDump { return &dbDump{} }
+
+func (d *dbDump) Aliases() []string { return []string{"db dump"} }
+
+func (d *dbDump) Desc() string {
+	return "Sends an archive of everything in the db, or only the indexes given, for !db restore. Only for the bot's admins."
+}
+
+func (d *dbDump) Subcommands() []commands.Command { return nil }
+
+func (d *dbDump) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// it has every server's data
+	if !cfg().IsAdmin(msg.Author.ID) {
+		return nil, ErrAdminOnly
+	}
+
+	buf := &bytes.Buffer{}
+	err := commands.DBDump(buf, d.Indexes...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := "Dumped everything in the db"
+	if len(d.Indexes) > 0 {
+		out = "Dumped " + utils.Code(strings.Join(d.Indexes, ", "))
+	}
+	return commands.NewSend(msg.ChannelID).MessageSend(&discordgo.MessageSend{
+		Content: out,
+		Files: []*discordgo.File{{
+			Name:        "pcsocgo-" + time.Now().UTC().Format("20060102T150405Z") + ".json",
+			ContentType: "application/json",
+			Reader:      buf,
+		}},
+	}), nil
+}
+
+/* db restore */
+
+type dbRestore struct {
+	dbCommand
+	Indexes []string `arg:"indexes"`
+}
+
+func newDBRestore() *dbRestore { return &dbRestore{} }
+
+func (d *dbRestore) Aliases() []string { return []string{"db restore"} }
+
+func (d *dbRestore) Desc() string {
+	return "Replaces everything in the db, or only the indexes given, with the archive attached to the message." +
+		" A snapshot is taken first if backup_dir is set. Restart the bot afterwards. Only for the bot's admins."
+}
+
+func (d *dbRestore) Subcommands() []commands.Command { return nil }
+
+func (d *dbRestore) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	if !cfg().IsAdmin(msg.Author.ID) {
+		return nil, ErrAdminOnly
+	}
+	if len(msg.Attachments) == 0 {
+		return nil, ErrNoArchive
+	}
+
+	what := "everything in the db"
+	if len(d.Indexes) > 0 {
+		what = utils.Code(strings.Join(d.Indexes, ", "))
+	}
+	ok, err := commands.Confirm(ses, msg.ChannelID, msg.Author.ID,
+		"Replace "+what+" with the archive "+utils.Code(msg.Attachments[0].Filename)+"?", confirmTimeout)
+	if err != nil || !ok {
+		return nil, errAborted(err)
+	}
+
+	resp, err := http.Get(msg.Attachments[0].URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	arc, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep what's being replaced, just in case
+	out := ""
+	if dir := cfg().BackupDir; len(dir) > 0 {
+		path, err := commands.DBSnapshot(dir)
+		if err != nil {
+			return nil, err
+		}
+		out = "Snapshotted the db to " + utils.Code(path) + " first\n"
+	}
+
+	n, err := commands.DBRestore(bytes.NewReader(arc), d.Indexes...)
+	if err != nil {
+		return nil, err
+	}
+	out += fmt.Sprintf("Restored %d things to %s, restart the bot so everything uses them.", n, what)
+	return commands.NewSimpleSend(msg.ChannelID, out), nil
+}
+
+/* backup daemon */
+
+// initBackup snapshots the db every backup_hours into backup_dir, removing old snapshots
+func initBackup(ses commands.Session) chan bool {
+	logs.Println("Initialised backup")
+
+	ticker := time.NewTicker(time.Minute)
+	done := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := doBackup()
+				if err != nil {
+					logs.Println("backupDaemon:", err)
+				}
+			case <-done:
+				logs.Println("backupDaemon: received done signal")
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// doBackup takes a snapshot if the newest one is old enough, then removes the ones past the config's retention
+func doBackup() error {
+	c := cfg()
+	if len(c.BackupDir) == 0 {
+		return nil
+	}
+
+	last, err := commands.DBLastSnapshot(c.BackupDir)
+	if err != nil {
+		return err
+	}
+	if time.Since(last) < time.Duration(c.BackupHours)*time.Hour {
+		return nil
+	}
+
+	path, err := commands.DBSnapshot(c.BackupDir)
+	if err != nil {
+		return err
+	}
+	logs.Println("Snapshotted the db to", path)
+
+	removed, err := commands.PruneSnapshots(c.BackupDir, c.BackupKeep, time.Duration(c.BackupDays)*24*time.Hour)
+	for _, path := range removed {
+		logs.Println("Removed old snapshot", path)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/config"
+)
+
+// TestDBRestore dumps the db, removes a quote, then restores it from the attached archive
+func TestDBRestore(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+	commands.DBSet(&quoteRecord{Text: "kept"}, quoteKey(testGuild, 0))
+
+	// mods of any server can't see or change every server's data
+	for _, com := range []commands.Command{newDB(), newDBDump(), newDBRestore()} {
+		if _, err := run(t, ses, com, testOther); err != ErrAdminOnly {
+			t.Errorf("!%s by a mod gave %v; want %v", com.Aliases()[0], err, ErrAdminOnly)
+		}
+	}
+
+	c := config.Default()
+	c.Admins = []string{testOther}
+	ApplyConfig(c)
+	defer ApplyConfig(config.Default())
+
+	_, err := run(t, ses, newDBDump(), testOther)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := ses.LastMessage(testChannel)
+	if len(last.Attachments) != 1 || !strings.HasSuffix(last.Attachments[0].Filename, ".json") {
+		t.Fatalf("!db dump sent %#v; want a json file", last)
+	}
+
+	arc := &bytes.Buffer{}
+	commands.DBDump(arc)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(arc.Bytes())
+	}))
+	defer srv.Close()
+
+	commands.DBSet(&quoteRecord{Text: "changed"}, quoteKey(testGuild, 0))
+
+	_, err = run(t, ses, newDBRestore(), testOther)
+	if err != ErrNoArchive {
+		t.Errorf("!db restore without an archive threw %v; want %v", err, ErrNoArchive)
+	}
+
+	com := newDBRestore()
+	msg := newTestMessage(testOther)
+	msg.Attachments = []*discordgo.MessageAttachment{{Filename: "pcsocgo.json", URL: srv.URL}}
+	if err = commands.FillArgs(ses, msg, com, []string{"quotes"}); err != nil {
+		t.Fatal(err)
+	}
+	answer(ses, testOther, commands.PromptConfirm)
+	_, err = com.MsgHandle(ses, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got quoteRecord
+	commands.DBGet(&got, quoteKey(testGuild, 0), &got)
+	if got.Text != "kept" {
+		t.Errorf("!db restore quotes left the quote %q; want kept", got.Text)
+	}
+}
+
+// TestDoBackup takes a snapshot, then none until backup_hours have passed
+func TestDoBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pcsocgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := config.Default()
+	c.BackupDir = dir
+	ApplyConfig(c)
+	defer ApplyConfig(config.Default())
+
+	for i := 0; i < 2; i++ {
+		if err = doBackup(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	infos, _ := ioutil.ReadDir(dir)
+	if len(infos) != 1 {
+		t.Errorf("two backups in a row took %d snapshots; want 1", len(infos))
+	}
+}
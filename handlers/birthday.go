@@ -25,7 +25,7 @@ func (b *birthdayStorer) Index() string {
 
 type Birthday struct {
 	nilCommand
-	Birthday string `arg:"birthday"`
+	Birthday time.Time `arg:"birthday" format:"2/Jan"`
 }
 
 func newBirthday() *Birthday { return &Birthday{} }
@@ -38,41 +38,21 @@ func (b *Birthday) Desc() string {
 
 func (b *Birthday) Subcommands() []commands.Command { return []commands.Command{newBirthdayRemove()} }
 
-func (b *Birthday) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	// parse the birthday
-	bdayString := strings.Trim(b.Birthday, " ")
-
-	birthday, err := time.Parse("2/Jan", bdayString)
-	if err != nil {
-		return nil, err
-	}
-
-	// get database
-	var bdays birthdayStorer
-	err = commands.DBGet(&bdays, bdaysKey, &bdays)
-	if err == commands.ErrDBNotFound {
-		// create entry
-		bdays = birthdayStorer{make(map[string]time.Time)}
-	} else if err != nil {
-		return nil, err
-	}
-
-	if bdays.Birthdays == nil {
-		bdays.Birthdays = make(map[string]time.Time)
-	}
-
-	bdays.Birthdays[msg.Author.ID] = birthday
-
+func (b *Birthday) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// set in database
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	_, _, err = commands.DBSet(&bdays, bdaysKey)
+	err := commands.DBUpdate(&birthdayStorer{}, commands.GuildKey(msg.GuildID, bdaysKey), func(v commands.Storer) error {
+		bdays := v.(*birthdayStorer)
+		if bdays.Birthdays == nil {
+			bdays.Birthdays = make(map[string]time.Time)
+		}
+		bdays.Birthdays[msg.Author.ID] = b.Birthday
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return commands.NewSimpleSend(msg.ChannelID, "Added your birthday "+bdayString), nil
+	return commands.NewSimpleSend(msg.ChannelID, "Added your birthday "+b.Birthday.Format("2/Jan")), nil
 }
 
 type BirthdayRemove struct {
@@ -89,18 +69,15 @@ func (b *BirthdayRemove) Desc() string {
 	return "Removes your birthday from the bot"
 }
 
-func (b *BirthdayRemove) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	// get database
-	var bdays birthdayStorer
-	commands.DBGet(&bdays, bdaysKey, &bdays)
-
-	delete(bdays.Birthdays, msg.Author.ID)
-
-	// set in database
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	commands.DBSet(&bdays, bdaysKey)
+func (b *BirthdayRemove) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// remove from database
+	err := commands.DBUpdate(&birthdayStorer{}, commands.GuildKey(msg.GuildID, bdaysKey), func(v commands.Storer) error {
+		delete(v.(*birthdayStorer).Birthdays, msg.Author.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return commands.NewSimpleSend(msg.ChannelID, "Removed your birthday"), nil
 }
@@ -121,15 +98,12 @@ func (b *BirthdayModCheck) Desc() string {
 
 func (b *BirthdayModCheck) Roles() []string { return []string{"mod", "exec"} }
 
-func (b *BirthdayModCheck) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	location, err := time.LoadLocation("Australia/Sydney")
-	if err != nil {
-		return nil, errors.New("location is bad :(")
-	}
+func (b *BirthdayModCheck) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	location := cfg().Location()
 
 	now := time.Now()
 	aestTime := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), location)
-	err = doBirthday(ses, aestTime)
+	err := doBirthday(ses, msg.GuildID, aestTime)
 	if err != nil {
 		return nil, err
 	}
@@ -137,19 +111,19 @@ func (b *BirthdayModCheck) MsgHandle(ses *discordgo.Session, msg *discordgo.Mess
 	return commands.NewSimpleSend(msg.ChannelID, "Check complete!"), nil
 }
 
-func doBirthday(ses *discordgo.Session, tim time.Time) error {
+func doBirthday(ses commands.Session, guildID string, tim time.Time) error {
 	// call handler
-	logs.Println("Calling birthday handler for time:", tim)
+	logs.Println("Calling birthday handler in guild", guildID, "for time:", tim)
 
 	var bdays birthdayStorer
-	err := commands.DBGet(&bdays, bdaysKey, &bdays)
+	err := commands.DBGet(&bdays, commands.GuildKey(guildID, bdaysKey), &bdays)
 	if err == commands.ErrDBNotFound {
 		logs.Println("No birthdays found in db")
 		return err
 	}
 
 	// get birthday role
-	guildroles, err := ses.GuildRoles(commands.Guild.ID)
+	guildroles, err := ses.GuildRoles(guildID)
 	if err != nil {
 		return err
 	}
@@ -163,7 +137,7 @@ func doBirthday(ses *discordgo.Session, tim time.Time) error {
 	}
 
 	if len(roleID) == 0 {
-		return errors.New("no birthday role in guild: " + commands.Guild.Name + "\n")
+		return errors.New("no birthday role in guild: " + guildID + "\n")
 	}
 
 	// iterate birthdays
@@ -175,12 +149,12 @@ func doBirthday(ses *discordgo.Session, tim time.Time) error {
 
 		// check that the day is right
 		if !(bday.Month() == tim.Month() && bday.Day() == tim.Day()) {
-			_ = ses.GuildMemberRoleRemove(commands.Guild.ID, uid, roleID)
+			_ = ses.GuildMemberRoleRemove(guildID, uid, roleID)
 			continue
 		}
 
 		// HAPPY @Birthday!
-		err = ses.GuildMemberRoleAdd(commands.Guild.ID, uid, roleID)
+		err = ses.GuildMemberRoleAdd(guildID, uid, roleID)
 		if err != nil {
 			logs.Println(err)
 			continue
@@ -190,13 +164,10 @@ func doBirthday(ses *discordgo.Session, tim time.Time) error {
 	return nil
 }
 
-func initBirthday(ses *discordgo.Session) chan bool {
+func initBirthday(ses commands.Session) chan bool {
 	logs.Println("Initialised birthday")
 
-	location, err := time.LoadLocation("Australia/Sydney")
-	if err != nil {
-		panic(errors.New("location is bad :("))
-	}
+	location := cfg().Location()
 
 	ticker := time.NewTicker(time.Minute)
 	done := make(chan bool)
@@ -209,9 +180,11 @@ func initBirthday(ses *discordgo.Session) chan bool {
 		for {
 			select {
 			case <-ticker.C:
-				err := doBirthday(ses, aestTime)
-				if err != nil {
-					logs.Println("birthDaemon:", err)
+				for _, guild := range commands.Guilds() {
+					err := doBirthday(ses, guild.ID, aestTime)
+					if err != nil && err != commands.ErrDBNotFound {
+						logs.Println("birthDaemon:", err)
+					}
 				}
 			case <-done:
 				logs.Println("birthDaemon: received done signal")
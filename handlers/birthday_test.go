@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestBirthday adds a birthday and checks the role is given on the day
+func TestBirthday(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	err := commands.InitGuilds(ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = run(t, ses, newBirthday(), testUser, "bad date")
+	if err == nil {
+		t.Errorf("!bday with a bad date didn't throw an error")
+	}
+
+	_, err = run(t, ses, newBirthday(), testUser, "2/Jan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem, _ := ses.GuildMember(testGuild, testUser)
+
+	// on the day
+	err = doBirthday(ses, testGuild, time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mem.Roles) != 1 || mem.Roles[0] != testBday {
+		t.Errorf("doBirthday on the day gave roles %v; want [%s]", mem.Roles, testBday)
+	}
+
+	// the day after
+	err = doBirthday(ses, testGuild, time.Date(2020, time.January, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mem.Roles) != 0 {
+		t.Errorf("doBirthday after the day left roles %v; want []", mem.Roles)
+	}
+
+	// removed birthdays are ignored
+	_, err = run(t, ses, newBirthdayRemove(), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doBirthday(ses, testGuild, time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mem.Roles) != 0 {
+		t.Errorf("doBirthday after !bday remove gave roles %v; want []", mem.Roles)
+	}
+}
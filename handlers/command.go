@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+var (
+	// ErrCantDisable means a mod tried to disable command, which would leave no way to enable it
+	ErrCantDisable = errors.New("command can't be disabled")
+)
+
+// commandTarget splits the args into the command they name in the guild and the channel mentioned at the end, if any
+//
+// The channel has to be one of the message's guild's.
+func commandTarget(ses commands.Session, msg *discordgo.Message, argv []string) (commands.Command, string, error) {
+	cha := ""
+	if len(argv) > 0 {
+		last := argv[len(argv)-1]
+		if strings.HasPrefix(last, "<#") && strings.HasSuffix(last, ">") {
+			got, err := commands.ResolveChannel(ses, msg, last)
+			if err != nil {
+				return nil, "", &commands.ArgError{Arg: "channel", Type: "channel", Value: last, Err: err}
+			}
+			cha = got.ID
+			argv = argv[:len(argv)-1]
+		}
+	}
+
+	com, err := permsCommand(msg.GuildID, argv)
+	if err != nil {
+		return nil, "", err
+	}
+	return com, cha, nil
+}
+
+// commandWhere describes where a command is toggled
+func commandWhere(channelID string) string {
+	if len(channelID) == 0 {
+		return "on this server"
+	}
+	return "in <#" + channelID + ">"
+}
+
+/* command */
+
+type commandRoot struct {
+	nilCommand
+}
+
+func newCommandRoot() *commandRoot { return &commandRoot{} }
+
+func (c *commandRoot) Aliases() []string { return []string{"command"} }
+
+func (c *commandRoot) Desc() string { return "Lists the commands that are disabled on this server." }
+
+func (c *commandRoot) Roles() []string { return []string{"mod"} }
+
+func (c *commandRoot) Subcommands() []commands.Command {
+	return []commands.Command{
+		newCommandDisable(),
+		newCommandEnable(),
+	}
+}
+
+func (c *commandRoot) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	dis, err := commands.GetDisabled(msg.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	if len(dis.Guild) == 0 && len(dis.Channels) == 0 {
+		return commands.NewSimpleSend(msg.ChannelID, "No commands are disabled on this server"), nil
+	}
+
+	out := utils.Bold("Disabled commands:")
+	if len(dis.Guild) > 0 {
+		out += "\nEverywhere: " + utils.Code(strings.Join(dis.Guild, "`, `"))
+	}
+
+	chans := []string{}
+	for cid := range dis.Channels {
+		chans = append(chans, cid)
+	}
+	sort.Strings(chans)
+	for _, cid := range chans {
+		out += "\nIn <#" + cid + ">: " + utils.Code(strings.Join(dis.Channels[cid], "`, `"))
+	}
+	return commands.NewSimpleSend(msg.ChannelID, out), nil
+}
+
+/* command disable */
+
+type commandDisable struct {
+	nilCommand
+	Command []string `arg:"command"`
+}
+
+func newCommandDisable() *commandDisable { return &commandDisable{} }
+
+func (c *commandDisable) Aliases() []string { return []string{"command disable"} }
+
+func (c *commandDisable) Desc() string {
+	return "Turns off a command and its subcommands on this server, or in a channel if you mention one at the end," +
+		" e.g. command disable scream #serious"
+}
+
+func (c *commandDisable) Roles() []string { return []string{"mod"} }
+
+func (c *commandDisable) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	com, cha, err := commandTarget(ses, msg, c.Command)
+	if err != nil {
+		return nil, err
+	}
+	name := com.Aliases()[0]
+	if strings.HasPrefix(name, "command") {
+		return nil, ErrCantDisable
+	}
+
+	err = commands.SetDisabled(msg.GuildID, cha, name, true)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Disabled "+utils.Code(name)+" "+commandWhere(cha)), nil
+}
+
+/* command enable */
+
+type commandEnable struct {
+	nilCommand
+	Command []string `arg:"command"`
+}
+
+func newCommandEnable() *commandEnable { return &commandEnable{} }
+
+func (c *commandEnable) Aliases() []string { return []string{"command enable"} }
+
+func (c *commandEnable) Desc() string {
+	return "Turns a disabled command back on, mention the channel at the end if it was disabled in one."
+}
+
+func (c *commandEnable) Roles() []string { return []string{"mod"} }
+
+func (c *commandEnable) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	com, cha, err := commandTarget(ses, msg, c.Command)
+	if err != nil {
+		return nil, err
+	}
+	name := com.Aliases()[0]
+
+	err = commands.SetDisabled(msg.GuildID, cha, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Enabled "+utils.Code(name)+" "+commandWhere(cha)), nil
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestCommandDisable disables tags here and scream on the server, which help then hides
+func TestCommandDisable(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	_, err := run(t, ses, newCommandDisable(), testOther, "command", "enable")
+	if err != ErrCantDisable {
+		t.Errorf("!command disable command enable gave %v; want %v", err, ErrCantDisable)
+	}
+
+	// only this server's channels
+	_, err = run(t, ses, newCommandDisable(), testOther, "tags", "<#999>")
+	if _, ok := err.(*commands.ArgError); !ok {
+		t.Errorf("!command disable tags in another server's channel gave %v; want an ArgError", err)
+	}
+
+	_, err = run(t, ses, newCommandDisable(), testOther, "tags", "<#"+testChannel+">")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = run(t, ses, newCommandDisable(), testOther, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dis, err := commands.GetDisabled(testGuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dis.Disabled(RouterRoute, testChannel, newTagsAdd()) {
+		t.Errorf("disabling tags didn't disable tags add")
+	}
+	if dis.Disabled(RouterRoute, "21", newTagsAdd()) {
+		t.Errorf("disabling tags in #%s disabled it in #21", testChannel)
+	}
+
+	got, err := run(t, ses, newHelp(), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "tags") || strings.Contains(got, "scream") {
+		t.Errorf("help listed disabled commands, sent %q", got)
+	}
+
+	got, err = run(t, ses, newHelp(), testUser, "tags", "add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "disabled here") {
+		t.Errorf("help for a disabled command didn't say so, sent %q", got)
+	}
+
+	// scream is disabled everywhere, so it can't be enabled in a channel
+	_, err = run(t, ses, newCommandEnable(), testOther, "scream", "<#"+testChannel+">")
+	if err != commands.ErrDisabledInGuild {
+		t.Errorf("!command enable in a channel gave %v; want %v", err, commands.ErrDisabledInGuild)
+	}
+	_, err = run(t, ses, newCommandEnable(), testOther, "scream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = run(t, ses, newCommandEnable(), testOther, "tags", "<#"+testChannel+">")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = run(t, ses, newCommandRoot(), testOther)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "No commands are disabled on this server" {
+		t.Errorf("!command after enabling everything sent %q", got)
+	}
+}
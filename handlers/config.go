@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/config"
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+const (
+	keyOverrides = "overrides"
+)
+
+var (
+	// ErrConfigFileOnly means the setting can only be changed in the config file
+	ErrConfigFileOnly = errors.New("that setting can only be changed in the config file")
+	// ErrAdminOnly means only the bot's admins from the config can do that, since it affects every guild
+	ErrAdminOnly = errors.New("only the bot's admins can do that, it affects every server")
+
+	// fileKeys are settings that can't be overridden, they're read before the db is open, are paths on the bot's host
+	// or say who the admins are
+	fileKeys = map[string]bool{"store": true, "store_path": true, "backup_dir": true, "admins": true}
+
+	// conf is the config in use, replaced rather than changed so it can be read without holding the lock
+	conf       = config.Default()
+	confPath   = ""
+	confLock   = &sync.RWMutex{}
+	badWords   = conf.BadWordRegexps()
+	channelKey = regexp.MustCompile(`^channels\.(\w+)$`)
+)
+
+// cfg returns the config in use, don't change it
+func cfg() *config.Config {
+	confLock.RLock()
+	defer confLock.RUnlock()
+	return conf
+}
+
+// filterWords returns the compiled bad words of the config in use
+func filterWords() []*regexp.Regexp {
+	confLock.RLock()
+	defer confLock.RUnlock()
+	return badWords
+}
+
+/* Storer: overrides */
+
+// configOverrides implements the Storer interface
+type configOverrides struct {
+	Values map[string]string // values set with !config set, indexed by key
+}
+
+func (c *configOverrides) Index() string { return "config" }
+
+// LoadConfig loads the config file at path and the environment, then the overrides set with !config set
+//
+// The config is validated, the path is remembered for reloading overrides.
+func LoadConfig(path string) (*config.Config, error) {
+	var ovr configOverrides
+	err := commands.DBGet(&ovr, keyOverrides, &ovr)
+	if err != nil && err != commands.ErrDBNotFound && err != commands.ErrDBNotOpen {
+		return nil, err
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	err = applyOverrides(c, ovr.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	confLock.Lock()
+	confPath = path
+	confLock.Unlock()
+	return c, nil
+}
+
+// applyOverrides sets the overrides in the config, then validates it
+func applyOverrides(c *config.Config, overrides map[string]string) error {
+	for key, val := range overrides {
+		err := c.Set(key, val)
+		if err != nil {
+			return errors.New("override " + key + ": " + err.Error())
+		}
+	}
+	return c.Validate()
+}
+
+// ApplyConfig puts the config in use, it should be validated first
+func ApplyConfig(c *config.Config) {
+	confLock.Lock()
+	defer confLock.Unlock()
+
+	conf = c
+	badWords = c.BadWordRegexps()
+	commands.SetPrefix(c.Prefix)
+	commands.SetGuildPrefixes(c.Prefixes())
+	commands.SetPermissionGroups(c.PermissionGroups())
+	msgCache.SetLimit(c.CacheLimit)
+
+	ErrPlatTooLong = errors.New("your platform is too long, keep it under " + strconv.Itoa(c.PlatformLimit) + " characters")
+	ErrTagTooLong = errors.New("your tag is too long, keep it under " + strconv.Itoa(c.TagLimit) + " characters")
+}
+
+// setOverride sets or removes (if value is empty) an override, then reloads the config with it
+//
+// Overrides that make the config invalid aren't kept.
+func setOverride(key string, value string) error {
+	confLock.RLock()
+	path := confPath
+	confLock.RUnlock()
+
+	// read before the transaction, so it isn't held while reading the file
+	base, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	var c *config.Config
+	err = commands.DBUpdate(&configOverrides{}, keyOverrides, func(v commands.Storer) error {
+		ovr := v.(*configOverrides)
+		if ovr.Values == nil {
+			ovr.Values = make(map[string]string)
+		}
+		if len(value) == 0 {
+			delete(ovr.Values, key)
+		} else {
+			ovr.Values[key] = value
+		}
+
+		c = base.Clone()
+		return applyOverrides(c, ovr.Values)
+	})
+	if err != nil {
+		return err
+	}
+	ApplyConfig(c)
+	return nil
+}
+
+// configAllowed returns whether the user can get and set the setting at key from the guild
+//
+// Mods can only use their guild's prefix and channels, everything else needs one of the bot's admins.
+func configAllowed(guildID string, userID string, key string) bool {
+	if cfg().IsAdmin(userID) {
+		return true
+	}
+	own := "guilds." + guildID + "."
+	if !strings.HasPrefix(key, own) {
+		return false
+	}
+	key = strings.TrimPrefix(key, own)
+	return key == "prefix" || channelKey.MatchString(key)
+}
+
+// configKey expands shorthand keys for the guild
+//
+//	channels.log -> guilds.<guild id>.channels.log
+func configKey(guildID string, key string) string {
+	if channelKey.MatchString(key) {
+		return "guilds." + guildID + "." + key
+	}
+	return key
+}
+
+/* config */
+
+type configCommand struct {
+	nilCommand
+}
+
+func newConfig() *configCommand { return &configCommand{} }
+
+func (c *configCommand) Aliases() []string { return []string{"config"} }
+
+func (c *configCommand) Desc() string { return "Lists the bot's settings." }
+
+func (c *configCommand) Roles() []string { return []string{"mod"} }
+
+func (c *configCommand) Subcommands() []commands.Command {
+	return []commands.Command{
+		newConfigGet(),
+		newConfigSet(),
+	}
+}
+
+func (c *configCommand) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	keys := []string{}
+	for _, key := range cfg().Keys() {
+		if configAllowed(msg.GuildID, msg.Author.ID, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return commands.NewSimpleSend(msg.ChannelID, "There are no settings you can change here yet, try "+utils.Code("channels.log")), nil
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Settings:\n"+utils.Block(strings.Join(keys, "\n"))), nil
+}
+
+/* config get */
+
+type configGet struct {
+	configCommand
+	Key string `arg:"key"`
+}
+
+func newConfigGet() *configGet { return &configGet{} }
+
+func (c *configGet) Aliases() []string { return []string{"config get"} }
+
+func (c *configGet) Desc() string {
+	return "Gets a setting. Use channels.<purpose> for this server's channels e.g. channels.log"
+}
+
+func (c *configGet) Subcommands() []commands.Command { return nil }
+
+func (c *configGet) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	key := configKey(msg.GuildID, c.Key)
+	if !configAllowed(msg.GuildID, msg.Author.ID, key) {
+		return nil, ErrAdminOnly
+	}
+	val, err := cfg().Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, utils.Code(c.Key)+" is "+utils.Code(val)), nil
+}
+
+/* config set */
+
+type configSet struct {
+	configCommand
+	Key   string `arg:"key"`
+	Value string `arg:"value" rest:"true" optional:"true"`
+}
+
+func newConfigSet() *configSet { return &configSet{} }
+
+func (c *configSet) Aliases() []string { return []string{"config set"} }
+
+func (c *configSet) Desc() string {
+	return "Sets a setting until the bot's data is reset, leave out the value to go back to the config file's." +
+		" Use channels.<purpose> for this server's channels e.g. channels.log." +
+		" Mods can only set this server's channels and prefix, everything else needs one of the bot's admins."
+}
+
+func (c *configSet) Subcommands() []commands.Command { return nil }
+
+func (c *configSet) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	key := configKey(msg.GuildID, c.Key)
+	if fileKeys[key] {
+		return nil, ErrConfigFileOnly
+	}
+	if !configAllowed(msg.GuildID, msg.Author.ID, key) {
+		return nil, ErrAdminOnly
+	}
+	val := strings.TrimSpace(c.Value)
+	if key != c.Key {
+		// channel mentions
+		val = strings.TrimSuffix(strings.TrimPrefix(val, "<#"), ">")
+	}
+
+	err := setOverride(key, val)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(val) == 0 {
+		return commands.NewSimpleSend(msg.ChannelID, "Reset "+utils.Code(c.Key)), nil
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Set "+utils.Code(c.Key)+" to "+utils.Code(val)), nil
+}
+
+/* prefix */
+
+type prefix struct {
+	nilCommand
+}
+
+func newPrefix() *prefix { return &prefix{} }
+
+func (p *prefix) Aliases() []string { return []string{"prefix"} }
+
+func (p *prefix) Desc() string {
+	return "Shows the prefix for commands on this server, mentioning me works too."
+}
+
+func (p *prefix) Subcommands() []commands.Command { return []commands.Command{newPrefixSet()} }
+
+func (p *prefix) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	out := "The prefix here is " + utils.Code(commands.GuildPrefix(msg.GuildID))
+	out += ", or mention me e.g. " + ses.State().User.Mention() + " " + HelpAlias
+	return commands.NewSimpleSend(msg.ChannelID, out), nil
+}
+
+/* prefix set */
+
+type prefixSet struct {
+	nilCommand
+	Prefix string `arg:"prefix" optional:"true"`
+}
+
+func newPrefixSet() *prefixSet { return &prefixSet{} }
+
+func (p *prefixSet) Aliases() []string { return []string{"prefix set"} }
+
+func (p *prefixSet) Desc() string {
+	return "Sets the prefix for commands on this server, leave it out to go back to the default."
+}
+
+func (p *prefixSet) Roles() []string { return []string{"mod"} }
+
+func (p *prefixSet) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	err := setOverride("guilds."+msg.GuildID+".prefix", p.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "The prefix here is now "+utils.Code(commands.GuildPrefix(msg.GuildID))), nil
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/config"
+)
+
+// TestConfigSet sets settings with !config set, which are kept in the db and survive reloads
+func TestConfigSet(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+	defer ApplyConfig(config.Default())
+
+	// mods can only change their own guild's settings
+	_, err := run(t, ses, newConfigSet(), testOther, "channels.log", "<#21>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cha := guildChannel(testGuild, channelLog); cha != "21" {
+		t.Errorf("!config set channels.log made the log channel %q; want 21", cha)
+	}
+	for _, key := range []string{"prefix", "groups.mod", "guilds.404.prefix", "guilds." + testGuild + ".groups.mod"} {
+		_, err = run(t, ses, newConfigSet(), testOther, key, "x")
+		if err != ErrAdminOnly {
+			t.Errorf("!config set %s by a mod gave %v; want %v", key, err, ErrAdminOnly)
+		}
+		_, err = run(t, ses, newConfigGet(), testOther, key)
+		if err != ErrAdminOnly {
+			t.Errorf("!config get %s by a mod gave %v; want %v", key, err, ErrAdminOnly)
+		}
+	}
+	if _, err = run(t, ses, newConfigGet(), testOther, "channels.log"); err != nil {
+		t.Errorf("!config get channels.log by a mod threw %v", err)
+	}
+
+	// admins can change everything
+	os.Setenv(config.EnvPrefix+"ADMINS", testOther)
+	defer os.Unsetenv(config.EnvPrefix + "ADMINS")
+	c, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyConfig(c)
+	_, err = run(t, ses, newConfigSet(), testOther, "prefix", "?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands.DefaultPrefix() != "?" {
+		t.Errorf("!config set prefix made the prefix %q; want ?", commands.DefaultPrefix())
+	}
+
+	// bad values aren't kept
+	_, err = run(t, ses, newConfigSet(), testOther, "tag_limit", "-1")
+	if err == nil {
+		t.Errorf("!config set with a bad value didn't throw an error")
+	}
+	if cfg().TagLimit != config.Default().TagLimit {
+		t.Errorf("!config set with a bad value made tag_limit %d", cfg().TagLimit)
+	}
+
+	// the store is picked before there's a db to keep overrides in
+	_, err = run(t, ses, newConfigSet(), testOther, "store", "sqlite")
+	if err != ErrConfigFileOnly {
+		t.Errorf("!config set store gave %v; want %v", err, ErrConfigFileOnly)
+	}
+
+	// overrides are reloaded
+	c, err = LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Prefix != "?" || c.Channel(testGuild, channelLog) != "21" {
+		t.Errorf("reloading lost the overrides, prefix is %q", c.Prefix)
+	}
+
+	// resetting
+	_, err = run(t, ses, newConfigSet(), testOther, "prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands.DefaultPrefix() != config.Default().Prefix {
+		t.Errorf("resetting the prefix made it %q; want the default", commands.DefaultPrefix())
+	}
+}
+
+// TestPrefixSet changes the guild's prefix, which help then shows
+func TestPrefixSet(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+	defer ApplyConfig(config.Default())
+
+	_, err := run(t, ses, newPrefixSet(), testOther, "a b")
+	if err == nil {
+		t.Errorf("!prefix set with a space didn't throw an error")
+	}
+
+	_, err = run(t, ses, newPrefixSet(), testOther, "?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pre := commands.GuildPrefix(testGuild); pre != "?" {
+		t.Errorf("!prefix set ? made the prefix %q", pre)
+	}
+	if pre := commands.GuildPrefix("404"); pre != commands.DefaultPrefix() {
+		t.Errorf("!prefix set changed another guild's prefix to %q", pre)
+	}
+
+	got, err := run(t, ses, newHelp(), testUser, "quote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "?quote") {
+		t.Errorf("help didn't use the guild's prefix, sent %q", got)
+	}
+
+	_, err = run(t, ses, newPrefixSet(), testOther)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pre := commands.GuildPrefix(testGuild); pre != commands.DefaultPrefix() {
+		t.Errorf("resetting the prefix made it %q; want %q", pre, commands.DefaultPrefix())
+	}
+}
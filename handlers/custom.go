@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+const (
+	keyCustom = "commands"
+
+	customNameLimit = 32
+	customTextLimit = 1500
+)
+
+var (
+	// ErrCustomName means the custom command's name isn't one word of lowercase letters, numbers, - and _
+	ErrCustomName = errors.New("custom command names must be one word of lowercase letters, numbers, - and _")
+	// ErrCustomTaken means the name is already used by a built-in command
+	ErrCustomTaken = errors.New("there's already a command with that name")
+	// ErrCustomExists means the guild already has a custom command with the name
+	ErrCustomExists = errors.New("there's already a custom command with that name, edit it instead")
+	// ErrNoCustom means the guild has no custom command with the name
+	ErrNoCustom = errors.New("there's no custom command with that name")
+	// ErrCustomTooLong means the custom command's text is too long to send
+	ErrCustomTooLong = errors.New("custom commands must be shorter than " + strconv.Itoa(customTextLimit) + " characters")
+
+	customNameRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+	// custom commands are routed by guild with GuildRoute, rather than in the router every guild shares
+	customNames = make(map[string]map[string]bool) // names indexed by guild id
+	customLock  = &sync.RWMutex{}
+)
+
+// custom is a guild's custom command
+type custom struct {
+	Text    string
+	Creator string // user id
+}
+
+// customStorer implements the Storer interface
+type customStorer struct {
+	Commands map[string]*custom // indexed by name
+}
+
+func (c *customStorer) Index() string { return "custom" }
+
+// getCustoms returns the guild's custom commands
+func getCustoms(guildID string) (*customStorer, error) {
+	var cus customStorer
+	err := commands.DBGet(&cus, commands.GuildKey(guildID, keyCustom), &cus)
+	if err != nil && err != commands.ErrDBNotFound {
+		return nil, err
+	}
+	if cus.Commands == nil {
+		cus.Commands = make(map[string]*custom)
+	}
+	return &cus, nil
+}
+
+// InitCustomCommands routes the custom commands of every guild in the db
+//
+// Custom commands only work with the prefix, they aren't slash commands.
+func InitCustomCommands() error {
+	found := make(map[string][]string) // names indexed by guild id
+	err := commands.DBView(func(tx *commands.DBTx) error {
+		keys, err := tx.Keys(&customStorer{}, commands.GuildKey("*", keyCustom))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			parts := strings.Split(key, ":")
+			if len(parts) != 2 {
+				continue
+			}
+			var cus customStorer
+			if err = tx.Get(&cus, key, &cus); err != nil {
+				return err
+			}
+			for name := range cus.Commands {
+				found[parts[0]] = append(found[parts[0]], name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// keep going if a built-in command has taken a name
+	for gid, names := range found {
+		for _, name := range names {
+			if rerr := routeCustom(gid, name); rerr != nil {
+				err = errors.New("custom command " + name + " in guild " + gid + ": " + rerr.Error())
+			}
+		}
+	}
+	return err
+}
+
+// routeCustom routes the guild's custom command, returning ErrCustomTaken if a built-in command has the name
+func routeCustom(guildID string, name string) error {
+	if commandRouter.Owner(name) != nil {
+		return ErrCustomTaken
+	}
+
+	customLock.Lock()
+	defer customLock.Unlock()
+	if customNames[guildID] == nil {
+		customNames[guildID] = make(map[string]bool)
+	}
+	customNames[guildID][name] = true
+	return nil
+}
+
+// unrouteCustom stops routing the guild's custom command
+func unrouteCustom(guildID string, name string) {
+	customLock.Lock()
+	defer customLock.Unlock()
+
+	delete(customNames[guildID], name)
+	if len(customNames[guildID]) == 0 {
+		delete(customNames, guildID)
+	}
+}
+
+// customCommands returns the guild's custom commands, ordered by name
+func customCommands(guildID string) []commands.Command {
+	customLock.RLock()
+	defer customLock.RUnlock()
+
+	names := []string{}
+	for name := range customNames[guildID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := []commands.Command{}
+	for _, name := range names {
+		out = append(out, newCustomCommand(name))
+	}
+	return out
+}
+
+// GuildRoute finds the guild's custom command named by the first arg, for commands.RouteGuild
+//
+// Built-in commands added since the custom command win.
+func GuildRoute(guildID string, argv []string) (commands.Command, int) {
+	if len(argv) == 0 {
+		return nil, 0
+	}
+	name := strings.ToLower(argv[0])
+
+	customLock.RLock()
+	has := customNames[guildID][name]
+	customLock.RUnlock()
+	if !has || commandRouter.Owner(name) != nil {
+		return nil, 0
+	}
+	return newCustomCommand(name), 1
+}
+
+// routeIn finds the command for the args in the guild, its custom commands first
+func routeIn(guildID string, argv []string) (commands.Command, int) {
+	if com, ind := GuildRoute(guildID, argv); com != nil {
+		return com, ind
+	}
+	return RouterRoute(argv)
+}
+
+// checkCustomName returns an error if the name can't be used for a custom command
+func checkCustomName(name string) error {
+	if len(name) > customNameLimit || !customNameRegex.MatchString(name) {
+		return ErrCustomName
+	}
+	if commandRouter.Owner(name) != nil {
+		return ErrCustomTaken
+	}
+	return nil
+}
+
+// setCustom adds, replaces or removes (if text is empty) the guild's custom command
+//
+// Adding fails if there is already one, replacing and removing fail if there isn't.
+func setCustom(guildID string, name string, text string, creator string, replace bool) error {
+	err := commands.DBUpdate(&customStorer{}, commands.GuildKey(guildID, keyCustom), func(v commands.Storer) error {
+		cus := v.(*customStorer)
+		if cus.Commands == nil {
+			cus.Commands = make(map[string]*custom)
+		}
+
+		_, has := cus.Commands[name]
+		switch {
+		case has && !replace:
+			return ErrCustomExists
+		case !has && replace:
+			return ErrNoCustom
+		}
+
+		if len(text) == 0 {
+			delete(cus.Commands, name)
+		} else {
+			cus.Commands[name] = &custom{Text: text, Creator: creator}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(text) == 0 {
+		unrouteCustom(guildID, name)
+		return nil
+	}
+	return routeCustom(guildID, name)
+}
+
+// expandCustom fills in the custom command's template variables
+func expandCustom(ses commands.Session, text string, msg *discordgo.Message, args string) string {
+	// the author's name on the server
+	name := msg.Author.Username
+	if mem, err := ses.State().Member(msg.GuildID, msg.Author.ID); err == nil {
+		name = mem.User.Username
+		if len(mem.Nick) > 0 {
+			name = mem.Nick
+		}
+	}
+
+	return strings.NewReplacer(
+		"{author}", name,
+		"{mention}", msg.Author.Mention(),
+		"{args}", args,
+		"{channel}", "<#"+msg.ChannelID+">",
+	).Replace(text)
+}
+
+/* a custom command */
+
+type customCommand struct {
+	nilCommand
+	name string
+	Args string `arg:"args" rest:"true" optional:"true"`
+}
+
+func newCustomCommand(name string) *customCommand { return &customCommand{name: name} }
+
+func (c *customCommand) Aliases() []string { return []string{c.name} }
+
+func (c *customCommand) Desc() string { return "A custom command, see cc." }
+
+func (c *customCommand) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	cus, err := getCustoms(msg.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	cur, ok := cus.Commands[c.name]
+	if !ok {
+		// removed since it was routed
+		return nil, ErrNoCustom
+	}
+	return commands.NewSimpleSend(msg.ChannelID, expandCustom(ses, cur.Text, msg, c.Args)), nil
+}
+
+/* cc */
+
+type cc struct {
+	nilCommand
+}
+
+func newCc() *cc { return &cc{} }
+
+func (c *cc) Aliases() []string { return []string{"cc", "cc list", "cc ls"} }
+
+func (c *cc) Desc() string { return "Lists this server's custom commands." }
+
+func (c *cc) Subcommands() []commands.Command {
+	return []commands.Command{
+		newCcAdd(),
+		newCcEdit(),
+		newCcRemove(),
+	}
+}
+
+func (c *cc) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	cus, err := getCustoms(msg.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cus.Commands) == 0 {
+		return commands.NewSimpleSend(msg.ChannelID, "This server has no custom commands"), nil
+	}
+
+	names := []string{}
+	for name := range cus.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pre := commands.GuildPrefix(msg.GuildID)
+	out := utils.Bold("Custom commands:")
+	for _, name := range names {
+		out += "\n" + utils.Code(pre+name) + ": " + cus.Commands[name].Text
+	}
+	return commands.NewSimpleSend(msg.ChannelID, out), nil
+}
+
+/* cc add */
+
+type ccAdd struct {
+	nilCommand
+	Name string `arg:"name"`
+	Text string `arg:"text" rest:"true"`
+}
+
+func newCcAdd() *ccAdd { return &ccAdd{} }
+
+func (c *ccAdd) Aliases() []string { return []string{"cc add"} }
+
+func (c *ccAdd) Desc() string {
+	return "Adds a command that replies with the text, which can use {author}, {mention}, {args} and {channel}."
+}
+
+func (c *ccAdd) Roles() []string { return []string{"mod"} }
+
+func (c *ccAdd) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	name := strings.ToLower(c.Name)
+	err := checkCustomName(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Text) > customTextLimit {
+		return nil, ErrCustomTooLong
+	}
+
+	err = setCustom(msg.GuildID, name, c.Text, msg.Author.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Added "+utils.Code(commands.GuildPrefix(msg.GuildID)+name)), nil
+}
+
+/* cc edit */
+
+type ccEdit struct {
+	nilCommand
+	Name string `arg:"name"`
+	Text string `arg:"text" rest:"true"`
+}
+
+func newCcEdit() *ccEdit { return &ccEdit{} }
+
+func (c *ccEdit) Aliases() []string { return []string{"cc edit"} }
+
+func (c *ccEdit) Desc() string { return "Changes the text of a custom command." }
+
+func (c *ccEdit) Roles() []string { return []string{"mod"} }
+
+func (c *ccEdit) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	name := strings.ToLower(c.Name)
+	if len(c.Text) > customTextLimit {
+		return nil, ErrCustomTooLong
+	}
+
+	err := setCustom(msg.GuildID, name, c.Text, msg.Author.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Edited "+utils.Code(commands.GuildPrefix(msg.GuildID)+name)), nil
+}
+
+/* cc remove */
+
+type ccRemove struct {
+	nilCommand
+	Name string `arg:"name"`
+}
+
+func newCcRemove() *ccRemove { return &ccRemove{} }
+
+func (c *ccRemove) Aliases() []string { return []string{"cc remove", "cc rm"} }
+
+func (c *ccRemove) Desc() string { return "Removes a custom command." }
+
+func (c *ccRemove) Roles() []string { return []string{"mod"} }
+
+func (c *ccRemove) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	name := strings.ToLower(c.Name)
+	err := setCustom(msg.GuildID, name, "", "", true)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Removed "+utils.Code(commands.GuildPrefix(msg.GuildID)+name)), nil
+}
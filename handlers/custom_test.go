@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCustomCommands adds, calls, edits and removes a custom command
+func TestCustomCommands(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	_, err := run(t, ses, newCcAdd(), testOther, "tags", "hi")
+	if err != ErrCustomTaken {
+		t.Errorf("!cc add tags gave %v; want %v", err, ErrCustomTaken)
+	}
+	_, err = run(t, ses, newCcAdd(), testOther, "two words", "hi")
+	if err != ErrCustomName {
+		t.Errorf("!cc add with a bad name gave %v; want %v", err, ErrCustomName)
+	}
+
+	_, err = run(t, ses, newCcAdd(), testOther, "faq", "hi {mention} in {channel}, you said {args}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = run(t, ses, newCcAdd(), testOther, "faq", "again")
+	if err != ErrCustomExists {
+		t.Errorf("adding faq twice gave %v; want %v", err, ErrCustomExists)
+	}
+
+	com, ind := GuildRoute(testGuild, []string{"FAQ", "a", "b"})
+	if com == nil || ind != 1 {
+		t.Fatalf("faq wasn't routed, got %v at %d", com, ind)
+	}
+
+	// other guilds don't see it
+	if oth, _ := GuildRoute("404", []string{"faq"}); oth != nil {
+		t.Errorf("faq was routed in another guild")
+	}
+	if oth, _ := RouterRoute([]string{"faq"}); oth != nil {
+		t.Errorf("faq was routed in the router every guild shares")
+	}
+	if sug := Suggest("404", []string{"fqa"}); len(sug) > 0 {
+		t.Errorf("suggested %q in another guild", sug)
+	}
+	got, err := run(t, ses, com, testUser, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hi <@" + testUser + "> in <#" + testChannel + ">, you said a b"; got != want {
+		t.Errorf("!faq a b sent %q; want %q", got, want)
+	}
+
+	_, err = run(t, ses, newCcEdit(), testOther, "faq", "bye {author}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = run(t, ses, com, testUser)
+	if got != "bye bob" {
+		t.Errorf("!faq after editing sent %q", got)
+	}
+
+	got, _ = run(t, ses, newCc(), testUser)
+	if !strings.Contains(got, "!faq") {
+		t.Errorf("!cc didn't list faq, sent %q", got)
+	}
+	run(t, ses, newHelp(), testUser)
+	got = ""
+	for _, m := range ses.Messages(testChannel) {
+		got += m.Content
+	}
+	if !strings.Contains(got, "!faq") {
+		t.Errorf("help didn't list faq, sent %q", got)
+	}
+
+	// custom commands are routed again on startup
+	unrouteCustom(testGuild, "faq")
+	if com, _ = GuildRoute(testGuild, []string{"faq"}); com != nil {
+		t.Fatalf("faq was still routed after unrouting it")
+	}
+	err = InitCustomCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if com, _ = GuildRoute(testGuild, []string{"faq"}); com == nil {
+		t.Errorf("InitCustomCommands didn't route faq")
+	}
+
+	_, err = run(t, ses, newCcRemove(), testOther, "faq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if com, _ = GuildRoute(testGuild, []string{"faq"}); com != nil {
+		t.Errorf("faq was still routed after removing it")
+	}
+	_, err = run(t, ses, newCcRemove(), testOther, "faq")
+	if err != ErrNoCustom {
+		t.Errorf("removing faq twice gave %v; want %v", err, ErrNoCustom)
+	}
+}
@@ -34,7 +34,7 @@ func (d *decimalSpiral) Desc() string {
 		strconv.Itoa(lowerLimit) + " and " + strconv.Itoa(upperLimit)
 }
 
-func (d *decimalSpiral) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (d *decimalSpiral) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	if d.Size%2 == 0 || d.Size < lowerLimit || d.Size > upperLimit {
 		return nil, ErrdecimalSpiralRange
 	}
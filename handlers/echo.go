@@ -1,8 +1,6 @@
 package handlers
 
 import (
-	"strings"
-
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/unswpcsoc/pcsocgo/commands"
@@ -10,7 +8,7 @@ import (
 
 type echo struct {
 	nilCommand
-	Input []string `arg:"input"`
+	Input string `arg:"input" rest:"true" optional:"true"`
 }
 
 func newEcho() *echo { return &echo{} }
@@ -19,12 +17,10 @@ func (e *echo) Aliases() []string { return []string{"echo"} }
 
 func (e *echo) Desc() string { return "Echo!" }
 
-func (e *echo) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var out string
-	if len(e.Input) == 0 {
+func (e *echo) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	out := e.Input
+	if len(out) == 0 {
 		out = "Echo!"
-	} else {
-		out = strings.Join(e.Input, " ")
 	}
 
 	return commands.NewSimpleSend(msg.ChannelID, out), nil
@@ -15,29 +15,15 @@ import (
 
 const (
 	keyEmoji       = "emoji"
-	thinkingEmoji  = string(0x1f914)
+	thinkingEmoji  = string(rune(0x1f914))
 	emojiLineLimit = 15
-
-	chungusW   = "<:cw:590153701252005907>"
-	chungus1   = "<:c1_0:590153698324381698>"
-	chungus2   = "<:c2_0:590153703609204760>"
-	chungus1_1 = "<:c1_1:590153699372826634>"
-	chungus3_1 = "<:c3_1:590153701281497109>"
-	chungus0_2 = "<:c0_2:590153690493747220>"
-	chungus1_2 = "<:c1_2:590153704129298442>"
-	chungus2_2 = "<:c2_2:590153702443319307>"
-	chungus3_2 = "<:c3_2:590153704121040898>"
-	chungus0_3 = "<:c0_3:590153695363203072>"
-	chungus1_3 = "<:c1_3:590153703454015493>"
-	chungus2_3 = "<:c2_3:590153701969231873>"
-	chungus3_3 = "<:c3_3:590153703697416192>"
 )
 
 var (
 	ErrEmojiNotInit = errors.New("emoji counter not initialised")
 )
 
-// emojis implements the Storer interface
+// emojis is how emoji counts were stored before each had its own key, see splitRecords
 type emojis struct {
 	Counter map[string]int
 	Start   time.Time
@@ -47,6 +33,68 @@ func (e *emojis) Index() string {
 	return keyEmoji
 }
 
+// emojiRecord implements the Storer interface, each is at commands.RecordKey(guild, emoji)
+type emojiRecord struct {
+	Emoji string
+	Count int
+}
+
+func (e *emojiRecord) Index() string {
+	return keyEmoji
+}
+
+// emojiStart implements the Storer interface, it's at commands.RecordKey(guild)
+type emojiStart struct {
+	Start time.Time
+}
+
+func (e *emojiStart) Index() string {
+	return keyEmoji
+}
+
+var emojiRecords = commands.NewCollection(func() commands.Storer { return &emojiRecord{} })
+
+// countEmoji adds delta to the counts of the guild's emojis, never going below 0
+func countEmoji(guildID string, texts []string, delta int) error {
+	if len(texts) == 0 {
+		return nil
+	}
+
+	return commands.DBTransact(func(tx *commands.DBTx) error {
+		// start counting
+		var start emojiStart
+		err := tx.Get(&start, commands.RecordKey(guildID), &start)
+		if err == commands.ErrDBNotFound {
+			if delta < 0 {
+				return nil
+			}
+			err = tx.Set(&emojiStart{time.Now()}, commands.RecordKey(guildID))
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, text := range texts {
+			rec := emojiRecord{Emoji: text}
+			err := tx.Get(&rec, commands.RecordKey(guildID, text), &rec)
+			if err == commands.ErrDBNotFound && delta < 0 {
+				continue
+			} else if err != nil && err != commands.ErrDBNotFound {
+				return err
+			}
+
+			rec.Count += delta
+			if rec.Count < 0 {
+				rec.Count = 0
+			}
+			if err = tx.Set(&rec, commands.RecordKey(guildID, text)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // emoji implements the Command interface
 type emoji struct {
 	nilCommand
@@ -68,7 +116,7 @@ func (e *emoji) Aliases() []string { return []string{"emoji", thinkingEmoji, "e"
 
 func (e *emoji) Desc() string { return "Prints a random custom server emoji" }
 
-func (e *emoji) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (e *emoji) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// get guild emojis
 	emojis, err := ses.GuildEmojis(msg.GuildID)
 	if err != nil {
@@ -123,10 +171,10 @@ func (e *emojiCount) Desc() string {
 	return "Prints a summary of the usage of custom server emojis\nNote: emoji are counted per message and reaction; using 10 of the same emoji in one message will only count as 1"
 }
 
-func (e *emojiCount) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (e *emojiCount) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Get emojis
-	var emo emojis
-	err := commands.DBGet(&emojis{}, keyEmoji, &emo)
+	var start emojiStart
+	err := commands.DBGet(&start, commands.RecordKey(msg.GuildID), &start)
 	if err == commands.ErrDBNotFound {
 		return nil, ErrEmojiNotInit
 	} else if err != nil {
@@ -140,31 +188,27 @@ func (e *emojiCount) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (
 	}
 
 	counts := []kv{}
-	for key, val := range emo.Counter {
-		counts = append(counts, kv{key, val})
+	err = emojiRecords.Ascend(commands.RecordPrefix(msg.GuildID), func(key string, v commands.Storer) bool {
+		rec := v.(*emojiRecord)
+		counts = append(counts, kv{rec.Emoji, rec.Count})
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	sort.Slice(counts, func(left, right int) bool {
 		return counts[left].Value > counts[right].Value
 	})
 
-	var title string = "Emoji stats (from " + emo.Start.Format("15:04:05 MST 2006-01-02") + "):\n"
+	var title string = "Emoji stats (from " + start.Start.Format("15:04:05 MST 2006-01-02") + "):"
 	lines := []string{}
 	for _, item := range counts {
 		lines = append(lines, fmt.Sprintf("%s : %d", item.Key, item.Value))
 	}
 
-	unregister, needUnregister := InitPaginated(ses, msg, title, lines, emojiLineLimit)
-
-	if needUnregister {
-		timer := time.NewTimer(2 * time.Minute)
-
-		<-timer.C
-
-		// yeet
-		unregister()
-	}
-
-	return nil, nil
+	pag := commands.NewPaginator(ses, msg.ChannelID).AddLines(title, lines, emojiLineLimit)
+	pag.Owner = msg.Author.ID
+	return nil, pag.Start(0)
 }
 
 type emojiChungus struct {
@@ -180,13 +224,16 @@ func (e *emojiChungus) Desc() string {
 	return "Prints a chungus with the emoji supplied or an emoji from this server (searches if a string is provided)"
 }
 
-func (e *emojiChungus) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (e *emojiChungus) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// get guild emojis
 	emojis, err := ses.GuildEmojis(msg.GuildID)
 	if err != nil {
 		return nil, err
 	}
 
+	// custom emoji for the chungus
+	ch := cfg().Emoji
+
 	// seed random
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -201,9 +248,9 @@ func (e *emojiChungus) MsgHandle(ses *discordgo.Session, msg *discordgo.Message)
 		// has an emoji (I think), put it all in
 		picked = strings.Join(e.Emoji, "")
 		for i := 0; i < len(e.Emoji)-1; i++ {
-			filler1 += chungusW
-			filler2 += chungus2_2
-			filler3 += chungus2_3
+			filler1 += ch["cw"]
+			filler2 += ch["c2_2"]
+			filler3 += ch["c2_3"]
 		}
 	} else {
 		// has a string other than that, search for the emoji, give a random one otherwise
@@ -227,16 +274,16 @@ func (e *emojiChungus) MsgHandle(ses *discordgo.Session, msg *discordgo.Message)
 		}
 		picked = strings.Join(outWords, "")
 		for i := 0; i < len(outWords)-1; i++ {
-			filler1 += chungusW
-			filler2 += chungus2_2
-			filler3 += chungus2_3
+			filler1 += ch["cw"]
+			filler2 += ch["c2_2"]
+			filler3 += ch["c2_3"]
 		}
 	}
 
-	chungachunga := chungusW + chungus1 + filler1 + chungus2 + chungusW + "\n"
-	chungachunga += chungusW + chungus1_1 + picked + chungus3_1 + "\n"
-	chungachunga += chungus0_2 + chungus1_2 + filler2 + chungus2_2 + chungus3_2 + "\n"
-	chungachunga += chungus0_3 + chungus1_3 + filler3 + chungus2_3 + chungus3_3
+	chungachunga := ch["cw"] + ch["c1_0"] + filler1 + ch["c2_0"] + ch["cw"] + "\n"
+	chungachunga += ch["cw"] + ch["c1_1"] + picked + ch["c3_1"] + "\n"
+	chungachunga += ch["c0_2"] + ch["c1_2"] + filler2 + ch["c2_2"] + ch["c3_2"] + "\n"
+	chungachunga += ch["c0_3"] + ch["c1_3"] + filler3 + ch["c2_3"] + ch["c3_3"]
 
 	return commands.NewSimpleSend(msg.ChannelID, chungachunga), nil
 }
@@ -251,13 +298,13 @@ func (e *emojiCunt) Aliases() []string { return []string{"emoji cunt"} }
 
 func (e *emojiCunt) Desc() string { return "OI" }
 
-func (e *emojiCunt) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (e *emojiCunt) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	return commands.NewSimpleSend(msg.ChannelID, utils.EmojiAlpha("OI CUNT")), nil
 }
 
 type emojiRegional struct {
 	nilCommand
-	Message []string `arg:"Message"`
+	Message string `arg:"message" rest:"true" optional:"true"`
 }
 
 func newEmojiRegional() *emojiRegional { return &emojiRegional{} }
@@ -266,158 +313,95 @@ func (e *emojiRegional) Aliases() []string { return []string{"emoji regional", "
 
 func (e *emojiRegional) Desc() string { return "Returns alphanumeric messages" }
 
-func (e *emojiRegional) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	return commands.NewSimpleSend(msg.ChannelID, utils.EmojiAlpha(strings.Join(e.Message, " "))), nil
+func (e *emojiRegional) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	return commands.NewSimpleSend(msg.ChannelID, utils.EmojiAlpha(e.Message)), nil
 }
 
 // logger for emoji count
-func initEmoji(ses *discordgo.Session) {
-	ses.AddHandler(func(se *discordgo.Session, mc *discordgo.MessageCreate) {
-
-		// lock the db for writing
-		commands.DBLock()
-		defer commands.DBUnlock()
-
+func initEmoji(ses commands.Session) {
+	ses.AddHandler(func(_ *discordgo.Session, mc *discordgo.MessageCreate) {
 		if mc.Author.Bot {
 			return
 		}
 
-		var emo emojis
-		// get the emoji list
-		err := commands.DBGet(&emojis{}, keyEmoji, &emo)
-		if err == commands.ErrDBNotFound {
-			// create a new emoji list
-			emo = emojis{
-				Counter: make(map[string]int),
-				Start:   time.Now(),
-			}
-		} else if err != nil {
-			return
-		}
-
 		// get guild emojis
-		var emojis []*discordgo.Emoji
-		emojis, err = ses.GuildEmojis(mc.GuildID)
+		emojis, err := ses.GuildEmojis(mc.GuildID)
 		if err != nil {
 			return
 		}
 
 		// check message for emojis
+		texts := []string{}
 		for _, emoji := range emojis {
 			var emojiText string = emoji.MessageFormat()
 			if strings.Contains(mc.Content, emojiText) {
-				emo.Counter[emojiText] += 1
+				texts = append(texts, emojiText)
 			}
 		}
 
-		// Set the in the db
-		commands.DBSet(&emo, keyEmoji)
+		// Count them in the db
+		countEmoji(mc.GuildID, texts, 1)
 	})
 
-	ses.AddHandler(func(se *discordgo.Session, mra *discordgo.MessageReactionAdd) {
-
-		// lock the db for writing
-		commands.DBLock()
-		defer commands.DBUnlock()
-
-		mem, err := se.State.Member(mra.GuildID, mra.UserID)
-		if err == nil {
-			if mem.User.Bot {
-				return
-			}
-		} else {
-			// fall back to session
-			usr, err := se.User(mra.UserID)
-			if err != nil {
-				return
-			}
-			if usr.Bot {
-				return
-			}
-		}
-
-		var emo emojis
-		// get the emoji list
-		err = commands.DBGet(&emojis{}, keyEmoji, &emo)
-		if err == commands.ErrDBNotFound {
-			// create a new emoji list
-			emo = emojis{
-				Counter: make(map[string]int),
-				Start:   time.Now(),
-			}
-		} else if err != nil {
+	ses.AddHandler(func(_ *discordgo.Session, mra *discordgo.MessageReactionAdd) {
+		if isBot(ses, mra.GuildID, mra.UserID) {
 			return
 		}
 
 		// get guild emojis
-		var emojis []*discordgo.Emoji
-		emojis, err = ses.GuildEmojis(mra.GuildID)
+		emojis, err := ses.GuildEmojis(mra.GuildID)
 		if err != nil {
 			return
 		}
 
 		// check reaction
+		texts := []string{}
 		for _, emoji := range emojis {
-			var emojiText string = emoji.MessageFormat()
 			if mra.Emoji.MessageFormat() == emoji.MessageFormat() {
-				emo.Counter[emojiText] += 1
+				texts = append(texts, emoji.MessageFormat())
 			}
 		}
 
-		// Set the in the db
-		commands.DBSet(&emo, keyEmoji)
+		// Count them in the db
+		countEmoji(mra.GuildID, texts, 1)
 	})
 
-	ses.AddHandler(func(se *discordgo.Session, mrr *discordgo.MessageReactionRemove) {
-
-		// lock the db for writing
-		commands.DBLock()
-		defer commands.DBUnlock()
-
-		mem, err := se.State.Member(mrr.GuildID, mrr.UserID)
-		if err == nil {
-			if mem.User.Bot {
-				return
-			}
-		} else {
-			// fall back to session
-			usr, err := se.User(mrr.UserID)
-			if err != nil {
-				return
-			}
-			if usr.Bot {
-				return
-			}
-		}
-
-		var emo emojis
-		// get the emoji list
-		err = commands.DBGet(&emojis{}, keyEmoji, &emo)
-		if err == commands.ErrDBNotFound {
-			return
-		} else if err != nil {
+	ses.AddHandler(func(_ *discordgo.Session, mrr *discordgo.MessageReactionRemove) {
+		if isBot(ses, mrr.GuildID, mrr.UserID) {
 			return
 		}
 
 		// get guild emojis
-		var emojis []*discordgo.Emoji
-		emojis, err = ses.GuildEmojis(mrr.GuildID)
+		emojis, err := ses.GuildEmojis(mrr.GuildID)
 		if err != nil {
 			return
 		}
 
 		// check reaction
+		texts := []string{}
 		for _, emoji := range emojis {
-			var emojiText string = emoji.MessageFormat()
 			if mrr.Emoji.MessageFormat() == emoji.MessageFormat() {
-				if emo.Counter[emojiText] > 0 {
-					emo.Counter[emojiText] -= 1
-				}
+				texts = append(texts, emoji.MessageFormat())
 			}
 		}
 
-		// Set the in the db
-		commands.DBSet(&emo, keyEmoji)
+		// Uncount them in the db
+		countEmoji(mrr.GuildID, texts, -1)
 	})
 	return
 }
+
+// isBot returns whether the user is a bot, or if we can't tell
+func isBot(ses commands.Session, guildID string, uid string) bool {
+	mem, err := ses.State().Member(guildID, uid)
+	if err == nil {
+		return mem.User.Bot
+	}
+
+	// fall back to session
+	usr, err := ses.User(uid)
+	if err != nil {
+		return true
+	}
+	return usr.Bot
+}
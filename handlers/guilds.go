@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// what guild channels are used for
+const (
+	channelLog     = "log"
+	channelArchive = "archive"
+	channelClean   = "clean"
+	channelRules   = "rules"
+)
+
+// guildChannel returns the channel the guild uses for the purpose, empty if it doesn't have one
+func guildChannel(guildID string, purpose string) string {
+	if cha := commands.GetGuild(guildID).Channel(purpose); len(cha) > 0 {
+		return cha
+	}
+	return cfg().Channel(guildID, purpose)
+}
+
+// MigrateGuildKeys moves everything stored from before the bot had more than one guild into the guild's namespace
+//
+// It needs the guild, so it runs after DBOpen's migrations and does their work on what it moves.
+func MigrateGuildKeys(guildID string) error {
+	moves := []struct {
+		s   commands.Storer
+		key string
+	}{
+		{&tagStorer{}, tagsKey},
+		{&quotes{}, keyQuotes},
+		{&quotes{}, keyPending},
+		{&emojis{}, keyEmoji},
+		{&birthdayStorer{}, bdaysKey},
+	}
+
+	for _, mv := range moves {
+		err := commands.DBMoveToGuild(mv.s, mv.key, guildID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the migrations ran before these were in a guild
+	return commands.DBTransact(func(tx *commands.DBTx) error {
+		return splitRecords(tx, guildID)
+	})
+}
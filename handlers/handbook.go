@@ -57,7 +57,7 @@ func (h *handbook) Aliases() []string { return []string{"handbook"} }
 
 func (h *handbook) Desc() string { return "Searches handbook.unsw for course" }
 
-func (h *handbook) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (h *handbook) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 
 	// Special case for DELL1234
 	if strings.ToUpper(h.Code) == "DELL1234" {
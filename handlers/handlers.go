@@ -3,87 +3,139 @@
 package handlers
 
 import (
-	"fmt"
-	"math"
-
-	"github.com/bwmarrin/discordgo"
+	"errors"
+	"time"
 
 	"github.com/unswpcsoc/pcsocgo/commands"
 	"github.com/unswpcsoc/pcsocgo/internal/router"
 )
 
 const (
-	emojiLeft          = "jrleft:681465381298503802"
-	emojiRight         = "jrright:681465381356961827"
-	fallbackEmojiLeft  = "⬅️"
-	fallbackEmojiRight = "➡️"
+	// confirmTimeout is how long users get to confirm destructive commands
+	confirmTimeout = 30 * time.Second
+	// abbrevLength is the shortest abbreviation of a command that's routed e.g. !quo se
+	abbrevLength = 2
+)
+
+var (
+	// ErrAborted means the user didn't confirm a command
+	ErrAborted = errors.New("aborted")
 )
 
 var commandRouter *router.Router
 
 func init() {
 	commandRouter = router.NewRouter()
+	commandRouter.FoldCase = true
+	commandRouter.MinAbbrev = abbrevLength
+
+	addCommand(newCc())
+	addCommand(newCcAdd())
+	addCommand(newCcEdit())
+	addCommand(newCcRemove())
+
+	addCommand(newCommandRoot())
+	addCommand(newCommandDisable())
+	addCommand(newCommandEnable())
+
+	addCommand(newConfig())
+	addCommand(newConfigGet())
+	addCommand(newConfigSet())
+
+	addCommand(newDB())
+	addCommand(newDBDump())
+	addCommand(newDBRestore())
 
-	commandRouter.AddCommand(newDecimalSpiral())
+	addCommand(newDecimalSpiral())
 
-	commandRouter.AddCommand(newEcho())
+	addCommand(newEcho())
 
-	commandRouter.AddCommand(newHelp())
+	addCommand(newHelp())
 
-	commandRouter.AddCommand(newLog())
-	commandRouter.AddCommand(newLogDelete())
-	commandRouter.AddCommand(newLogFilter())
+	addCommand(newLog())
+	addCommand(newLogDelete())
+	addCommand(newLogFilter())
 
-	commandRouter.AddCommand(newPing())
+	addCommand(newPerms())
+	addCommand(newPermsAllow())
+	addCommand(newPermsDeny())
+	addCommand(newPermsReset())
 
-	commandRouter.AddCommand(newQuote())
-	commandRouter.AddCommand(newQuoteAdd())
-	commandRouter.AddCommand(newQuoteApprove())
-	commandRouter.AddCommand(newQuoteList())
-	commandRouter.AddCommand(newQuotePending())
-	commandRouter.AddCommand(newQuoteRemove())
-	commandRouter.AddCommand(newQuoteReject())
-	commandRouter.AddCommand(newQuoteSearch())
-	commandRouter.AddCommand(newQuoteClean())
+	addCommand(newPing())
 
-	commandRouter.AddCommand(newRole("Bookworm"))
-	commandRouter.AddCommand(newRole("Meta"))
-	commandRouter.AddCommand(newRole("Weeb"))
+	addCommand(newPrefix())
+	addCommand(newPrefixSet())
 
-	commandRouter.AddCommand(newTags())
-	commandRouter.AddCommand(newTagsAdd())
-	commandRouter.AddCommand(newTagsClean())
-	commandRouter.AddCommand(newTagsGet())
-	commandRouter.AddCommand(newTagsList())
-	commandRouter.AddCommand(newTagsModRemove())
-	commandRouter.AddCommand(newTagsPing())
-	commandRouter.AddCommand(newTagsPingMe())
-	commandRouter.AddCommand(newTagsPlatforms())
-	commandRouter.AddCommand(newTagsRemove())
-	commandRouter.AddCommand(newTagsShutup())
-	commandRouter.AddCommand(newTagsUser())
+	addCommand(newQuote())
+	addCommand(newQuoteAdd())
+	addCommand(newQuoteApprove())
+	addCommand(newQuoteList())
+	addCommand(newQuotePending())
+	addCommand(newQuoteRemove())
+	addCommand(newQuoteReject())
+	addCommand(newQuoteSearch())
+	addCommand(newQuoteClean())
 
-	commandRouter.AddCommand(newArchive())
+	addCommand(newRole("Bookworm"))
+	addCommand(newRole("Meta"))
+	addCommand(newRole("Weeb"))
 
-	commandRouter.AddCommand(newStaticIce())
+	addCommand(newSuggest())
+	addCommand(newSuggestOff())
+	addCommand(newSuggestOn())
 
-	commandRouter.AddCommand(newHandbook())
+	addCommand(newTags())
+	addCommand(newTagsAdd())
+	addCommand(newTagsClean())
+	addCommand(newTagsGet())
+	addCommand(newTagsList())
+	addCommand(newTagsModRemove())
+	addCommand(newTagsPing())
+	addCommand(newTagsPingMe())
+	addCommand(newTagsPlatforms())
+	addCommand(newTagsRemove())
+	addCommand(newTagsShutup())
+	addCommand(newTagsUser())
 
-	commandRouter.AddCommand(newScream())
+	addCommand(newArchive())
 
-	//commandRouter.AddCommand(newRules())
-	//commandRouter.AddCommand(newRulesGet())
-	//commandRouter.AddCommand(newRulesSet())
+	addCommand(newStaticIce())
+
+	addCommand(newHandbook())
+
+	addCommand(newScream())
+
+	//addCommand(newRules())
+	//addCommand(newRulesGet())
+	//addCommand(newRulesSet())
+
+	addCommand(newEmoji())
+	addCommand(newEmojiCount())
+	addCommand(newEmojiChungus())
+	addCommand(newEmojiCunt())
+	addCommand(newEmojiRegional())
+
+	addCommand(newBirthday())
+	addCommand(newBirthdayRemove())
+	addCommand(newBirthdayModCheck())
+
+	for _, m := range migrations {
+		addMigration(m)
+	}
+}
 
-	commandRouter.AddCommand(newEmoji())
-	commandRouter.AddCommand(newEmojiCount())
-	commandRouter.AddCommand(newEmojiChungus())
-	commandRouter.AddCommand(newEmojiCunt())
-	commandRouter.AddCommand(newEmojiRegional())
+// addCommand routes the command, two commands with the same alias is a bug
+func addCommand(com commands.Command) {
+	if err := commandRouter.AddCommand(com); err != nil {
+		panic(err)
+	}
+}
 
-	commandRouter.AddCommand(newBirthday())
-	commandRouter.AddCommand(newBirthdayRemove())
-	commandRouter.AddCommand(newBirthdayModCheck())
+// addMigration adds a migration for commands.DBOpen, two with the same version is a bug
+func addMigration(m *commands.Migration) {
+	if err := commands.AddMigration(m); err != nil {
+		panic(err)
+	}
 }
 
 // RouterRoute is a wrapper around the handler package's internal router's Route method
@@ -104,9 +156,17 @@ func (n *nilCommand) Roles() []string { return nil }
 
 func (n *nilCommand) Chans() []string { return nil }
 
+// errAborted turns the error from an unconfirmed prompt into an error for the user
+func errAborted(err error) error {
+	if err == nil || err == commands.ErrPromptTimeout || err == commands.ErrPromptCancelled {
+		return ErrAborted
+	}
+	return err
+}
+
 // InitLogs inits all logging commands.
 // Needs to be maually updated when adding new loggers
-func InitLogs(ses *discordgo.Session) {
+func InitLogs(ses commands.Session) {
 	initFil(ses)
 	initDel(ses)
 	initArchive(ses)
@@ -114,10 +174,11 @@ func InitLogs(ses *discordgo.Session) {
 }
 
 // InitDaemons inits all daemons, returns a function to close all channels when done
-func InitDaemons(ses *discordgo.Session) (Close func()) {
+func InitDaemons(ses commands.Session) (Close func()) {
 	chans := []chan bool{}
 	chans = append(chans, initClean(ses))
 	chans = append(chans, initBirthday(ses))
+	chans = append(chans, initBackup(ses))
 
 	return func() {
 		// signal all channels on close
@@ -127,135 +188,3 @@ func InitDaemons(ses *discordgo.Session) (Close func()) {
 	}
 }
 
-// InitPaginated inits a reaction handler for a message to allow pagination
-func InitPaginated(ses *discordgo.Session, msg *discordgo.Message, title string, lines []string, lineLimit int) (unregister func(), needUnregister bool) {
-	// init return values
-	unregister = nil
-	needUnregister = false
-
-	// keep state of message
-	page := 0
-	// pages are indexed at 0
-	// Ceil(15/15)-1 = 0		| 15 15
-	// Ceil(30/15)-1 = 1		| 15 15
-	// Ceil(31/15)-1 = 2 		| 15 15 1
-	lastPage := int(math.Ceil(float64(len(lines))/float64(lineLimit))) - 1
-	lineLim := lineLimit
-	once := false
-	if len(lines) < lineLim {
-		lastPage = 0
-		lineLim = len(lines)
-		once = true
-	}
-
-	// send a message first
-	out := title
-	for _, line := range lines[0:lineLim] {
-		if line != "" {
-			out += "\n" + line
-		}
-	}
-	out += fmt.Sprintf("\n`Page 0/%d`", lastPage)
-
-	// send initial message
-	outMessage, err := ses.ChannelMessageSend(msg.ChannelID, out)
-	if err != nil {
-		return
-	}
-
-	// check once
-	if once {
-		return
-	}
-
-	// react with left and right emojis
-	err = ses.MessageReactionAdd(msg.ChannelID, outMessage.ID, emojiLeft)
-	if err != nil {
-		// use fallback emoji
-		err = ses.MessageReactionAdd(msg.ChannelID, outMessage.ID, fallbackEmojiLeft)
-		if err != nil {
-			return
-		}
-	}
-
-	err = ses.MessageReactionAdd(msg.ChannelID, outMessage.ID, emojiRight)
-	if err != nil {
-		// use fallback emoji
-		err = ses.MessageReactionAdd(msg.ChannelID, outMessage.ID, fallbackEmojiRight)
-		if err != nil {
-			return
-		}
-	}
-
-	rootUnregister := ses.AddHandler(func(innerSes *discordgo.Session, event *discordgo.MessageReactionAdd) {
-		reaction := event.MessageReaction
-
-		// listen for reactions on the specific message sent
-		if reaction.MessageID != outMessage.ID || reaction.UserID == outMessage.Author.ID {
-			return
-		}
-
-		// ignore non-control emoji
-		reactEmoji := reaction.Emoji.APIName()
-		if reactEmoji != emojiLeft && reactEmoji != emojiRight && reactEmoji != fallbackEmojiLeft && reactEmoji != fallbackEmojiRight {
-			return
-		}
-
-		// remove the reaction made by the user
-		err := innerSes.MessageReactionRemove(
-			reaction.ChannelID,
-			reaction.MessageID,
-			reaction.Emoji.APIName(),
-			reaction.UserID,
-		)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		if reactEmoji == emojiLeft || reactEmoji == fallbackEmojiLeft {
-			if page == 0 {
-				page = lastPage
-			} else {
-				page--
-			}
-		}
-
-		if reactEmoji == emojiRight || reactEmoji == fallbackEmojiRight {
-			if page+1 > lastPage {
-				page = 0
-			} else {
-				page++
-			}
-		}
-
-		// calculate bounds
-		left := page * lineLimit
-		right := (page + 1) * lineLimit
-		if right > len(lines) {
-			right = len(lines)
-		}
-
-		// construct edit message
-		edit := title
-		for _, line := range lines[left:right] {
-			if line != "" {
-				edit += "\n" + line
-			}
-		}
-		edit += fmt.Sprintf("\n`Page %d/%d`", page, lastPage)
-
-		// actually edit the damn message
-		innerSes.ChannelMessageEdit(reaction.ChannelID, reaction.MessageID, edit)
-	})
-
-	unregister = func() {
-		ses.MessageReactionsRemoveAll(msg.ChannelID, outMessage.ID)
-		ses.MessageReactionsRemoveAll(msg.ChannelID, outMessage.ID)
-		rootUnregister()
-	}
-
-	// set needs unregister
-	needUnregister = true
-	return
-}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+/* preamble */
+
+const (
+	testBot     = "1"
+	testGuild   = "10"
+	testChannel = "20"
+	testUser    = "30"
+	testOther   = "31"
+	testMod     = "40"
+	testWeeb    = "41"
+	testBday    = "42"
+)
+
+func TestMain(m *testing.M) {
+	commands.DBOpenStore("memory", "")
+	res := m.Run()
+	commands.DBClose()
+	os.Exit(res)
+}
+
+// newTestSession returns a fake session with a guild, a channel,
+// two members and a few roles
+func newTestSession() *commands.FakeSession {
+	ses := commands.NewFakeSession(testBot)
+	ses.State().GuildAdd(&discordgo.Guild{
+		ID:   testGuild,
+		Name: "PCSoc",
+		Roles: []*discordgo.Role{
+			&discordgo.Role{ID: testMod, Name: "Mod"},
+			&discordgo.Role{ID: testWeeb, Name: "Weeb"},
+			&discordgo.Role{ID: testBday, Name: "Birthday Boy"},
+		},
+		Channels: []*discordgo.Channel{
+			&discordgo.Channel{ID: testChannel, GuildID: testGuild, Name: "general"},
+		},
+		Members: []*discordgo.Member{
+			&discordgo.Member{
+				GuildID: testGuild,
+				User:    &discordgo.User{ID: testUser, Username: "bob"},
+			},
+			&discordgo.Member{
+				GuildID: testGuild,
+				User:    &discordgo.User{ID: testOther, Username: "alice"},
+				Roles:   []string{testMod},
+			},
+		},
+	})
+	return ses
+}
+
+// newTestMessage returns a message sent by uid in the test channel
+func newTestMessage(uid string) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        "50",
+		ChannelID: testChannel,
+		GuildID:   testGuild,
+		Author:    &discordgo.User{ID: uid},
+	}
+}
+
+// clearDB removes everything from the db
+func clearDB(t *testing.T) {
+	err := commands.DB.Update(func(tx commands.StoreTx) error {
+		keys := []string{}
+		tx.AscendPrefix("", func(key, val string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		for _, key := range keys {
+			if err := tx.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run fills the command's args and handles the message, sending anything returned.
+// Returns the content of the last message in the test channel.
+func run(t *testing.T, ses *commands.FakeSession, com commands.Command, uid string, args ...string) (string, error) {
+	t.Helper()
+	defer commands.CleanArgs(com)
+
+	msg := newTestMessage(uid)
+	err := commands.FillArgs(ses, msg, com, args)
+	if err != nil {
+		return "", err
+	}
+
+	snd, err := com.MsgHandle(ses, msg)
+	if err != nil {
+		return "", err
+	}
+
+	if snd != nil {
+		err = snd.Send(ses)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last := ses.LastMessage(testChannel)
+	if last == nil {
+		return "", nil
+	}
+	return last.Content, nil
+}
+
+// answer reacts with emo from uid to the next prompt in testChannel, in the background
+func answer(ses *commands.FakeSession, uid string, emo string) {
+	go func() {
+		for i := 0; i < 1000; i++ {
+			last := ses.LastMessage(testChannel)
+			if last != nil {
+				for _, r := range ses.Reactions(last.ID) {
+					if r.Emoji.Name == emo {
+						ses.Emit(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+							UserID:    uid,
+							MessageID: last.ID,
+							ChannelID: testChannel,
+							Emoji:     discordgo.Emoji{Name: emo},
+						}})
+						return
+					}
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+}
+
+/* tests */
+
+// TestRole toggles a role on and off
+func TestRole(t *testing.T) {
+	ses := newTestSession()
+
+	got, err := run(t, ses, newRole("Weeb"), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "<@!"+testUser+"> is now a weeb" {
+		t.Errorf("!weeb sent %q", got)
+	}
+
+	mem, _ := ses.GuildMember(testGuild, testUser)
+	if len(mem.Roles) != 1 || mem.Roles[0] != testWeeb {
+		t.Errorf("!weeb gave roles %v; want [%s]", mem.Roles, testWeeb)
+	}
+
+	_, err = run(t, ses, newRole("Weeb"), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mem.Roles) != 0 {
+		t.Errorf("second !weeb left roles %v; want []", mem.Roles)
+	}
+
+	// missing role
+	_, err = run(t, ses, newRole("Meta"), testUser)
+	if err == nil {
+		t.Errorf("!meta with no role in guild didn't throw an error")
+	}
+}
+
+// TestHelp sends the usage of every command
+func TestHelp(t *testing.T) {
+	ses := newTestSession()
+
+	_, err := run(t, ses, newHelp(), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := ""
+	for _, msg := range ses.Messages(testChannel) {
+		if len(msg.Attachments) > 0 {
+			t.Errorf("!%s sent an attachment", HelpAlias)
+		}
+		all += msg.Content + "\n"
+	}
+	for _, exp := range []string{"All Commands", commands.GetUsage(newHelp()), commands.GetUsage(newQuote())} {
+		if !strings.Contains(all, exp) {
+			t.Errorf("!%s is missing %q", HelpAlias, exp)
+		}
+	}
+}
+
+// TestRoute routes commands regardless of case and by abbreviation
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want string
+	}{
+		{[]string{"Quote"}, "quote"},
+		{[]string{"quo", "se", "words"}, "quote search"},
+		{[]string{"QUOTE", "AP"}, "quote approve"},
+		{[]string{"quote", "re"}, "quote"}, // remove or reject
+	}
+	for _, tt := range tests {
+		com, _ := RouterRoute(tt.argv)
+		if com == nil || com.Aliases()[0] != tt.want {
+			t.Errorf("routed %q to %v; want %s", tt.argv, com, tt.want)
+		}
+	}
+}
+
+// TestSlashCommands checks that every command makes a slash command discord will accept
+func TestSlashCommands(t *testing.T) {
+	name := regexp.MustCompile(`^[-_\p{L}\p{N}]{1,32}$`)
+
+	var check func(path string, opts []*commands.ApplicationCommandOption)
+	check = func(path string, opts []*commands.ApplicationCommandOption) {
+		seen := map[string]bool{}
+		optional := false
+		for _, opt := range opts {
+			if !name.MatchString(opt.Name) || seen[opt.Name] {
+				t.Errorf("/%s has a bad or repeated option name %q", path, opt.Name)
+			}
+			seen[opt.Name] = true
+			if len(opt.Description) == 0 || utf8.RuneCountInString(opt.Description) > 100 {
+				t.Errorf("/%s %s has a bad description %q", path, opt.Name, opt.Description)
+			}
+			if opt.Required && optional {
+				t.Errorf("/%s has required option %s after an optional one", path, opt.Name)
+			}
+			optional = optional || !opt.Required
+			check(path+" "+opt.Name, opt.Options)
+		}
+	}
+
+	for _, ac := range commands.SlashCommands(RouterToSlice()) {
+		if !name.MatchString(ac.Name) || len(ac.Description) == 0 {
+			t.Errorf("bad slash command %q: %q", ac.Name, ac.Description)
+		}
+		check(ac.Name, ac.Options)
+	}
+}
@@ -27,13 +27,19 @@ func (h *help) Aliases() []string { return []string{HelpAlias, "commands", "fuck
 
 func (h *help) Desc() string { return "help!" }
 
-func (h *help) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (h *help) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	snd := commands.NewSend(msg.ChannelID)
+	pre := commands.GuildPrefix(msg.GuildID)
+	dis, err := commands.GetDisabled(msg.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
 	var out string
 	if len(h.Query) == 0 {
 		// consider rate-limiting/re-routing/disabling this if your message becomes enormous
 		ignore := map[string]bool{}
-		routerSlice := RouterToSlice()
+		routerSlice := append(RouterToSlice(), customCommands(msg.GuildID)...)
 		for _, com := range routerSlice {
 			// register subcommands to be ignored using first alias
 			if com.Subcommands() != nil {
@@ -48,34 +54,37 @@ func (h *help) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comma
 			}
 		}
 
-		count := 0
+		// Send splits this up for us
 		out = utils.Bold("All Commands:")
 		for _, com := range routerSlice {
 			// ignore subcommands
 			if seen, _ := ignore[com.Aliases()[0]]; seen {
 				continue
 			}
-
-			tmp := "\n" + commands.GetUsage(com)
-			count += len(tmp)
-			if count < commands.MessageLimit {
-				out += tmp
-			} else {
-				snd.Message(out)
-				out = tmp
-				count = len(tmp)
+			// ignore commands disabled here
+			if dis.Disabled(RouterRoute, msg.ChannelID, com) {
+				continue
 			}
+
+			out += "\n" + commands.GetUsagePrefix(com, pre)
 		}
 		snd.Message(out)
 	} else {
-		com, _ := RouterRoute(h.Query)
+		com, _ := routeIn(msg.GuildID, h.Query)
 		if com != nil {
 			out = "Command " + utils.Bold(com.Aliases()[0])
-			out += "\n" + commands.GetUsage(com)
+			if dis.Disabled(RouterRoute, msg.ChannelID, com) {
+				out += " " + utils.Italics("(disabled here)")
+			}
+			out += "\n" + commands.GetUsagePrefix(com, pre)
 			snd.Message(out)
 		} else {
 			// user provided bad command string, use fuzzy finding to find suggestions
-			mat := fuzzy.Find(strings.Join(h.Query, " "), RouterToStringSlice())
+			aliases := RouterToStringSlice()
+			for _, com := range customCommands(msg.GuildID) {
+				aliases = append(aliases, com.Aliases()...)
+			}
+			mat := fuzzy.Find(strings.Join(h.Query, " "), aliases)
 
 			out = "Unknown command provided"
 			if len(mat) > 0 {
@@ -86,7 +95,7 @@ func (h *help) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comma
 					if i == 3 {
 						break
 					}
-					out += utils.Code(commands.Prefix+m.Str) + "\n"
+					out += utils.Code(pre+m.Str) + "\n"
 				}
 			}
 			snd.Message(out)
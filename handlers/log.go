@@ -5,7 +5,6 @@ import (
 	"errors"
 	logs "log"
 	"net/http"
-	"regexp"
 
 	//"strconv"
 	"strings"
@@ -16,30 +15,14 @@ import (
 )
 
 const (
-	cacheLimit  = 100
 	embedColour = 0xff0000
-	logChannel  = "529463078610534410" // #report
 )
 
 var (
 	killDel func()
 	killFil func()
 
-	msgCache = NewMapCache(cacheLimit)
-
-	badWords = []*regexp.Regexp{
-		regexp.MustCompile("(?i)kms"),
-		regexp.MustCompile("(?i)kill[[:space:]]*myself"),
-		regexp.MustCompile("(?i)kill[[:space:]]*me"),
-		regexp.MustCompile("(?i)retard"),
-		regexp.MustCompile("(?i)ni[bg]+er"),
-		regexp.MustCompile("(?i)ni[bg]+a"),
-		regexp.MustCompile("(?i)autis[tm]"),
-		regexp.MustCompile("(?i)nang"),
-		regexp.MustCompile("(?i)my[[:space:]]address[[:space:]]is"),
-		regexp.MustCompile("(?i)i[[:space:]]want[[:space:]]to[[:space:]]jump[[:space:]]off[[:space:]]a[[:space:]]tall[[:space:]]building[[:space:]]and[[:space:]]splatter[[:space:]]into[[:space:]]a[[:space:]]million[[:space:]]pieces"),
-		regexp.MustCompile("(?i)suck[[:space:]]my[[:space:]]left[[:space:]]nut"),
-	}
+	msgCache = NewMapCache(conf.CacheLimit)
 
 	ErrLoggingOn  = errors.New("logging is already on")
 	ErrLoggingOff = errors.New("logging is already off")
@@ -71,6 +54,11 @@ func NewMapCache(lim int) *MapCache {
 	}
 }
 
+// SetLimit changes the limit, extra pairs are dropped on the next Insert
+func (m *MapCache) SetLimit(lim int) {
+	m.limit = lim
+}
+
 // Insert puts a key-value pair
 func (m *MapCache) Insert(ky string, vl *discordgo.Message) {
 	// ensure order slice is below limit
@@ -150,7 +138,7 @@ func (l *log) Subcommands() []commands.Command {
 	}
 }
 
-func (l *log) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (l *log) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	stat := ""
 	if l.Mode {
 		// TODO: test
@@ -193,7 +181,7 @@ func (l *logDelete) Desc() string {
 
 func (l *logDelete) Subcommands() []commands.Command { return nil }
 
-func (l *logDelete) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (l *logDelete) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	stat := ""
 	if l.Mode {
 		if killDel != nil {
@@ -230,7 +218,7 @@ func (l *logFilter) Desc() string {
 
 func (l *logFilter) Subcommands() []commands.Command { return nil }
 
-func (l *logFilter) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (l *logFilter) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	stat := ""
 	if l.Mode {
 		if killFil != nil {
@@ -253,16 +241,16 @@ func (l *logFilter) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*
 	return commands.NewSimpleSend(msg.ChannelID, "MessageFilter logging has been turned "+stat), nil
 }
 
-func initDel(ses *discordgo.Session) {
-	tmp1 := ses.AddHandler(func(se *discordgo.Session, mc *discordgo.MessageCreate) {
+func initDel(ses commands.Session) {
+	tmp1 := ses.AddHandler(func(_ *discordgo.Session, mc *discordgo.MessageCreate) {
 		msg := mc.Message
-		if msg.Author.ID == se.State.User.ID {
+		if msg.Author.ID == ses.State().User.ID {
 			return
 		}
 		msgCache.Insert(msg.ID+msg.ChannelID, msg)
 	})
 
-	tmp2 := ses.AddHandler(func(se *discordgo.Session, dm *discordgo.MessageDelete) {
+	tmp2 := ses.AddHandler(func(_ *discordgo.Session, dm *discordgo.MessageDelete) {
 		// get from cache
 		dtd, img, ok := msgCache.Pop(dm.Message.ID + dm.Message.ChannelID)
 		if !ok {
@@ -270,6 +258,11 @@ func initDel(ses *discordgo.Session) {
 			return
 		}
 
+		logChannel := guildChannel(dtd.GuildID, channelLog)
+		if len(logChannel) == 0 {
+			return
+		}
+
 		// craft message
 		out := &discordgo.MessageSend{
 			Content: "",
@@ -307,7 +300,7 @@ func initDel(ses *discordgo.Session) {
 			}
 		*/
 
-		cha, err := ses.State.Channel(dtd.ChannelID)
+		cha, err := ses.State().Channel(dtd.ChannelID)
 		if err != nil {
 			logs.Println(err)
 			return
@@ -331,7 +324,7 @@ func initDel(ses *discordgo.Session) {
 			out.File = img
 		}
 
-		se.ChannelMessageSendComplex(logChannel, out)
+		ses.ChannelMessageSendComplex(logChannel, out)
 	})
 	killDel = func() {
 		tmp1()
@@ -339,17 +332,17 @@ func initDel(ses *discordgo.Session) {
 	}
 }
 
-func initFil(ses *discordgo.Session) {
-	killFil = ses.AddHandler(func(se *discordgo.Session, mc *discordgo.MessageCreate) {
+func initFil(ses commands.Session) {
+	killFil = ses.AddHandler(func(_ *discordgo.Session, mc *discordgo.MessageCreate) {
 		msg := mc.Message
-		if msg.Author.ID == se.State.User.ID {
+		if msg.Author.ID == ses.State().User.ID {
 			return
 		}
 
 		bad := false
 		matchString := ""
 		// check for BAD WORDS
-		for _, bw := range badWords {
+		for _, bw := range filterWords() {
 			if bw.MatchString(msg.Content) {
 				// bad word detected
 				bad = true
@@ -362,6 +355,11 @@ func initFil(ses *discordgo.Session) {
 			return
 		}
 
+		logChannel := guildChannel(msg.GuildID, channelLog)
+		if len(logChannel) == 0 {
+			return
+		}
+
 		// craft fields
 		fields := []*discordgo.MessageEmbedField{
 			&discordgo.MessageEmbedField{
@@ -376,13 +374,13 @@ func initFil(ses *discordgo.Session) {
 			},
 		}
 
-		cha, err := ses.State.Channel(mc.Message.ChannelID)
+		cha, err := ses.State().Channel(mc.Message.ChannelID)
 		if err != nil {
 			logs.Println(err)
 			return
 		}
 
-		se.ChannelMessageSendEmbed(logChannel, &discordgo.MessageEmbed{
+		ses.ChannelMessageSendEmbed(logChannel, &discordgo.MessageEmbed{
 			Title: "Bad Word Detected in " + cha.Name,
 			Author: &discordgo.MessageEmbedAuthor{
 				IconURL: msg.Author.AvatarURL(""),
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// migrations change what handlers store, never change or remove one once it's shipped
+var migrations = []*commands.Migration{
+	&commands.Migration{
+		Version: 1,
+		Desc:    "split tags, approved quotes and emoji counts into a record each",
+		Up: func(tx *commands.DBTx) error {
+			// find every guild with something to split
+			gids := make(map[string]bool)
+			blobs := []struct {
+				s   commands.Storer
+				key string
+			}{
+				{&tagStorer{}, tagsKey},
+				{&quotes{}, keyQuotes},
+				{&emojis{}, keyEmoji},
+			}
+			for _, blob := range blobs {
+				keys, err := tx.Keys(blob.s, commands.GuildKey("*", blob.key))
+				if err != nil {
+					return err
+				}
+				for _, key := range keys {
+					gids[strings.TrimSuffix(key, ":"+blob.key)] = true
+				}
+			}
+
+			for gid := range gids {
+				if err := splitRecords(tx, gid); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// splitRecords splits the guild's tags, approved quotes and emoji counts into a record each,
+// from when each was all stored in one big Storer
+func splitRecords(tx *commands.DBTx, guildID string) error {
+	err := tx.Split(&tagStorer{}, commands.GuildKey(guildID, tagsKey), func(v commands.Storer) (map[string]commands.Storer, error) {
+		recs := make(map[string]commands.Storer)
+		for pname, plt := range v.(*tagStorer).Platforms {
+			for uid, utg := range plt.Users {
+				utg.Platform = pname
+				recs[commands.RecordKey(guildID, pname, uid)] = utg
+			}
+			recs[commands.RecordKey(guildID, pname)] = &platform{Name: plt.Name, Role: plt.Role}
+		}
+		return recs, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Split(&quotes{}, commands.GuildKey(guildID, keyQuotes), func(v commands.Storer) (map[string]commands.Storer, error) {
+		recs := make(map[string]commands.Storer)
+		for i, quote := range v.(*quotes).List {
+			if len(quote) > 0 {
+				recs[quoteKey(guildID, i)] = &quoteRecord{quote}
+			}
+		}
+		return recs, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Split(&emojis{}, commands.GuildKey(guildID, keyEmoji), func(v commands.Storer) (map[string]commands.Storer, error) {
+		emo := v.(*emojis)
+		recs := map[string]commands.Storer{
+			commands.RecordKey(guildID): &emojiStart{emo.Start},
+		}
+		for text, count := range emo.Counter {
+			recs[commands.RecordKey(guildID, text)] = &emojiRecord{Emoji: text, Count: count}
+		}
+		return recs, nil
+	})
+}
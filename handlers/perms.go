@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+var (
+	// ErrNoCommand means the args don't name a command
+	ErrNoCommand = errors.New("there's no command with that name")
+	// ErrBadPermTarget means the target isn't a role, channel, permission or group
+	ErrBadPermTarget = errors.New("that isn't a role, channel, permission or group")
+	// ErrPermsSelf means a mod tried to change who can use perms, which could lock everyone out
+	ErrPermsSelf = errors.New("perms can't be changed with perms")
+)
+
+// permsCommand returns the command in the guild named by the args, which must all be used
+func permsCommand(guildID string, argv []string) (commands.Command, error) {
+	if len(argv) == 0 {
+		return nil, ErrNoCommand
+	}
+	com, ind := routeIn(guildID, argv)
+	if com == nil || ind != len(argv) {
+		return nil, ErrNoCommand
+	}
+	return com, nil
+}
+
+// permsTarget resolves the target to something rules take, and whether it's a channel
+//
+// Roles and channels are stored by id so renaming them doesn't break rules.
+func permsTarget(ses commands.Session, guildID string, target string) (string, bool, error) {
+	if strings.HasPrefix(target, "<#") && strings.HasSuffix(target, ">") {
+		return target[2 : len(target)-1], true, nil
+	}
+	if strings.HasPrefix(target, "<@&") && strings.HasSuffix(target, ">") {
+		return target[3 : len(target)-1], false, nil
+	}
+	if _, ok := commands.Permissions[target]; ok {
+		return target, false, nil
+	}
+	if _, ok := commands.PermissionGroup(guildID, target); ok {
+		return target, false, nil
+	}
+
+	roles, err := ses.GuildRoles(guildID)
+	if err != nil {
+		return "", false, err
+	}
+	for _, rol := range roles {
+		if rol.ID == target || strings.EqualFold(rol.Name, target) {
+			return rol.ID, false, nil
+		}
+	}
+
+	chans, err := ses.GuildChannels(guildID)
+	if err != nil {
+		return "", false, err
+	}
+	for _, cha := range chans {
+		if cha.ID == target || strings.EqualFold(cha.Name, strings.TrimPrefix(target, "#")) {
+			return cha.ID, true, nil
+		}
+	}
+	return "", false, ErrBadPermTarget
+}
+
+// permsList returns the targets formatted for discord, role ids are shown by name
+func permsList(ses commands.Session, guildID string, targets []string, channels bool) string {
+	out := []string{}
+	for _, tar := range targets {
+		switch {
+		case channels:
+			out = append(out, "<#"+tar+">")
+		default:
+			if rol, err := ses.State().Role(guildID, tar); err == nil {
+				tar = rol.Name
+			}
+			out = append(out, utils.Code(tar))
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// permsShow returns a description of the command's rules
+func permsShow(ses commands.Session, guildID string, name string, rul *commands.CommandRules) string {
+	out := utils.Bold(name)
+	lines := []struct {
+		desc     string
+		targets  []string
+		channels bool
+	}{
+		{"Allowed for", rul.AllowRoles, false},
+		{"Denied for", rul.DenyRoles, false},
+		{"Allowed in", rul.AllowChans, true},
+		{"Denied in", rul.DenyChans, true},
+	}
+	for _, ln := range lines {
+		if len(ln.targets) > 0 {
+			out += "\n" + ln.desc + " " + permsList(ses, guildID, ln.targets, ln.channels)
+		}
+	}
+	return out
+}
+
+// without returns the list without the string
+func without(list []string, s string) []string {
+	out := []string{}
+	for _, l := range list {
+		if l != s {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// permsChange allows or denies the target for the command named by argv
+func permsChange(ses commands.Session, msg *discordgo.Message, target string, argv []string, allow bool) (*commands.CommandSend, error) {
+	com, err := permsCommand(msg.GuildID, argv)
+	if err != nil {
+		return nil, err
+	}
+	name := com.Aliases()[0]
+	if strings.HasPrefix(name, "perms") {
+		return nil, ErrPermsSelf
+	}
+
+	tar, isChan, err := permsTarget(ses, msg.GuildID, target)
+	if err != nil {
+		return nil, err
+	}
+
+	rul, err := commands.GetCommandRules(msg.GuildID, name)
+	if err == commands.ErrNoCommandRules {
+		rul = &commands.CommandRules{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// a target is only ever allowed or denied
+	add, rem := &rul.AllowRoles, &rul.DenyRoles
+	if isChan {
+		add, rem = &rul.AllowChans, &rul.DenyChans
+	}
+	if !allow {
+		add, rem = rem, add
+	}
+	*rem = without(*rem, tar)
+	*add = append(without(*add, tar), tar)
+
+	err = commands.SetCommandRules(msg.GuildID, name, rul)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, permsShow(ses, msg.GuildID, name, rul)), nil
+}
+
+/* perms */
+
+type perms struct {
+	nilCommand
+	Command []string `arg:"command"`
+}
+
+func newPerms() *perms { return &perms{} }
+
+func (p *perms) Aliases() []string { return []string{"perms"} }
+
+func (p *perms) Desc() string {
+	return "Shows who can use a command on this server, or which commands have been changed if you leave it out."
+}
+
+func (p *perms) Roles() []string { return []string{"mod"} }
+
+func (p *perms) Subcommands() []commands.Command {
+	return []commands.Command{
+		newPermsAllow(),
+		newPermsDeny(),
+		newPermsReset(),
+	}
+}
+
+func (p *perms) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	if len(p.Command) == 0 {
+		rls, err := commands.GuildCommandRules(msg.GuildID)
+		if err != nil {
+			return nil, err
+		}
+		if len(rls) == 0 {
+			return commands.NewSimpleSend(msg.ChannelID, "No commands have been changed on this server"), nil
+		}
+
+		names := []string{}
+		for name := range rls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		out := []string{}
+		for _, name := range names {
+			out = append(out, permsShow(ses, msg.GuildID, name, rls[name]))
+		}
+		return commands.NewSimpleSend(msg.ChannelID, strings.Join(out, "\n\n")), nil
+	}
+
+	com, err := permsCommand(msg.GuildID, p.Command)
+	if err != nil {
+		return nil, err
+	}
+	name := com.Aliases()[0]
+
+	out := ""
+	rul, err := commands.GetCommandRules(msg.GuildID, name)
+	if err == commands.ErrNoCommandRules {
+		out = utils.Bold(name) + "\nNo changes on this server"
+	} else if err != nil {
+		return nil, err
+	} else {
+		out = permsShow(ses, msg.GuildID, name, rul)
+	}
+
+	if roles := com.Roles(); len(roles) > 0 {
+		out += "\nNeeds " + permsList(ses, msg.GuildID, roles, false)
+	}
+	if chans := com.Chans(); len(chans) > 0 {
+		out += "\nOnly in " + strings.Join(chans, ", ")
+	}
+	return commands.NewSimpleSend(msg.ChannelID, out), nil
+}
+
+/* perms allow */
+
+type permsAllow struct {
+	nilCommand
+	Target  string   `arg:"target"`
+	Command []string `arg:"command"`
+}
+
+func newPermsAllow() *permsAllow { return &permsAllow{} }
+
+func (p *permsAllow) Aliases() []string { return []string{"perms allow"} }
+
+func (p *permsAllow) Desc() string {
+	return "Lets a role, permission or group use a command without its usual roles, or lets it be used in a channel," +
+		" e.g. perms allow ManageMessages quote approve"
+}
+
+func (p *permsAllow) Roles() []string { return []string{"mod"} }
+
+func (p *permsAllow) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	return permsChange(ses, msg, p.Target, p.Command, true)
+}
+
+/* perms deny */
+
+type permsDeny struct {
+	nilCommand
+	Target  string   `arg:"target"`
+	Command []string `arg:"command"`
+}
+
+func newPermsDeny() *permsDeny { return &permsDeny{} }
+
+func (p *permsDeny) Aliases() []string { return []string{"perms deny"} }
+
+func (p *permsDeny) Desc() string {
+	return "Stops a role, permission or group using a command, or stops it being used in a channel," +
+		" e.g. perms deny #general tags"
+}
+
+func (p *permsDeny) Roles() []string { return []string{"mod"} }
+
+func (p *permsDeny) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	return permsChange(ses, msg, p.Target, p.Command, false)
+}
+
+/* perms reset */
+
+type permsReset struct {
+	nilCommand
+	Command []string `arg:"command"`
+}
+
+func newPermsReset() *permsReset { return &permsReset{} }
+
+func (p *permsReset) Aliases() []string { return []string{"perms reset"} }
+
+func (p *permsReset) Desc() string {
+	return "Undoes all changes to who can use a command on this server."
+}
+
+func (p *permsReset) Roles() []string { return []string{"mod"} }
+
+func (p *permsReset) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	com, err := permsCommand(msg.GuildID, p.Command)
+	if err != nil {
+		return nil, err
+	}
+	name := com.Aliases()[0]
+
+	err = commands.SetCommandRules(msg.GuildID, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Reset who can use "+utils.Bold(name)), nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestPermsAllowDeny grants and restricts commands with !perms, and resets them
+func TestPermsAllowDeny(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	_, err := run(t, ses, newPermsAllow(), testOther, "Weeb", "nothing")
+	if err != ErrNoCommand {
+		t.Errorf("!perms allow on no command gave %v; want %v", err, ErrNoCommand)
+	}
+	_, err = run(t, ses, newPermsAllow(), testOther, "nobody", "quote", "approve")
+	if err != ErrBadPermTarget {
+		t.Errorf("!perms allow for nobody gave %v; want %v", err, ErrBadPermTarget)
+	}
+	_, err = run(t, ses, newPermsDeny(), testOther, "Mod", "perms", "allow")
+	if err != ErrPermsSelf {
+		t.Errorf("!perms deny on perms allow gave %v; want %v", err, ErrPermsSelf)
+	}
+
+	got, err := run(t, ses, newPermsAllow(), testOther, "weeb", "quote", "approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "Allowed for `Weeb`") {
+		t.Errorf("!perms allow weeb sent %q", got)
+	}
+	_, err = run(t, ses, newPermsDeny(), testOther, "<#"+testChannel+">", "quote", "approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rul, err := commands.GetCommandRules(testGuild, "quote approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rul.AllowRoles) != 1 || rul.AllowRoles[0] != testWeeb {
+		t.Errorf("allowed roles are %q; want [%s]", rul.AllowRoles, testWeeb)
+	}
+	if len(rul.DenyChans) != 1 || rul.DenyChans[0] != testChannel {
+		t.Errorf("denied channels are %q; want [%s]", rul.DenyChans, testChannel)
+	}
+
+	// denying an allowed role moves it
+	_, err = run(t, ses, newPermsDeny(), testOther, "<@&"+testWeeb+">", "quote", "approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rul, _ = commands.GetCommandRules(testGuild, "quote approve")
+	if len(rul.AllowRoles) != 0 || len(rul.DenyRoles) != 1 {
+		t.Errorf("denying an allowed role left allowed %q, denied %q", rul.AllowRoles, rul.DenyRoles)
+	}
+
+	got, err = run(t, ses, newPerms(), testOther)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "quote approve") {
+		t.Errorf("!perms didn't list the changed command, sent %q", got)
+	}
+
+	_, err = run(t, ses, newPermsReset(), testOther, "quote", "approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commands.GetCommandRules(testGuild, "quote approve"); err != commands.ErrNoCommandRules {
+		t.Errorf("!perms reset left rules, GetCommandRules gave %v", err)
+	}
+}
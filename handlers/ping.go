@@ -15,6 +15,6 @@ func (p *ping) Aliases() []string { return []string{"ping", "ping pong"} }
 
 func (p *ping) Desc() string { return "ping!" }
 
-func (p *ping) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (p *ping) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	return commands.NewSimpleSend(msg.ChannelID, "Pong!"), nil
 }
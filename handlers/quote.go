@@ -38,7 +38,9 @@ var (
 
 /* Storer: quotes */
 
-// quotes implements the Storer interface
+// quotes implements the Storer interface, for the pending list
+//
+// Approved quotes were a quotes too before each had its own key, see splitRecords.
 type quotes struct {
 	List []string
 	Last int // THIS FIELD HAS BEEN DEPRECATED, DO NOT RELY ON IT, USE len(List) INSTEAD!
@@ -48,11 +50,53 @@ func (q *quotes) Index() string {
 	return "quotes"
 }
 
+// quoteRecord implements the Storer interface, each approved quote is at commands.RecordKey(guild, keyQuotes, index)
+type quoteRecord struct {
+	Text string
+}
+
+func (q *quoteRecord) Index() string {
+	return "quotes"
+}
+
+var quoteRecords = commands.NewCollection(func() commands.Storer { return &quoteRecord{} })
+
+// quoteKey is where the guild's approved quote at the index is
+func quoteKey(guildID string, index int) string {
+	return commands.RecordKey(guildID, keyQuotes, strconv.Itoa(index))
+}
+
+// quoteIndex is the index of the approved quote at the key
+func quoteIndex(key string) int {
+	ind, _ := strconv.Atoi(key[strings.LastIndex(key, ":")+1:])
+	return ind
+}
+
+// getQuotes gets the guild's approved quotes, with removed ones left empty so the indexes stay the same
+func getQuotes(guildID string) ([]string, error) {
+	list := []string{}
+	err := quoteRecords.Ascend(commands.RecordPrefix(guildID, keyQuotes), func(key string, v commands.Storer) bool {
+		ind := quoteIndex(key)
+		for len(list) <= ind {
+			list = append(list, "")
+		}
+		list[ind] = v.(*quoteRecord).Text
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrQuoteEmpty
+	}
+	return list, nil
+}
+
 /* quote */
 
 type quote struct {
 	nilCommand
-	Index []int `arg:"index"`
+	Index int `arg:"index" default:"-1"`
 }
 
 func newQuote() *quote { return &quote{} }
@@ -74,37 +118,34 @@ func (q *quote) Subcommands() []commands.Command {
 	}
 }
 
-func (q *quote) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (q *quote) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Get quotes
-	var quo quotes
-	err := commands.DBGet(&quotes{}, keyQuotes, &quo)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
+	list, err := getQuotes(msg.GuildID)
+	if err != nil {
 		return nil, err
 	}
 
 	// Check args
-	var ind int
-	if len(q.Index) == 0 {
-		// Gen random number
+	ind := q.Index
+	if ind == -1 {
+		// Gen random number, skipping removed quotes
 		rand.Seed(time.Now().UnixNano())
-		ind = rand.Intn(len(quo.List))
-	} else {
-		ind = q.Index[0]
-		if ind >= len(quo.List) || ind < 0 {
-			return nil, ErrQuoteIndex
+		ind = rand.Intn(len(list))
+		for len(list[ind]) == 0 {
+			ind = rand.Intn(len(list))
 		}
+	} else if ind >= len(list) || ind < 0 || len(list[ind]) == 0 {
+		return nil, ErrQuoteIndex
 	}
 
 	// Get quote and send it
-	noMentions := utils.Unmention(ses, msg, quo.List[ind])
+	noMentions := utils.Unmention(ses, msg, list[ind])
 	return commands.NewSimpleSend(msg.ChannelID, noMentions), nil
 }
 
 type quoteAdd struct {
 	nilCommand
-	New []string `arg:"quote"`
+	New string `arg:"quote" rest:"true" optional:"true"`
 }
 
 func newQuoteAdd() *quoteAdd { return &quoteAdd{} }
@@ -113,36 +154,26 @@ func (q *quoteAdd) Aliases() []string { return []string{"quote add"} }
 
 func (q *quoteAdd) Desc() string { return "Adds a quote to the pending list." }
 
-func (q *quoteAdd) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	// Get the pending quote list from the db
-	var pen quotes
-	err := commands.DBGet(&quotes{}, keyPending, &pen)
-	if err == commands.ErrDBNotFound {
-		// Create a new quote list
-		pen = quotes{
-			List: []string{},
-			Last: -1,
-		}
-	} else if err != nil {
-		return nil, err
-	}
-
+func (q *quoteAdd) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Check quote first
-	newQuote := strings.TrimSpace(strings.Join(q.New, " "))
+	newQuote := strings.TrimSpace(q.New)
 
 	if len(newQuote) == 0 {
 		// Quote is empty, throw error
 		return nil, ErrQuoteNone
 	}
 
-	// Put the new quote into the pending quote list and update Last
-	newQuote = strings.ReplaceAll(strings.Join(q.New, " "), `\n`, "\n")
-
-	pen.List = append(pen.List, newQuote)
-	//pen.Last++
+	// Put the new quote into the pending quote list
+	newQuote = strings.ReplaceAll(newQuote, `\n`, "\n")
 
-	// Set the pending quote list in the db
-	_, _, err = commands.DBSet(&pen, keyPending)
+	pen := quotes{
+		List: []string{},
+		Last: -1,
+	}
+	err := commands.DBUpdate(&pen, commands.GuildKey(msg.GuildID, keyPending), func(v commands.Storer) error {
+		pen.List = append(pen.List, newQuote)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -165,88 +196,57 @@ func (q *quoteApprove) Desc() string { return "Approves a quote." }
 
 func (q *quoteApprove) Roles() []string { return []string{"mod"} }
 
-func (q *quoteApprove) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	// Get pending list
-	var pen quotes
-	err := commands.DBGet(&quotes{}, keyPending, &pen)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
-		return nil, err
-	}
-
-	// Check index
-	if err != nil || q.Index < 0 || q.Index >= len(pen.List) {
-		return nil, ErrQuoteIndex
-	}
-
-	// Get approved list
-	var quo quotes
-	err = commands.DBGet(&quotes{}, keyQuotes, &quo)
-	if err == commands.ErrDBNotFound {
-		quo = quotes{
-			List: []string{},
-			Last: -1,
-		}
-	} else if err != nil {
-		return nil, err
-	}
-
-	// Move pending quote to approved list, filling gaps first
+func (q *quoteApprove) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// Move pending quote to approved list in one go
+	var approved string
 	var ins int
-	func() {
-		if len(quo.List) == 0 {
-			// quote list is empty
-			quo.List = append(quo.List, pen.List[q.Index])
-			ins = len(quo.List) - 1
-			return
+	err := commands.DBTransact(func(tx *commands.DBTx) error {
+		// Get pending list
+		var pen quotes
+		err := tx.Get(&pen, commands.GuildKey(msg.GuildID, keyPending), &pen)
+		if err == commands.ErrDBNotFound {
+			return ErrQuoteEmpty
+		} else if err != nil {
+			return err
 		}
 
-		// quote list is not empty
-		ins = len(quo.List)
+		// Check index
+		if q.Index < 0 || q.Index >= len(pen.List) {
+			return ErrQuoteIndex
+		}
 
-		// find first empty index in the list
-		for i, quote := range quo.List {
-			if len(quote) == 0 {
-				// found index, insert
-				ins = i
-				quo.List[ins] = pen.List[q.Index]
-				return
+		// Find the first gap in the approved list, otherwise the end
+		for ; ; ins++ {
+			err = tx.Get(&quoteRecord{}, quoteKey(msg.GuildID, ins), &quoteRecord{})
+			if err == commands.ErrDBNotFound {
+				break
+			} else if err != nil {
+				return err
 			}
 		}
 
-		// didn't find index, insert at end
-		quo.List = append(quo.List, pen.List[q.Index])
-	}()
-
-	// get all elements before the index
-	newPen := pen.List[:q.Index]
-
-	// not at the end, splice the rest on
-	if q.Index != len(pen.List) {
-		newPen = append(newPen, pen.List[q.Index+1:]...)
-	}
-
-	// set new pending list
-	pen.List = newPen
+		approved = pen.List[q.Index]
+		err = tx.Set(&quoteRecord{approved}, quoteKey(msg.GuildID, ins))
+		if err != nil {
+			return err
+		}
 
-	// Set quotes and pending
-	_, _, err = commands.DBSet(&pen, keyPending)
-	if err != nil {
-		return nil, err
-	}
-	_, _, err = commands.DBSet(&quo, keyQuotes)
+		// Splice the quote out of the pending list
+		pen.List = append(pen.List[:q.Index], pen.List[q.Index+1:]...)
+		return tx.Set(&pen, commands.GuildKey(msg.GuildID, keyPending))
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	out := fmt.Sprintf("Approved quote %s now at index **#%d**", utils.Block(quo.List[ins]), ins)
+	out := fmt.Sprintf("Approved quote %s now at index **#%d**", utils.Block(approved), ins)
 
 	return commands.NewSimpleSend(msg.ChannelID, out), nil
 }
 
 type quoteList struct {
 	nilCommand
+	Index int `arg:"index" optional:"true"`
 }
 
 func newQuoteList() *quoteList { return &quoteList{} }
@@ -254,47 +254,42 @@ func newQuoteList() *quoteList { return &quoteList{} }
 func (q *quoteList) Aliases() []string { return []string{"quote list", "quote ls"} }
 
 func (q *quoteList) Desc() string {
-	return fmt.Sprintf("Lists a range of approved quotes. Specify an index to look around it (defaults to %d).", quoteListLimit/2)
+	return "Lists all approved quotes. Specify an index to start at the page it's on."
 }
 
-func (q *quoteList) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (q *quoteList) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Get all approved quotes from db
-	var quo quotes
-	err := commands.DBGet(&quotes{}, keyQuotes, &quo)
-
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
+	list, err := getQuotes(msg.GuildID)
+	if err != nil {
 		return nil, err
 	}
 
-	// make line list
+	if q.Index < 0 || (q.Index > 0 && q.Index >= len(list)) {
+		return nil, ErrQuoteIndex
+	}
+
+	// make line list, remembering the first quote from the index on
 	title := utils.Under("Quotes of PCSoc:")
 	lines := []string{}
-	for i, quote := range quo.List {
+	first := ""
+	for i, quote := range list {
 		if quote != "" {
-			lines = append(lines, fmt.Sprintf("\n**#%d:** %s", i, utils.Unmention(ses, msg, quote)))
+			line := fmt.Sprintf("\n**#%d:** %s", i, utils.Unmention(ses, msg, quote))
+			if i >= q.Index && first == "" {
+				first = line
+			}
+			lines = append(lines, line)
 		}
 	}
 
-	unregister, needUnregister := InitPaginated(ses, msg, title, lines, quoteListLimit)
-
-	if needUnregister {
-		fmt.Println("needs unregistering")
-		timer := time.NewTimer(2 * time.Minute)
-
-		<-timer.C
-
-		// yeet
-		unregister()
-	}
-
-	return nil, nil
+	pag := commands.NewPaginator(ses, msg.ChannelID).AddLines(title, lines, quoteListLimit)
+	pag.Owner = msg.Author.ID
+	return nil, pag.Start(pag.Find(first))
 }
 
 type quotePending struct {
 	nilCommand
-	Index []int `arg:"index"`
+	Index int `arg:"index" default:"-1"`
 }
 
 func newQuotePending() *quotePending { return &quotePending{} }
@@ -303,10 +298,10 @@ func (q *quotePending) Aliases() []string { return []string{"quote pending", "qu
 
 func (q *quotePending) Desc() string { return "Lists all pending quotes." }
 
-func (q *quotePending) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (q *quotePending) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Get all pending quotes from db
 	var pen quotes
-	err := commands.DBGet(&quotes{}, keyPending, &pen)
+	err := commands.DBGet(&quotes{}, commands.GuildKey(msg.GuildID, keyPending), &pen)
 	if err == commands.ErrDBNotFound {
 		return nil, ErrQuoteEmpty
 	} else if err != nil {
@@ -320,21 +315,19 @@ func (q *quotePending) MsgHandle(ses *discordgo.Session, msg *discordgo.Message)
 
 	// Build output
 	var out string
-	if len(q.Index) == 0 {
+	if q.Index == -1 {
 		// List them
 		out = utils.Under("Pending quotes:") + "\n"
 		for i, q := range pen.List {
 			out += utils.Bold("#"+strconv.Itoa(i)+":") + " " + q + "\n"
 		}
 	} else {
-		ind := q.Index[0]
 		// Check index
-		if ind < 0 || ind >= len(pen.List) {
+		if q.Index < 0 || q.Index >= len(pen.List) {
 			return nil, ErrQuoteIndex
 		}
 
-		// TODO: test
-		out = fmt.Sprintf("Pending quote at index **%d**:\n%s", q.Index[0], pen.List[ind])
+		out = fmt.Sprintf("Pending quote at index **%d**:\n%s", q.Index, pen.List[q.Index])
 	}
 
 	return commands.NewSimpleSend(msg.ChannelID, out), nil
@@ -351,32 +344,24 @@ func (q *quoteReject) Aliases() []string { return []string{"quote reject", "quot
 
 func (q *quoteReject) Desc() string { return "Rejects a quote from the pending list." }
 
-func (q *quoteReject) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	// Get pending list
+func (q *quoteReject) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// Remove from pending list
 	var pen quotes
-	err := commands.DBGet(&quotes{}, keyPending, &pen)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
-		return nil, err
-	}
-
-	// Check index
-	if q.Index < 0 || q.Index >= len(pen.List) {
-		return nil, ErrQuoteIndex
-	}
-
-	// Reorder list
-	rej := pen.List[q.Index]
-	newPen := pen.List[:q.Index]
-	if q.Index != len(pen.List) {
-		newPen = append(newPen, pen.List[q.Index+1:]...)
-	}
-	pen.List = newPen
-	//pen.Last--
+	var rej string
+	err := commands.DBUpdate(&pen, commands.GuildKey(msg.GuildID, keyPending), func(v commands.Storer) error {
+		// Check pending list and index
+		if pen.List == nil {
+			return ErrQuoteEmpty
+		}
+		if q.Index < 0 || q.Index >= len(pen.List) {
+			return ErrQuoteIndex
+		}
 
-	// Set pending
-	_, _, err = commands.DBSet(&pen, keyPending)
+		// Reorder list
+		rej = pen.List[q.Index]
+		pen.List = append(pen.List[:q.Index], pen.List[q.Index+1:]...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -398,27 +383,36 @@ func (q *quoteRemove) Desc() string { return "Removes a quote." }
 
 func (q *quoteRemove) Roles() []string { return []string{"mod"} }
 
-func (q *quoteRemove) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (q *quoteRemove) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Get quotes list
-	var quo quotes
-	err := commands.DBGet(&quotes{}, keyQuotes, &quo)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
+	list, err := getQuotes(msg.GuildID)
+	if err != nil {
 		return nil, err
 	}
 
 	// Check index
-	if q.Index < 0 || q.Index >= len(quo.List) {
+	if q.Index < 0 || q.Index >= len(list) || len(list[q.Index]) == 0 {
 		return nil, ErrQuoteIndex
 	}
 
-	// Clear quote at index, don't reorder
-	rem := quo.List[q.Index]
-	quo.List[q.Index] = ""
+	ok, err := commands.Confirm(ses, msg.ChannelID, msg.Author.ID,
+		"Remove this quote?\n"+utils.Block(list[q.Index]), confirmTimeout)
+	if err != nil || !ok {
+		return nil, errAborted(err)
+	}
 
-	// Set quotes
-	_, _, err = commands.DBSet(&quo, keyQuotes)
+	// Clear quote at index, don't reorder, unless it changed while confirming
+	rem := list[q.Index]
+	err = commands.DBTransact(func(tx *commands.DBTx) error {
+		var now quoteRecord
+		err := tx.Get(&now, quoteKey(msg.GuildID, q.Index), &now)
+		if err == commands.ErrDBNotFound || (err == nil && now.Text != rem) {
+			return ErrQuoteIndex
+		} else if err != nil {
+			return err
+		}
+		return tx.Delete(&now, quoteKey(msg.GuildID, q.Index))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -445,7 +439,7 @@ func (q *quoteSearch) Desc() string {
 	return fmt.Sprintf("Searches for a quote, returns top %d results.", searchLimit)
 }
 
-func (q *quoteSearch) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (q *quoteSearch) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// Join query
 	qry := strings.TrimSpace(strings.Join(q.Query, "[ \\._-]*"))
 
@@ -455,17 +449,14 @@ func (q *quoteSearch) MsgHandle(ses *discordgo.Session, msg *discordgo.Message)
 		return nil, ErrQueryNone
 	}
 
-	// Get quotes list
-	var quo quotes
-	err := commands.DBGet(&quotes{}, keyQuotes, &quo)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
+	// Get quotes list, nothing matches if there aren't any
+	list, err := getQuotes(msg.GuildID)
+	if err != nil && err != ErrQuoteEmpty {
 		return nil, err
 	}
 
 	matches := []*searchMatch{}
-	for i, quote := range quo.List {
+	for i, quote := range list {
 		match, err := regexp.Match("(?i)"+qry, []byte(quote))
 		if err != nil {
 			return nil, err
@@ -505,22 +496,34 @@ func (q *quoteClean) Aliases() []string { return []string{"quote clean", "quote
 
 func (q *quoteClean) Desc() string { return "Replaces `\\n` characters with newlines." }
 
-func (q *quoteClean) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	// Get quotes list
-	var quo quotes
-	err := commands.DBGet(&quotes{}, keyQuotes, &quo)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrQuoteEmpty
-	} else if err != nil {
-		return nil, err
-	}
-
-	for i := 0; i < len(quo.List); i++ {
-		quo.List[i] = strings.ReplaceAll(quo.List[i], `\n`, "\n")
-	}
+func (q *quoteClean) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// Clean quotes list, only setting the quotes that change
+	err := commands.DBTransact(func(tx *commands.DBTx) error {
+		dirty := make(map[string]*quoteRecord)
+		found := false
+		err := tx.Ascend(quoteRecords, commands.RecordPrefix(msg.GuildID, keyQuotes), func(key string, v commands.Storer) bool {
+			found = true
+			quo := v.(*quoteRecord)
+			if strings.Contains(quo.Text, `\n`) {
+				quo.Text = strings.ReplaceAll(quo.Text, `\n`, "\n")
+				dirty[key] = quo
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrQuoteEmpty
+		}
 
-	// Set quotes
-	_, _, err = commands.DBSet(&quo, keyQuotes)
+		for key, quo := range dirty {
+			if err = tx.Set(quo, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
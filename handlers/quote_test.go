@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// TestQuoteFlow adds, approves, gets and removes a quote
+func TestQuoteFlow(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	_, err := run(t, ses, newQuote(), testUser)
+	if err != ErrQuoteEmpty {
+		t.Errorf("!quote with no quotes threw %v; want %v", err, ErrQuoteEmpty)
+	}
+
+	got, err := run(t, ses, newQuoteAdd(), testUser, "hello", "<@!"+testOther+">")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "**#0**") {
+		t.Errorf("!quote add sent %q", got)
+	}
+
+	_, err = run(t, ses, newQuoteApprove(), testOther, "1")
+	if err != ErrQuoteIndex {
+		t.Errorf("!quote approve 1 threw %v; want %v", err, ErrQuoteIndex)
+	}
+
+	got, err = run(t, ses, newQuoteApprove(), testOther, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "**#0**") {
+		t.Errorf("!quote approve sent %q", got)
+	}
+
+	got, err = run(t, ses, newQuotePending(), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Pending list is empty." {
+		t.Errorf("!quote pending sent %q", got)
+	}
+
+	// mentions are replaced with usernames
+	got, err = run(t, ses, newQuote(), testUser, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello alice" {
+		t.Errorf("!quote 0 sent %q; want %q", got, "hello alice")
+	}
+
+	// no index gives a random quote
+	got, err = run(t, ses, newQuote(), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello alice" {
+		t.Errorf("!quote sent %q; want %q", got, "hello alice")
+	}
+
+	_, err = run(t, ses, newQuote(), testUser, "-5")
+	if err != ErrQuoteIndex {
+		t.Errorf("!quote -5 threw %v; want %v", err, ErrQuoteIndex)
+	}
+
+	got, err = run(t, ses, newQuoteSearch(), testUser, "HELLO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "#0:") {
+		t.Errorf("!quote search HELLO sent %q", got)
+	}
+
+	// mods have to confirm removing
+	answer(ses, testOther, commands.PromptDeny)
+	_, err = run(t, ses, newQuoteRemove(), testOther, "0")
+	if err != ErrAborted {
+		t.Errorf("!quote remove denied threw %v; want %v", err, ErrAborted)
+	}
+
+	answer(ses, testOther, commands.PromptConfirm)
+	_, err = run(t, ses, newQuoteRemove(), testOther, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = run(t, ses, newQuoteSearch(), testUser, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "No matches found." {
+		t.Errorf("!quote search after remove sent %q", got)
+	}
+}
+
+// TestQuoteAddRaw keeps quote marks and newlines in added quotes
+func TestQuoteAddRaw(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	in := "\"I love pcsoc\"\n  - bob"
+	com := newQuoteAdd()
+	err := commands.FillTokens(ses, newTestMessage(testUser), com, commands.Tokenize(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = com.MsgHandle(ses, newTestMessage(testUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pen quotes
+	err = commands.DBGet(&quotes{}, commands.GuildKey(testGuild, keyPending), &pen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pen.List) != 1 || pen.List[0] != in {
+		t.Errorf("!quote add %s added %q; want %q", in, pen.List, in)
+	}
+}
+
+// TestMigrateQuotes splits the old approved list into a record per quote, keeping their indexes
+func TestMigrateQuotes(t *testing.T) {
+	clearDB(t)
+	ses := newTestSession()
+
+	quo := quotes{List: []string{"zero", "", "two"}}
+	_, _, err := commands.DBSet(&quo, commands.GuildKey(testGuild, keyQuotes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = commands.DBTransact(migrations[0].Up)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := run(t, ses, newQuote(), testUser, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "two" {
+		t.Errorf("!quote 2 after migrating sent %q; want %q", got, "two")
+	}
+
+	// approving fills the gap
+	_, err = run(t, ses, newQuoteAdd(), testUser, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = run(t, ses, newQuoteApprove(), testOther, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "**#1**") {
+		t.Errorf("!quote approve into a gap sent %q", got)
+	}
+}
@@ -29,10 +29,10 @@ func (r *role) Aliases() []string { return r.names }
 
 func (r *role) Desc() string { return r.desc }
 
-func (r *role) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (r *role) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	var err error
 
-	mem, err := ses.State.Member(msg.GuildID, msg.Author.ID)
+	mem, err := ses.State().Member(msg.GuildID, msg.Author.ID)
 	if err != nil {
 		mem, err = ses.GuildMember(msg.GuildID, msg.Author.ID)
 		if err != nil {
@@ -7,14 +7,6 @@ import (
 	//"github.com/unswpcsoc/pcsocgo/internal/utils"
 )
 
-const (
-	// PCSoc
-	//rulesChannel = "602899198198808606"
-
-	// PCSoc2
-	rulesChannel = "602899198198808606"
-)
-
 type rules struct {
 	nilCommand
 }
@@ -37,7 +29,7 @@ func (r *rules) Roles() []string { return []string{"mod"} }
 
 func (r *rules) Chans() []string { return []string{"mods"} }
 
-func (r *rules) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (r *rules) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	return nil, nil
 }
 
@@ -56,7 +48,7 @@ func (r *rulesGet) Roles() []string { return []string{"mod"} }
 
 func (r *rulesGet) Chans() []string { return []string{"mods"} }
 
-func (r *rulesGet) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (r *rulesGet) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// niceman
 	return nil, nil
 }
@@ -76,7 +68,7 @@ func (r *rulesSet) Roles() []string { return []string{"mod"} }
 
 func (r *rulesSet) Chans() []string { return []string{"mods"} }
 
-func (r *rulesSet) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (r *rulesSet) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// niceman
 	return nil, nil
 }
@@ -23,7 +23,11 @@ func (s *scream) Aliases() []string {
 
 func (s *scream) Desc() string { return "AAAAAAAAAAAAAAAA" }
 
-func (s *scream) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (s *scream) Cooldown() (time.Duration, commands.CooldownScope) {
+	return 5 * time.Second, commands.CooldownChannel
+}
+
+func (s *scream) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// seed randomness every run
 	rand.Seed(time.Now().UnixNano())
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/1lann/staticice"
 	"github.com/bwmarrin/discordgo"
@@ -20,7 +21,7 @@ const (
 
 type staticIce struct {
 	nilCommand
-	Floor int      `arg:"price floor"`
+	Floor int      `arg:"price floor" default:"0"`
 	Query []string `arg:"search term"`
 }
 
@@ -29,10 +30,15 @@ func newStaticIce() *staticIce { return &staticIce{} }
 func (s *staticIce) Aliases() []string { return []string{"staticice", "static ice"} }
 
 func (s *staticIce) Desc() string {
-	return "Searches static ice and returns the top 10 results that are above the price floor"
+	return "Searches static ice and returns the top 10 results that are above the price floor (if given)"
 }
 
-func (s *staticIce) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+// don't hammer staticice
+func (s *staticIce) Cooldown() (time.Duration, commands.CooldownScope) {
+	return 10 * time.Second, commands.CooldownGlobal
+}
+
+func (s *staticIce) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	var err error
 
 	if s.Floor < 0 {
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sahilm/fuzzy"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+	"github.com/unswpcsoc/pcsocgo/internal/utils"
+)
+
+const (
+	// suggestLimit is how many commands are suggested for an unknown one
+	suggestLimit = 3
+	// suggestTypos is how long an alias is per letter of it that can be wrong
+	suggestTypos = 4
+)
+
+// Suggest returns the aliases of the commands that are a typo or two away from the unknown command in argv
+//
+// Each alias is compared to as many words from the start of argv as it has, so args don't count.
+// Aliases are fuzzy found for what was typed with a letter taken out or two swapped, so a changed letter is two typos.
+func Suggest(guildID string, argv []string) []string {
+	type match struct {
+		alias string
+		dist  int
+	}
+	best := make(map[string]match) // indexed by first alias
+	better := func(com commands.Command, ali string, dist int) {
+		if old, ok := best[com.Aliases()[0]]; !ok || dist < old.dist {
+			best[com.Aliases()[0]] = match{ali, dist}
+		}
+	}
+
+	// aliases to fuzzy find, indexed by how many words they have
+	aliases := make(map[int][]string)
+	owners := make(map[string]commands.Command) // indexed by alias
+	for _, com := range append(RouterToSlice(), customCommands(guildID)...) {
+		for _, ali := range com.Aliases() {
+			words := strings.Count(ali, " ") + 1
+			if words > len(argv) {
+				// the start of a longer alias e.g. static for static ice, missing words count as typos
+				if strings.HasPrefix(ali, strings.ToLower(strings.Join(argv, " "))+" ") {
+					better(com, ali, words-len(argv))
+				}
+				continue
+			}
+			aliases[words] = append(aliases[words], ali)
+			owners[ali] = com
+		}
+	}
+
+	for words, alis := range aliases {
+		for _, typ := range typoVariants(strings.ToLower(strings.Join(argv[:words], " "))) {
+			for _, mat := range fuzzy.Find(typ.text, alis) {
+				// letters of the alias that weren't typed are typos too
+				ali := alis[mat.Index]
+				dist := typ.typos + utf8.RuneCountInString(ali) - utf8.RuneCountInString(typ.text)
+				if dist == 0 || dist > utf8.RuneCountInString(ali)/suggestTypos {
+					continue
+				}
+				better(owners[ali], ali, dist)
+			}
+		}
+	}
+
+	matches := []match{}
+	for _, mat := range best {
+		matches = append(matches, mat)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		// more specific first
+		if len(matches[i].alias) != len(matches[j].alias) {
+			return len(matches[i].alias) > len(matches[j].alias)
+		}
+		return matches[i].alias < matches[j].alias
+	})
+
+	out := []string{}
+	for i, mat := range matches {
+		if i == suggestLimit {
+			break
+		}
+		out = append(out, mat.alias)
+	}
+	return out
+}
+
+// typoVariant is what might have been meant by some typed text, and how many typos that takes
+type typoVariant struct {
+	text  string
+	typos int
+}
+
+// typoVariants returns the text, then the text with each letter taken out and each pair of letters swapped
+func typoVariants(text string) []typoVariant {
+	rs := []rune(text)
+	out := []typoVariant{{text, 0}}
+	for i := range rs {
+		if len(rs) > 1 {
+			out = append(out, typoVariant{string(rs[:i]) + string(rs[i+1:]), 1})
+		}
+		if i > 0 && rs[i] != rs[i-1] {
+			swap := append([]rune{}, rs...)
+			swap[i], swap[i-1] = swap[i-1], swap[i]
+			out = append(out, typoVariant{string(swap), 1})
+		}
+	}
+	return out
+}
+
+/* suggest */
+
+type suggest struct {
+	nilCommand
+}
+
+func newSuggest() *suggest { return &suggest{} }
+
+func (s *suggest) Aliases() []string { return []string{"suggest"} }
+
+func (s *suggest) Desc() string {
+	return "Shows whether I suggest commands when you get one wrong in this channel."
+}
+
+func (s *suggest) Subcommands() []commands.Command {
+	return []commands.Command{
+		newSuggestOff(),
+		newSuggestOn(),
+	}
+}
+
+func (s *suggest) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	on, err := commands.SuggestionsOn(msg.GuildID, msg.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if on {
+		return commands.NewSimpleSend(msg.ChannelID, "Suggestions are on in this channel"), nil
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Suggestions are off in this channel"), nil
+}
+
+/* suggest off */
+
+type suggestOff struct {
+	nilCommand
+	Channel *discordgo.Channel `arg:"channel" optional:"true"`
+}
+
+func newSuggestOff() *suggestOff { return &suggestOff{} }
+
+func (s *suggestOff) Aliases() []string { return []string{"suggest off"} }
+
+func (s *suggestOff) Desc() string {
+	return "Stops suggesting commands in a channel e.g. one for another bot, this one if you leave it out."
+}
+
+func (s *suggestOff) Roles() []string { return []string{"mod"} }
+
+func (s *suggestOff) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	return setSuggestions(msg, s.Channel, false)
+}
+
+/* suggest on */
+
+type suggestOn struct {
+	nilCommand
+	Channel *discordgo.Channel `arg:"channel" optional:"true"`
+}
+
+func newSuggestOn() *suggestOn { return &suggestOn{} }
+
+func (s *suggestOn) Aliases() []string { return []string{"suggest on"} }
+
+func (s *suggestOn) Desc() string {
+	return "Suggests commands in a channel again, this one if you leave it out."
+}
+
+func (s *suggestOn) Roles() []string { return []string{"mod"} }
+
+func (s *suggestOn) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	return setSuggestions(msg, s.Channel, true)
+}
+
+// setSuggestions turns suggestions on or off in the channel, or the message's channel if it's nil
+func setSuggestions(msg *discordgo.Message, cha *discordgo.Channel, on bool) (*commands.CommandSend, error) {
+	cid := msg.ChannelID
+	if cha != nil {
+		cid = cha.ID
+	}
+
+	err := commands.SetSuggestions(msg.GuildID, cid, on)
+	if err != nil {
+		return nil, err
+	}
+
+	state := "on"
+	if !on {
+		state = "off"
+	}
+	return commands.NewSimpleSend(msg.ChannelID, "Suggestions are "+utils.Bold(state)+" in <#"+cid+">"), nil
+}
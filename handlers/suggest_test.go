@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSuggest suggests commands for typos and partial commands
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want []string
+	}{
+		{[]string{"qoute", "add", "hello"}, []string{"quote add", "quote"}},
+		{[]string{"qoute", "aprove"}, []string{"quote", "quote approve"}},
+		{[]string{"quote", "aprove"}, []string{"quote approve"}},
+		{[]string{"quote", "approvr"}, []string{"quote approve"}}, // changed letter
+		{[]string{"taggs"}, []string{"tags"}},
+		{[]string{"tags", "ad", "pc"}, []string{"tags add"}},
+		{[]string{"static"}, []string{"static ice"}},
+		{[]string{"zzz"}, []string{}},
+		{[]string{"b"}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := Suggest(testGuild, tt.argv); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q; want %q", tt.argv, got, tt.want)
+		}
+	}
+}
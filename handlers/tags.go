@@ -18,33 +18,22 @@ import (
 )
 
 const (
-	emojiConfirm     = string(0x2705)
-	emojiClean       = string(0x2728)
-	emojiDeny        = string(0x274C)
-	guildMemberLimit = 1000
-	tagsKey          = "fulltags"
-	teal             = 0x008080
-
-	tagLimit  = 64
-	platLimit = 20
-	userLimit = 20 // discord's nick limit is 32
+	emojiClean = string(rune(0x2728))
+	tagsKey    = "fulltags"
+	teal       = 0x008080
 
-	addTimeout = 7
+	userLimit = 20 // discord's nick limit is 32
 
-	// PCSoc
-	cleanChannelID = "213662770724339712"
-	cleanGuildID   = "157263595128881153"
+	tagsListLimit = 15 // tags per page of !tags list
 
-	// PCSoc2
-	//cleanChannelID = "462063414408249376"
-	//cleanGuildID   = "462063414408249374"
+	addTimeout = 7
 )
 
 var (
 	// ErrPlatTooLong means the user tried to create a platform that was too damn long
-	ErrPlatTooLong = errors.New("your platform is too long, keep it under " + strconv.Itoa(platLimit) + " characters")
+	ErrPlatTooLong = errors.New("your platform is too long, keep it under " + strconv.Itoa(conf.PlatformLimit) + " characters")
 	// ErrTagTooLong means the user tried to create a tag that was too damn long
-	ErrTagTooLong = errors.New("your tag is too long, keep it under " + strconv.Itoa(tagLimit) + " characters")
+	ErrTagTooLong = errors.New("your tag is too long, keep it under " + strconv.Itoa(conf.TagLimit) + " characters")
 	// ErrNoTags means there is no tag list
 	ErrNoTags = errors.New("no tags found in database, add a tag to start it")
 	// ErrNoUserTags means there are no tags for the queried user
@@ -53,8 +42,6 @@ var (
 	ErrNoPlatform = errors.New("no platform of that name")
 	// ErrNoUser means the user queried a platform they did not have a tag on
 	ErrNoUser = errors.New("you don't have a tag on this platform")
-	// ErrUserNotFound means the user queried a username that doesn't exist on the server
-	ErrUserNotFound = errors.New("user not found")
 	// ErrAddSpam means the user tried to add while a new platform was being waited on
 	ErrAddSpam = errors.New("please do not try add anything while I'm waiting")
 	// ErrCleanSpam means the user tried to clean while a clean is in progress
@@ -65,6 +52,7 @@ var (
 	cleanSemaphore = semaphore.NewWeighted(1)
 )
 
+// tag implements the Storer interface, each is at commands.RecordKey(guild, platform, uid)
 type tag struct {
 	UID      string
 	Username string // don't trust this, always fetch from the UID
@@ -73,19 +61,121 @@ type tag struct {
 	PingMe   bool
 }
 
+func (t *tag) Index() string { return "tags" }
+
+// platform implements the Storer interface, each is at commands.RecordKey(guild, name)
 type platform struct {
 	Name  string
 	Role  *discordgo.Role
-	Users map[string]*tag // indexed by user id's
+	Users map[string]*tag `json:",omitempty"` // indexed by user id's, only in tagStorer
 }
 
-// TODO: default games and api integrations
+func (p *platform) Index() string { return "platforms" }
+
+// tagStorer is how tags were stored before each had its own key, see splitRecords
 type tagStorer struct {
 	Platforms map[string]*platform
 }
 
 func (t *tagStorer) Index() string { return "tags" }
 
+var (
+	tagRecords      = commands.NewCollection(func() commands.Storer { return &tag{} }, "UID")
+	platformRecords = commands.NewCollection(func() commands.Storer { return &platform{} })
+)
+
+// getPlatform gets the guild's platform, ErrNoPlatform if there isn't one
+func getPlatform(guildID string, name string) (*platform, error) {
+	var plt platform
+	err := commands.DBGet(&plt, commands.RecordKey(guildID, name), &plt)
+	if err == commands.ErrDBNotFound {
+		return nil, ErrNoPlatform
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plt, nil
+}
+
+// platformTags gets the tags on the guild's platform
+func platformTags(guildID string, name string) ([]*tag, error) {
+	utgs := []*tag{}
+	err := tagRecords.Ascend(commands.RecordPrefix(guildID, name), func(key string, v commands.Storer) bool {
+		utgs = append(utgs, v.(*tag))
+		return true
+	})
+	return utgs, err
+}
+
+// userTags gets the user's tags in the guild
+func userTags(guildID string, uid string) ([]*tag, error) {
+	utgs := []*tag{}
+	err := tagRecords.AscendField("UID", uid, commands.RecordPrefix(guildID), func(key string, v commands.Storer) bool {
+		utgs = append(utgs, v.(*tag))
+		return true
+	})
+	return utgs, err
+}
+
+// ImportTags adds tags from elsewhere to the guild, indexed by platform then user id, and makes any new platforms
+//
+// Imported tags don't want pings, like tags from before PingMe, use PingAllTags to opt them in.
+// Returns how many tags were added.
+func ImportTags(guildID string, tgs map[string]map[string]string) (int, error) {
+	n := 0
+	err := commands.DBTransact(func(tx *commands.DBTx) error {
+		n = 0
+		for pname, users := range tgs {
+			plt := &platform{Name: pname}
+			err := tx.Get(plt, commands.RecordKey(guildID, pname), plt)
+			if err == commands.ErrDBNotFound {
+				err = tx.Set(plt, commands.RecordKey(guildID, pname))
+			}
+			if err != nil {
+				return err
+			}
+
+			for uid, text := range users {
+				err = tx.Set(&tag{UID: uid, Tag: text, Platform: pname}, commands.RecordKey(guildID, pname, uid))
+				if err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// PingAllTags turns PingMe on for every tag in the guild, even those that turned it off, and returns how many there are
+func PingAllTags(guildID string) (int, error) {
+	n := 0
+	err := commands.DBTransact(func(tx *commands.DBTx) error {
+		utgs := map[string]*tag{}
+		err := tx.Ascend(tagRecords, commands.RecordPrefix(guildID), func(key string, v commands.Storer) bool {
+			// skips the guild's tags from before records, if they haven't been split yet
+			if utg := v.(*tag); len(utg.UID) > 0 {
+				utgs[key] = utg
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, utg := range utgs {
+			utg.PingMe = true
+			if err = tx.Set(utg, key); err != nil {
+				return err
+			}
+		}
+		n = len(utgs)
+		return nil
+	})
+	return n, err
+}
+
 type tags struct {
 	nilCommand
 	Platform string `arg:"platform"`
@@ -113,37 +203,35 @@ func (t *tags) Subcommands() []commands.Command {
 	}
 }
 
-func (t *tags) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+func (t *tags) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	// attempt to lookup platform first before routing to help message
-	var err error
-	var tgs tagStorer
-
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
+	_, err := getPlatform(msg.GuildID, t.Platform)
+	if err == ErrNoPlatform {
+		return commands.NewSimpleSend(msg.ChannelID, commands.GetUsagePrefix(t, commands.GuildPrefix(msg.GuildID))), nil
 	} else if err != nil {
 		return nil, err
 	}
 
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
-		return commands.NewSimpleSend(msg.ChannelID, commands.GetUsage(t)), nil
+	ptgs, err := platformTags(msg.GuildID, t.Platform)
+	if err != nil {
+		return nil, err
 	}
 
-	list := fmt.Sprintf(fmt.Sprintf("Ping? | %%-%ds | %%s\n", platLimit), "User", "Tag")
-	for i := range list {
+	platLimit := cfg().PlatformLimit
+	header := fmt.Sprintf(fmt.Sprintf("Ping? | %%-%ds | %%s\n", platLimit), "User", "Tag")
+	for i := range header {
 		if i == 6 || i == platLimit+9 {
-			list += "+"
+			header += "+"
 		} else {
-			list += "-"
+			header += "-"
 		}
 	}
-	list += "\n"
+	header += "\n"
 
 	// update usernames
 	utags := []*tag{}
-	for _, utg := range plt.Users {
-		mem, err := ses.State.Member(msg.GuildID, utg.UID)
+	for _, utg := range ptgs {
+		mem, err := ses.State().Member(msg.GuildID, utg.UID)
 		if err != nil {
 			// try use session instead
 			mem, err = ses.GuildMember(msg.GuildID, utg.UID)
@@ -162,6 +250,9 @@ func (t *tags) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comma
 		if utags[i] == nil {
 			return false
 		}
+		if utags[j] == nil {
+			return true
+		}
 
 		if strings.Compare(utags[i].Username, utags[j].Username) < 0 {
 			return true
@@ -169,8 +260,11 @@ func (t *tags) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comma
 		return false
 	})
 
-	// generate output
-	for _, utg := range utags {
+	// generate output, a page at a time
+	pag := commands.NewPaginator(ses, msg.ChannelID)
+	pag.Owner = msg.Author.ID
+	list := header
+	for i, utg := range utags {
 		if utg == nil {
 			// signal invalid users in the db
 			list += fmt.Sprintf(fmt.Sprintf("%%-%dt | %%-%ds | %%s\n", 5, userLimit),
@@ -183,15 +277,20 @@ func (t *tags) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comma
 			list += fmt.Sprintf(fmt.Sprintf("%%-%dt | %%-%ds | %%s\n", 5, userLimit),
 				utg.PingMe, utg.Username[0:ind], utg.Tag)
 		}
+
+		if (i+1)%tagsListLimit == 0 || i+1 == len(utags) {
+			pag.AddPage(t.Platform + "'s tags:\n" + utils.Block(list))
+			list = header
+		}
 	}
 
-	return commands.NewSimpleSend(msg.ChannelID, t.Platform+"'s tags:\n"+utils.Block(list)), nil
+	return nil, pag.Start(0)
 }
 
 type tagsAdd struct {
 	nilCommand
-	Platform string   `arg:"platform"`
-	Tag      []string `arg:"tag"`
+	Platform string `arg:"platform"`
+	Tag      string `arg:"tag" rest:"true" optional:"true"`
 }
 
 func newTagsAdd() *tagsAdd { return &tagsAdd{} }
@@ -200,20 +299,18 @@ func (t *tagsAdd) Aliases() []string { return []string{"tags add", "tags edit"}
 
 func (t *tagsAdd) Desc() string { return "Adds your tag to a platform" }
 
-func (t *tagsAdd) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
+func (t *tagsAdd) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	var out = commands.NewSend(msg.ChannelID)
 
 	if len(t.Tag) == 0 {
 		return nil, errors.New("please provide a tag")
 	}
-	argTag := strings.Join(t.Tag, " ")
-	if len(argTag) > tagLimit {
+	argTag := t.Tag
+	if len(argTag) > cfg().TagLimit {
 		return nil, ErrTagTooLong
 	}
 
-	if len(t.Platform) > platLimit {
+	if len(t.Platform) > cfg().PlatformLimit {
 		return nil, ErrPlatTooLong
 	}
 
@@ -223,108 +320,48 @@ func (t *tagsAdd) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*co
 	}
 	defer addSemaphore.Release(1)
 
-	// lock the db
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		tgs = tagStorer{make(map[string]*platform)}
-	} else if err != nil {
-		return nil, err
-	}
-
 	// get platform
-	//var drl *discordgo.Role
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
+	_, err := getPlatform(msg.GuildID, t.Platform)
+	create := err == ErrNoPlatform
+	if create {
 		// wait for user reaction to verify
-		war, _ := ses.ChannelMessageSend(msg.ChannelID,
+		ok, err := commands.Confirm(ses, msg.ChannelID, msg.Author.ID,
 			fmt.Sprintf("Creating new platform **%s**.\n__Please check if a similar one exists.__\n"+
-				"Confirm adding in %d seconds.", t.Platform, addTimeout))
-
-		// react to the message to get things going
-		err = ses.MessageReactionAdd(war.ChannelID, war.ID, emojiConfirm)
-		if err != nil {
-			return nil, err
-		}
-
-		err = ses.MessageReactionAdd(war.ChannelID, war.ID, emojiDeny)
-		if err != nil {
-			return nil, err
-		}
-
-		// remove all reactions when done
-		defer func() {
-			ses.MessageReactionsRemoveAll(war.ChannelID, war.ID)
-		}()
-
-		// spin up goroutine to check if message has been reacted
-		reaction := make(chan bool)
-		go func() {
-			reacted := make(chan int)
-			kill := ses.AddHandler(func(se *discordgo.Session, no *discordgo.MessageReactionAdd) {
-				// demo sonnanja dame
-				// mou sonnanja hora
-				// KOKORO WA SHINKA SURU YO
-				// MOTTO
-				// MO-O-TTO
-
-				// make sure reaction is on the correct message by the correct user
-				if no.MessageReaction.MessageID != war.ID || no.MessageReaction.UserID != msg.Author.ID {
-					return
-				}
-
-				// signal that we have achieved nirvana
-				switch no.MessageReaction.Emoji.Name {
-				case emojiConfirm:
-					reaction <- true
-					reacted <- 0
-				case emojiDeny:
-					reaction <- false
-					reacted <- 0
-				}
-			})
-
-			select {
-			case <-reacted:
-			case <-time.After(addTimeout * time.Second):
-				reaction <- false
-			}
-
-			kill()
-		}()
-
-		// check what we got
-		if !<-reaction {
+				"Confirm adding in %d seconds.", t.Platform, addTimeout), addTimeout*time.Second)
+		if err == commands.ErrPromptTimeout || err == commands.ErrPromptCancelled || !ok {
 			out.Message("Aborting platform creation.")
 			return out, nil
+		} else if err != nil {
+			return nil, err
 		}
 
 		// acknowledge reaction
 		ses.ChannelMessageSend(msg.ChannelID, "Creating new platform: "+utils.Code(t.Platform))
-
-		// create new platform
-		plt = &platform{
-			Name:  t.Platform,
-			Role:  nil,
-			Users: make(map[string]*tag),
-		}
-		tgs.Platforms[t.Platform] = plt
+	} else if err != nil {
+		return nil, err
 	}
 
-	// add tag to platform
-	plt.Users[msg.Author.ID] = &tag{
-		UID:      msg.Author.ID,
-		Username: msg.Author.Username,
-		Tag:      argTag,
-		Platform: t.Platform,
-		PingMe:   true, // opt-out
-	}
+	// add tag to platform, creating it unless someone beat us to it
+	err = commands.DBTransact(func(tx *commands.DBTx) error {
+		if create {
+			plt := &platform{Name: t.Platform}
+			err := tx.Get(plt, commands.RecordKey(msg.GuildID, t.Platform), plt)
+			if err == commands.ErrDBNotFound {
+				err = tx.Set(plt, commands.RecordKey(msg.GuildID, t.Platform))
+			}
+			if err != nil {
+				return err
+			}
+		}
 
-	// set tags
-	_, _, err = commands.DBSet(&tgs, tagsKey)
+		return tx.Set(&tag{
+			UID:      msg.Author.ID,
+			Username: msg.Author.Username,
+			Tag:      argTag,
+			Platform: t.Platform,
+			PingMe:   true, // opt-out
+		}, commands.RecordKey(msg.GuildID, t.Platform, msg.Author.ID))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -350,9 +387,15 @@ func (t *tagsClean) Desc() string {
 
 func (t *tagsClean) Roles() []string { return []string{"mod"} }
 
-func (t *tagsClean) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
+func (t *tagsClean) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// the daemon doesn't need to ask
+	if msg.Author != nil {
+		ok, err := commands.Confirm(ses, msg.ChannelID, msg.Author.ID,
+			"This will remove invalid tags and empty platforms. Are you sure?", confirmTimeout)
+		if err != nil || !ok {
+			return nil, errAborted(err)
+		}
+	}
 
 	// check if we're cleaning
 	if !cleanSemaphore.TryAcquire(1) {
@@ -360,67 +403,104 @@ func (t *tagsClean) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*
 	}
 	defer cleanSemaphore.Release(1)
 
-	// lock the db
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
+	// get all platforms and tags
+	plts := make(map[string]*platform) // indexed by key
+	err := platformRecords.Ascend(commands.RecordPrefix(msg.GuildID), func(key string, v commands.Storer) bool {
+		plts[key] = v.(*platform)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(plts) == 0 {
 		return nil, ErrNoTags
-	} else if err != nil {
+	}
+
+	utgs := make(map[string]*tag) // indexed by key
+	err = tagRecords.Ascend(commands.RecordPrefix(msg.GuildID), func(key string, v commands.Storer) bool {
+		utgs[key] = v.(*tag)
+		return true
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// cache already seen uids
 	checkMap := make(map[string]bool)
 
-	// iterate platforms
-	for pname, plt := range tgs.Platforms {
-		// clean empty platforms
-		if len(plt.Users) == 0 || len(plt.Name) == 0 {
-			// remove the platform
-			delete(tgs.Platforms, pname)
-			logs.Println("Removed empty platform: " + utils.Code(pname))
+	// check valid users, nil tags are removed
+	changed := make(map[string]*tag)
+	for key, utg := range utgs {
+		res, ok := checkMap[utg.UID]
+		if ok {
+			if !res {
+				// has been checked and is invalid, remove
+				changed[key] = nil
+				logs.Println("Removed invalid user: " + utg.UID)
+			}
 			continue
 		}
 
-		// check valid users
-		for uid, _ := range plt.Users {
-			res, ok := checkMap[uid]
-			if ok {
-				if !res {
-					// has been checked and is invalid, remove
-					delete(plt.Users, uid)
-					logs.Println("Removed invalid user: " + uid)
-				}
+		// check user
+		mem, err := ses.State().Member(msg.GuildID, utg.UID)
+		if err != nil {
+			mem, err = ses.GuildMember(msg.GuildID, utg.UID)
+			if err != nil {
+				// couldn't find user, remove tag from db
+				changed[key] = nil
+				logs.Println("Removed invalid user: " + utg.UID)
+
+				// update cache
+				checkMap[utg.UID] = false
 				continue
 			}
+		}
 
-			// check user
-			mem, err := ses.State.Member(msg.GuildID, uid)
-			if err != nil {
-				mem, err = ses.GuildMember(msg.GuildID, uid)
-				if err != nil {
-					// couldn't find user, remove tag from db
-					delete(plt.Users, uid)
-					logs.Println("Removed invalid user: " + uid)
+		// update username
+		if utg.Username != mem.User.Username {
+			utg.Username = mem.User.Username
+			changed[key] = utg
+		}
 
-					// update cache
-					checkMap[uid] = false
-					continue
-				}
+		// update cache
+		checkMap[utg.UID] = true
+	}
+
+	err = commands.DBTransact(func(tx *commands.DBTx) error {
+		for key, utg := range changed {
+			var err error
+			if utg == nil {
+				err = tx.Delete(&tag{}, key)
+			} else {
+				err = tx.Set(utg, key)
 			}
+			if err != nil && err != commands.ErrDBNotFound {
+				return err
+			}
+		}
 
-			// update username
-			plt.Users[uid].Username = mem.User.Username
+		// clean empty platforms, counting what's left in them now
+		for key, plt := range plts {
+			empty := true
+			err := tx.Ascend(tagRecords, key+":", func(string, commands.Storer) bool {
+				empty = false
+				return false
+			})
+			if err != nil {
+				return err
+			}
 
-			// update cache
-			checkMap[uid] = true
+			if empty || len(plt.Name) == 0 {
+				// remove the platform
+				err = tx.Delete(plt, key)
+				if err != nil && err != commands.ErrDBNotFound {
+					return err
+				}
+				logs.Println("Removed empty platform: " + utils.Code(plt.Name))
+			}
 		}
-	}
-
-	_, _, err = commands.DBSet(&tgs, tagsKey)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -439,25 +519,18 @@ func (t *tagsGet) Aliases() []string { return []string{"tags get"} }
 
 func (t *tagsGet) Desc() string { return "Gets your tag for a platform." }
 
-func (t *tagsGet) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
+func (t *tagsGet) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	_, err := getPlatform(msg.GuildID, t.Platform)
+	if err != nil {
 		return nil, err
 	}
 
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
-		return nil, ErrNoPlatform
-	}
-
-	utg, ok := plt.Users[msg.Author.ID]
-	if !ok {
+	var utg tag
+	err = commands.DBGet(&utg, commands.RecordKey(msg.GuildID, t.Platform, msg.Author.ID), &utg)
+	if err == commands.ErrDBNotFound {
 		return nil, ErrNoUser
+	} else if err != nil {
+		return nil, err
 	}
 
 	return commands.NewSimpleSend(msg.ChannelID, "Your tag is "+utils.Code(utg.Tag)+" for platform "+utils.Code(utg.Platform)), nil
@@ -474,36 +547,32 @@ func (t *tagsList) Aliases() []string { return []string{"tags list", "tags ls"}
 
 func (t *tagsList) Desc() string { return "Lists all tags for that platform." }
 
-func (t *tagsList) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
+func (t *tagsList) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	_, err := getPlatform(msg.GuildID, t.Platform)
+	if err != nil {
 		return nil, err
 	}
 
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
-		return nil, ErrNoPlatform
+	ptgs, err := platformTags(msg.GuildID, t.Platform)
+	if err != nil {
+		return nil, err
 	}
 
-	list := fmt.Sprintf(fmt.Sprintf("Ping? | %%-%ds | %%s\n", platLimit), "User", "Tag")
-	for i := range list {
+	platLimit := cfg().PlatformLimit
+	header := fmt.Sprintf(fmt.Sprintf("Ping? | %%-%ds | %%s\n", platLimit), "User", "Tag")
+	for i := range header {
 		if i == 6 || i == platLimit+9 {
-			list += "+"
+			header += "+"
 		} else {
-			list += "-"
+			header += "-"
 		}
 	}
-	list += "\n"
+	header += "\n"
 
 	// update usernames
 	utags := []*tag{}
-	for _, utg := range plt.Users {
-		mem, err := ses.State.Member(msg.GuildID, utg.UID)
+	for _, utg := range ptgs {
+		mem, err := ses.State().Member(msg.GuildID, utg.UID)
 		if err != nil {
 			// try use session instead
 			mem, err = ses.GuildMember(msg.GuildID, utg.UID)
@@ -522,6 +591,9 @@ func (t *tagsList) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*c
 		if utags[i] == nil {
 			return false
 		}
+		if utags[j] == nil {
+			return true
+		}
 
 		if strings.Compare(utags[i].Username, utags[j].Username) < 0 {
 			return true
@@ -529,8 +601,11 @@ func (t *tagsList) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*c
 		return false
 	})
 
-	// generate output
-	for _, utg := range utags {
+	// generate output, a page at a time
+	pag := commands.NewPaginator(ses, msg.ChannelID)
+	pag.Owner = msg.Author.ID
+	list := header
+	for i, utg := range utags {
 		if utg == nil {
 			// signal invalid users in the db
 			list += fmt.Sprintf(fmt.Sprintf("%%-%dt | %%-%ds | %%s\n", 5, userLimit),
@@ -543,9 +618,14 @@ func (t *tagsList) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*c
 			list += fmt.Sprintf(fmt.Sprintf("%%-%dt | %%-%ds | %%s\n", 5, userLimit),
 				utg.PingMe, utg.Username[0:ind], utg.Tag)
 		}
+
+		if (i+1)%tagsListLimit == 0 || i+1 == len(utags) {
+			pag.AddPage(t.Platform + "'s tags:\n" + utils.Block(list))
+			list = header
+		}
 	}
 
-	return commands.NewSimpleSend(msg.ChannelID, t.Platform+"'s tags:\n"+utils.Block(list)), nil
+	return nil, pag.Start(0)
 }
 
 type tagsPlatforms struct {
@@ -558,22 +638,28 @@ func (t *tagsPlatforms) Aliases() []string { return []string{"tags platforms"} }
 
 func (t *tagsPlatforms) Desc() string { return "Lists all platforms." }
 
-func (t *tagsPlatforms) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
+func (t *tagsPlatforms) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// get all platforms
+	plats := []*platform{}
+	err := platformRecords.Ascend(commands.RecordPrefix(msg.GuildID), func(key string, v commands.Storer) bool {
+		plats = append(plats, v.(*platform))
+		return true
+	})
+	if err != nil {
 		return nil, err
 	}
+	if len(plats) == 0 {
+		return nil, ErrNoTags
+	}
 
-	// iterate platforms
-	plats := []*platform{}
-	for _, plt := range tgs.Platforms {
-		plats = append(plats, plt)
+	// count tags
+	counts := make(map[string]int)
+	err = tagRecords.Ascend(commands.RecordPrefix(msg.GuildID), func(key string, v commands.Storer) bool {
+		counts[v.(*tag).Platform]++
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// sort platforms
@@ -586,9 +672,10 @@ func (t *tagsPlatforms) MsgHandle(ses *discordgo.Session, msg *discordgo.Message
 
 	// create message
 	list := ""
+	platLimit := cfg().PlatformLimit
 	for _, plt := range plats {
 		list += fmt.Sprintf(fmt.Sprintf("%%-%ds", platLimit), plt.Name)
-		list += "|  " + strconv.Itoa(len(plt.Users)) + " tag(s)\n"
+		list += "|  " + strconv.Itoa(counts[plt.Name]) + " tag(s)\n"
 	}
 
 	out := "Platforms:\n" + utils.Block(list)
@@ -597,8 +684,8 @@ func (t *tagsPlatforms) MsgHandle(ses *discordgo.Session, msg *discordgo.Message
 
 type tagsPing struct {
 	nilCommand
-	Platform string   `arg:"platform"`
-	Message  []string `arg:"message"`
+	Platform string `arg:"platform"`
+	Message  string `arg:"message" rest:"true" optional:"true"`
 }
 
 func newTagsPing() *tagsPing { return &tagsPing{} }
@@ -609,25 +696,25 @@ func (t *tagsPing) Desc() string {
 	return "Pings all users with `PingMe` set on the platform. Can also add your own message."
 }
 
-func (t *tagsPing) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
+func (t *tagsPing) Cooldown() (time.Duration, commands.CooldownScope) {
+	return 30 * time.Second, commands.CooldownUser
+}
+
+func (t *tagsPing) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	out := commands.NewSend(msg.ChannelID)
 
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
+	plt, err := getPlatform(msg.GuildID, t.Platform)
+	if err != nil {
 		return nil, err
 	}
 
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
-		return nil, ErrNoPlatform
+	ptgs, err := platformTags(msg.GuildID, t.Platform)
+	if err != nil {
+		return nil, err
 	}
 
 	pings := ""
-	for _, utg := range plt.Users {
+	for _, utg := range ptgs {
 		if utg.PingMe {
 			pings += " " + utils.Mention(utg.UID)
 		}
@@ -637,7 +724,7 @@ func (t *tagsPing) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*c
 		return out.Message("No one wants " + utils.Code(plt.Name) + " pings."), nil
 	}
 
-	pings = utils.Bold(plt.Name) + pings + "\n" + strings.Join(t.Message, " ")
+	pings = utils.Bold(plt.Name) + pings + "\n" + t.Message
 
 	return out.Message(pings), nil
 }
@@ -652,34 +739,28 @@ func (t *tagsShutup) Aliases() []string { return []string{"tags shutup", "shutup
 
 func (t *tagsShutup) Desc() string { return "Stop pings from tags" }
 
-func (t *tagsShutup) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-
-	// lock the db
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
-		return nil, err
-	}
-
-	// iterate platforms
-	for _, plt := range tgs.Platforms {
-		tag, ok := plt.Users[msg.Author.ID]
-		if !ok {
-			continue
+func (t *tagsShutup) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	err := commands.DBTransact(func(tx *commands.DBTx) error {
+		// get user's tags
+		utgs := make(map[string]*tag)
+		err := tx.AscendField(tagRecords, "UID", msg.Author.ID, commands.RecordPrefix(msg.GuildID),
+			func(key string, v commands.Storer) bool {
+				utgs[key] = v.(*tag)
+				return true
+			})
+		if err != nil {
+			return err
 		}
-		// :unping:
-		tag.PingMe = false
-		plt.Users[msg.Author.ID] = tag
-	}
 
-	_, _, err = commands.DBSet(&tgs, tagsKey)
+		// :unping:
+		for key, utg := range utgs {
+			utg.PingMe = false
+			if err = tx.Set(utg, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -699,38 +780,21 @@ func (t *tagsPingMe) Aliases() []string { return []string{"tags pingme", "askme"
 
 func (t *tagsPingMe) Desc() string { return "Set your ping status for a given platform" }
 
-func (t *tagsPingMe) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-
-	// lock the db
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	// get all tags
-	err = commands.DBGet(&tagStorer{}, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
+func (t *tagsPingMe) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	_, err := getPlatform(msg.GuildID, t.Platform)
+	if err != nil {
 		return nil, err
 	}
 
-	// get platform
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
-		return nil, ErrNoPlatform
-	}
-
-	// get tag
-	utg, ok := plt.Users[msg.Author.ID]
-	if !ok {
-		return nil, ErrNoUser
-	}
-
 	// set pingme
-	utg.PingMe = t.PingMe
-
-	_, _, err = commands.DBSet(&tgs, tagsKey)
+	err = tagRecords.Update(commands.RecordKey(msg.GuildID, t.Platform, msg.Author.ID), func(v commands.Storer) error {
+		utg := v.(*tag)
+		if len(utg.UID) == 0 {
+			return ErrNoUser
+		}
+		utg.PingMe = t.PingMe
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -754,59 +818,58 @@ func (t *tagsRemove) Aliases() []string { return []string{"tags remove", "tags r
 
 func (t *tagsRemove) Desc() string { return "Removes your tag from a platform" }
 
-func (t *tagsRemove) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
+func (t *tagsRemove) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
 	var out = commands.NewSend(msg.ChannelID)
 
-	// lock the db
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
-		return nil, err
-	}
+	var plt platform
+	empty := true
+	err := commands.DBTransact(func(tx *commands.DBTx) error {
+		// get platform
+		err := tx.Get(&plt, commands.RecordKey(msg.GuildID, t.Platform), &plt)
+		if err == commands.ErrDBNotFound {
+			return ErrNoPlatform
+		} else if err != nil {
+			return err
+		}
 
-	// get platform
-	plt, ok := tgs.Platforms[t.Platform]
-	if !ok {
-		return nil, ErrNoPlatform
-	}
+		// remove the tag
+		err = tx.Delete(&tag{}, commands.RecordKey(msg.GuildID, t.Platform, msg.Author.ID))
+		if err == commands.ErrDBNotFound {
+			return ErrNoUser
+		} else if err != nil {
+			return err
+		}
 
-	// get tag
-	_, ok = plt.Users[msg.Author.ID]
-	if !ok {
-		return nil, ErrNoUser
+		// remove the platform if that was the last tag
+		err = tx.Ascend(tagRecords, commands.RecordPrefix(msg.GuildID, t.Platform), func(string, commands.Storer) bool {
+			empty = false
+			return false
+		})
+		if err != nil || !empty {
+			return err
+		}
+		return tx.Delete(&plt, commands.RecordKey(msg.GuildID, t.Platform))
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// remove the tag
-	delete(plt.Users, msg.Author.ID)
 	out.Message("Removed your tag from " + utils.Code(t.Platform))
 
-	if len(plt.Users) == 0 {
+	if empty {
 		// remove the role from guild, silently fails
-		ses.GuildRoleDelete(msg.GuildID, plt.Role.ID)
-
-		// remove the platform
-		delete(tgs.Platforms, t.Platform)
+		if plt.Role != nil {
+			ses.GuildRoleDelete(msg.GuildID, plt.Role.ID)
+		}
 		out.Message("Removing empty platform: " + utils.Code(t.Platform))
 	}
 
-	_, _, err = commands.DBSet(&tgs, tagsKey)
-	if err != nil {
-		return nil, err
-	}
-
 	return out, nil
 }
 
 type tagsUser struct {
 	nilCommand
-	User []string `arg:"username"`
+	User *discordgo.User `arg:"user" optional:"true"`
 }
 
 func newTagsUser() *tagsUser { return &tagsUser{} }
@@ -814,64 +877,21 @@ func newTagsUser() *tagsUser { return &tagsUser{} }
 func (t *tagsUser) Aliases() []string { return []string{"tags user", "tags view"} }
 
 func (t *tagsUser) Desc() string {
-	return "Lists all tags of a user. Use a @ping or a case-insensitive username (not nickname) search." +
+	return "Lists all tags of a user. Use a @ping or a case-insensitive username or nickname search." +
 		" Empty username will get your own tags."
 }
 
-func (t *tagsUser) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-	var usr *discordgo.User
-
-	if len(t.User) == 0 {
-		// get self
-		usr = msg.Author
-	} else {
-		if len(msg.Mentions) > 0 {
-			// use mention to get user
-			usr = msg.Mentions[0]
-		} else {
-			// try as uid
-			mem, err := ses.GuildMember(msg.GuildID, strings.TrimSpace(t.User[0]))
-			if err != nil {
-				// use string to search for user
-				members, err := ses.GuildMembers(msg.GuildID, "0", guildMemberLimit)
-				if err != nil {
-					return nil, err
-				}
-
-				for _, mem := range members {
-					// case-insensitive
-					if strings.ToLower(mem.User.Username) == strings.ToLower(t.User[0]) {
-						usr = mem.User
-					}
-				}
-
-				if usr == nil {
-					return nil, ErrUserNotFound
-				}
-			} else {
-				usr = mem.User
-			}
-		}
-	}
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
-		return nil, err
+func (t *tagsUser) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	// get self if no user given
+	usr := msg.Author
+	if t.User != nil {
+		usr = t.User
 	}
 
 	// collect user's tags
-	var utgs []*tag
-	for _, plt := range tgs.Platforms {
-		utg, ok := plt.Users[usr.ID]
-		if !ok {
-			continue
-		}
-		utgs = append(utgs, utg)
+	utgs, err := userTags(msg.GuildID, usr.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(utgs) == 0 {
@@ -880,12 +900,13 @@ func (t *tagsUser) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*c
 
 	// sort tags
 	sort.Slice(utgs, func(i, j int) bool {
-		if strings.Compare(utgs[i].Username, utgs[j].Username) < 0 {
+		if strings.Compare(utgs[i].Platform, utgs[j].Platform) < 0 {
 			return true
 		}
 		return false
 	})
 
+	platLimit := cfg().PlatformLimit
 	list := fmt.Sprintf(fmt.Sprintf("Ping? | %%-%ds | %%s\n", platLimit), "Platform", "Tag")
 	for i := range list {
 		if i == 6 || i == platLimit+9 {
@@ -919,41 +940,33 @@ func (t *tagsModRemove) Desc() string { return "Moderator tool to forcibly remov
 
 func (t *tagsModRemove) Roles() []string { return []string{"mod"} }
 
-func (t *tagsModRemove) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
-	var err error
-	var tgs tagStorer
-
-	// lock the db
-	commands.DBLock()
-	defer commands.DBUnlock()
-
-	// get all tags
-	err = commands.DBGet(&tgs, tagsKey, &tgs)
-	if err == commands.ErrDBNotFound {
-		return nil, ErrNoTags
-	} else if err != nil {
-		return nil, err
+func (t *tagsModRemove) MsgHandle(ses commands.Session, msg *discordgo.Message) (*commands.CommandSend, error) {
+	ok, err := commands.Confirm(ses, msg.ChannelID, msg.Author.ID,
+		"Remove platform "+utils.Code(t.Platform)+" and all of its tags?", confirmTimeout)
+	if err != nil || !ok {
+		return nil, errAborted(err)
 	}
 
-	// iterate platforms
-	for pname, _ := range tgs.Platforms {
-		if pname == t.Platform {
-			// remove the platform
-			delete(tgs.Platforms, pname)
-
-			// commit changes
-			_, _, err = commands.DBSet(&tgs, tagsKey)
-			if err != nil {
-				return nil, err
-			}
-			return commands.NewSimpleSend(msg.ChannelID, "Removed platform: "+utils.Code(pname)), nil
+	// remove the platform and all of its tags
+	err = commands.DBTransact(func(tx *commands.DBTx) error {
+		err := tx.Delete(&platform{}, commands.RecordKey(msg.GuildID, t.Platform))
+		if err == commands.ErrDBNotFound {
+			return ErrNoPlatform
+		} else if err != nil {
+			return err
 		}
+
+		_, err = tx.DeletePrefix(tagRecords, commands.RecordPrefix(msg.GuildID, t.Platform))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, ErrNoPlatform
+	return commands.NewSimpleSend(msg.ChannelID, "Removed platform: "+utils.Code(t.Platform)), nil
 }
 
-func initClean(ses *discordgo.Session) chan bool {
+func initClean(ses commands.Session) chan bool {
 	// check at 2am
 	logs.Println("Initialised clean")
 
@@ -963,16 +976,22 @@ func initClean(ses *discordgo.Session) chan bool {
 	doClean := func() {
 		// AEST = GMT+11
 		if time.Now().Hour() == 2+11 {
-			// call handler
-			logs.Println("Calling tagsClean handler")
-			cmd := &tagsClean{}
-			_, err := cmd.MsgHandle(ses, &discordgo.Message{
-				ChannelID: cleanChannelID,
-				GuildID:   cleanGuildID,
-			})
-			if err != nil {
-				logs.Println("doClean:", err)
-				return
+			// call handler in every guild that has a clean channel
+			for _, guild := range commands.Guilds() {
+				cha := guildChannel(guild.ID, channelClean)
+				if len(cha) == 0 {
+					continue
+				}
+
+				logs.Println("Calling tagsClean handler in guild", guild.ID)
+				cmd := &tagsClean{}
+				_, err := cmd.MsgHandle(ses, &discordgo.Message{
+					ChannelID: cha,
+					GuildID:   guild.ID,
+				})
+				if err != nil {
+					logs.Println("doClean:", err)
+				}
 			}
 		}
 	}
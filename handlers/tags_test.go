@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unswpcsoc/pcsocgo/commands"
+)
+
+// seedTags puts a platform with a tag for testUser in the db
+func seedTags(t *testing.T) {
+	clearDB(t)
+	_, _, err := commands.DBSet(&platform{Name: "pc"}, commands.RecordKey(testGuild, "pc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utg := tag{UID: testUser, Username: "bob", Tag: "bobby", Platform: "pc", PingMe: true}
+	_, _, err = commands.DBSet(&utg, commands.RecordKey(testGuild, "pc", testUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getTag gets uid's tag on the platform, nil if there isn't one
+func getTag(t *testing.T, plat string, uid string) *tag {
+	var utg tag
+	err := commands.DBGet(&utg, commands.RecordKey(testGuild, plat, uid), &utg)
+	if err == commands.ErrDBNotFound {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &utg
+}
+
+// hasPlatform returns whether the platform is in the db
+func hasPlatform(t *testing.T, plat string) bool {
+	_, err := getPlatform(testGuild, plat)
+	if err != nil && err != ErrNoPlatform {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+// TestTagsAdd adds a tag to an existing platform
+func TestTagsAdd(t *testing.T) {
+	seedTags(t)
+	ses := newTestSession()
+
+	got, err := run(t, ses, newTagsAdd(), testOther, "pc", "ali", "ce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "Success!") {
+		t.Errorf("!tags add sent %q", got)
+	}
+
+	utg := getTag(t, "pc", testOther)
+	if utg == nil {
+		t.Fatalf("!tags add didn't add a tag")
+	}
+	if utg.Tag != "ali ce" || !utg.PingMe {
+		t.Errorf("!tags add added %#v", utg)
+	}
+
+	// too long
+	_, err = run(t, ses, newTagsAdd(), testOther, "pc", strings.Repeat("a", cfg().TagLimit+1))
+	if err != ErrTagTooLong {
+		t.Errorf("!tags add with long tag threw %v; want %v", err, ErrTagTooLong)
+	}
+}
+
+// TestTagsGetRemove gets and removes a tag
+func TestTagsGetRemove(t *testing.T) {
+	seedTags(t)
+	ses := newTestSession()
+
+	got, err := run(t, ses, newTagsGet(), testUser, "pc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "bobby") {
+		t.Errorf("!tags get sent %q", got)
+	}
+
+	_, err = run(t, ses, newTagsGet(), testOther, "pc")
+	if err != ErrNoUser {
+		t.Errorf("!tags get without tag threw %v; want %v", err, ErrNoUser)
+	}
+
+	_, err = run(t, ses, newTagsGet(), testUser, "xbox")
+	if err != ErrNoPlatform {
+		t.Errorf("!tags get on bad platform threw %v; want %v", err, ErrNoPlatform)
+	}
+
+	// removing the last tag removes the platform
+	_, err = run(t, ses, newTagsRemove(), testUser, "pc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasPlatform(t, "pc") {
+		t.Errorf("!tags remove left an empty platform")
+	}
+}
+
+// TestTagsPingMe turns pings on and off
+func TestTagsPingMe(t *testing.T) {
+	seedTags(t)
+	ses := newTestSession()
+
+	_, err := run(t, ses, newTagsPingMe(), testUser, "pc", "false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getTag(t, "pc", testUser).PingMe {
+		t.Errorf("!tags pingme false didn't turn off pings")
+	}
+
+	got, err := run(t, ses, newTagsPing(), testOther, "pc", "anyone?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "No one wants") {
+		t.Errorf("!tags ping with no pingers sent %q", got)
+	}
+
+	_, err = run(t, ses, newTagsPingMe(), testUser, "pc", "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = run(t, ses, newTagsPing(), testOther, "pc", "anyone?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "<@!"+testUser+">") {
+		t.Errorf("!tags ping sent %q; want a mention of %s", got, testUser)
+	}
+}
+
+// TestTagsUser looks up tags by username
+func TestTagsUser(t *testing.T) {
+	seedTags(t)
+	ses := newTestSession()
+
+	got, err := run(t, ses, newTagsUser(), testOther, "BOB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "bob's tags") || !strings.Contains(got, "bobby") {
+		t.Errorf("!tags user BOB sent %q", got)
+	}
+
+	got, err = run(t, ses, newTagsUser(), testOther, "<@!"+testUser+">")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "bob's tags") {
+		t.Errorf("!tags user @bob sent %q", got)
+	}
+
+	_, err = run(t, ses, newTagsUser(), testOther, "nobody")
+	if argErr, ok := err.(*commands.ArgError); !ok || argErr.Err != commands.ErrArgNotFound {
+		t.Errorf("!tags user nobody threw %v; want %v", err, commands.ErrArgNotFound)
+	}
+
+	_, err = run(t, ses, newTagsUser(), testOther)
+	if err != ErrNoUserTags {
+		t.Errorf("!tags user on self without tags threw %v; want %v", err, ErrNoUserTags)
+	}
+}
+
+// TestTagsList pages through a platform's tags
+func TestTagsList(t *testing.T) {
+	seedTags(t)
+	ses := newTestSession()
+
+	got, err := run(t, ses, newTagsList(), testUser, "pc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "pc's tags:") || !strings.Contains(got, "bobby") {
+		t.Errorf("!tags list sent %q", got)
+	}
+}
+
+// TestTagsNewPlatform confirms creating and removing platforms
+func TestTagsNewPlatform(t *testing.T) {
+	seedTags(t)
+	ses := newTestSession()
+
+	answer(ses, testOther, commands.PromptDeny)
+	got, err := run(t, ses, newTagsAdd(), testOther, "xbox", "ali")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Aborting platform creation." {
+		t.Errorf("!tags add denied sent %q", got)
+	}
+
+	answer(ses, testOther, commands.PromptConfirm)
+	_, err = run(t, ses, newTagsAdd(), testOther, "xbox", "ali")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasPlatform(t, "xbox") {
+		t.Fatalf("!tags add confirmed didn't create the platform")
+	}
+
+	answer(ses, testOther, commands.PromptConfirm)
+	_, err = run(t, ses, newTagsModRemove(), testOther, "xbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasPlatform(t, "xbox") || getTag(t, "xbox", testOther) != nil {
+		t.Errorf("!tags modremove confirmed didn't remove the platform and its tags")
+	}
+}
+
+// TestTagsShutup turns off pings on every platform
+func TestTagsShutup(t *testing.T) {
+	seedTags(t)
+	commands.DBSet(&platform{Name: "xbox"}, commands.RecordKey(testGuild, "xbox"))
+	commands.DBSet(&tag{UID: testUser, Tag: "bob", Platform: "xbox", PingMe: true}, commands.RecordKey(testGuild, "xbox", testUser))
+	commands.DBSet(&tag{UID: testOther, Tag: "ali", Platform: "xbox", PingMe: true}, commands.RecordKey(testGuild, "xbox", testOther))
+	ses := newTestSession()
+
+	_, err := run(t, ses, newTagsShutup(), testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getTag(t, "pc", testUser).PingMe || getTag(t, "xbox", testUser).PingMe {
+		t.Errorf("!tags shutup left pings on")
+	}
+	if !getTag(t, "xbox", testOther).PingMe {
+		t.Errorf("!tags shutup turned off someone else's pings")
+	}
+}
+
+// TestMigrateTags splits the old tags blob into a record per platform and tag
+func TestMigrateTags(t *testing.T) {
+	clearDB(t)
+	tgs := tagStorer{map[string]*platform{
+		"pc": &platform{
+			Name: "pc",
+			Users: map[string]*tag{
+				testUser: &tag{UID: testUser, Username: "bob", Tag: "bobby", Platform: "pc", PingMe: true},
+			},
+		},
+	}}
+	_, _, err := commands.DBSet(&tgs, commands.GuildKey(testGuild, tagsKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = commands.DBTransact(migrations[0].Up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasPlatform(t, "pc") {
+		t.Errorf("migrating didn't make the platform")
+	}
+	if utg := getTag(t, "pc", testUser); utg == nil || utg.Tag != "bobby" {
+		t.Errorf("migrating made tag %#v", utg)
+	}
+	if err = commands.DBGet(&tgs, commands.GuildKey(testGuild, tagsKey), &tgs); err != commands.ErrDBNotFound {
+		t.Errorf("migrating left the old tags, got %v", err)
+	}
+}
+
+// TestImportTags adds tags from the old sqlite db, then opts them all into pings like cmd/pingme does
+func TestImportTags(t *testing.T) {
+	clearDB(t)
+
+	n, err := ImportTags(testGuild, map[string]map[string]string{
+		"pc":   {testUser: "bobby", testOther: "ally"},
+		"xbox": {testUser: "bob360"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("ImportTags added %d tags; want 3", n)
+	}
+	if !hasPlatform(t, "pc") || !hasPlatform(t, "xbox") {
+		t.Errorf("importing didn't make the platforms")
+	}
+	if utg := getTag(t, "xbox", testUser); utg == nil || utg.Tag != "bob360" || utg.PingMe {
+		t.Errorf("importing made tag %#v; want bob360 without pings", utg)
+	}
+
+	n, err = PingAllTags(testGuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("PingAllTags changed %d tags; want 3", n)
+	}
+	if !getTag(t, "pc", testOther).PingMe {
+		t.Errorf("PingAllTags didn't turn on pings")
+	}
+}
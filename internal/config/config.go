@@ -0,0 +1,530 @@
+// Package config is the bot's runtime configuration, loaded from a JSON file and the environment
+//
+// Settings are addressed by key, which is the path of json names through the config:
+//
+//	prefix
+//	bad_words
+//	emoji.cw
+//	guilds.<guild id>.channels.log
+//	groups.mod
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvPrefix is prepended to the env tags of Config's fields to get their environment variables
+	EnvPrefix = "PCSOC_"
+)
+
+var (
+	// ErrNoKey means the key isn't a setting
+	ErrNoKey = errors.New("no setting with that key")
+	// ErrBadValue means the value can't be parsed for the setting
+	ErrBadValue = errors.New("bad value for that setting")
+
+	snowflake = regexp.MustCompile(`^[0-9]+$`)
+	emojiTag  = regexp.MustCompile(`^<a?:\w+:[0-9]+>$`)
+)
+
+// Config is the bot's runtime configuration
+type Config struct {
+	Prefix   string `json:"prefix" env:"PREFIX"`
+	Timezone string `json:"timezone" env:"TIMEZONE"`
+
+	Store     string `json:"store" env:"STORE"`           // db backend, see commands.Stores
+	StorePath string `json:"store_path" env:"STORE_PATH"` // where the store keeps the db in production
+
+	BackupDir   string `json:"backup_dir" env:"BACKUP_DIR"`     // where snapshots of the db go, none are taken if it's empty
+	BackupHours int    `json:"backup_hours" env:"BACKUP_HOURS"` // between snapshots
+	BackupKeep  int    `json:"backup_keep" env:"BACKUP_KEEP"`   // newest snapshots to keep, all of them if 0
+	BackupDays  int    `json:"backup_days" env:"BACKUP_DAYS"`   // snapshots older than this are removed, none if 0
+
+	CacheLimit    int `json:"cache_limit" env:"CACHE_LIMIT"`       // deleted messages to remember for logging
+	HistoryLimit  int `json:"history_limit" env:"HISTORY_LIMIT"`   // reacted messages to remember for archiving
+	TagLimit      int `json:"tag_limit" env:"TAG_LIMIT"`           // longest tag
+	PlatformLimit int `json:"platform_limit" env:"PLATFORM_LIMIT"` // longest tags platform
+
+	BadWords []string          `json:"bad_words" env:"BAD_WORDS"` // regexes for the message filter
+	Emoji    map[string]string `json:"emoji"`                     // custom emoji by name
+
+	Admins []string            `json:"admins" env:"ADMINS"` // user ids that can change settings for every guild and the whole db
+	Groups map[string][]string `json:"groups,omitempty"`    // permission groups in every guild, see commands.HasRoles
+	Guilds map[string]*Guild   `json:"guilds"`              // indexed by guild id
+
+	LegacyGuild string `json:"legacy_guild,omitempty" env:"LEGACY_GUILD"` // gets the data from before the bot had more than one guild, see LegacyGuildID
+}
+
+// Guild is the configuration for a guild
+type Guild struct {
+	Prefix   string              `json:"prefix,omitempty"` // used instead of the bot's prefix if set
+	Channels map[string]string   `json:"channels"`         // channel ids by what they're for e.g. "log"
+	Groups   map[string][]string `json:"groups,omitempty"` // permission groups, used instead of the bot's with the same name
+}
+
+// Default returns the config pcsocgo has always used
+func Default() *Config {
+	return &Config{
+		Prefix:   "!",
+		Timezone: "Australia/Sydney",
+
+		Store:     "buntdb",
+		StorePath: "./bot.db",
+
+		BackupHours: 24,
+		BackupKeep:  7,
+		BackupDays:  30,
+
+		CacheLimit:    100,
+		HistoryLimit:  2000,
+		TagLimit:      64,
+		PlatformLimit: 20,
+
+		BadWords: []string{
+			"(?i)kms",
+			"(?i)kill[[:space:]]*myself",
+			"(?i)kill[[:space:]]*me",
+			"(?i)retard",
+			"(?i)ni[bg]+er",
+			"(?i)ni[bg]+a",
+			"(?i)autis[tm]",
+			"(?i)nang",
+			"(?i)my[[:space:]]address[[:space:]]is",
+			"(?i)i[[:space:]]want[[:space:]]to[[:space:]]jump[[:space:]]off[[:space:]]a[[:space:]]tall[[:space:]]building[[:space:]]and[[:space:]]splatter[[:space:]]into[[:space:]]a[[:space:]]million[[:space:]]pieces",
+		},
+
+		Emoji: map[string]string{
+			"cw":   "<:cw:590153701252005907>",
+			"c1_0": "<:c1_0:590153698324381698>",
+			"c2_0": "<:c2_0:590153703609204760>",
+			"c1_1": "<:c1_1:590153699372826634>",
+			"c3_1": "<:c3_1:590153701281497109>",
+			"c0_2": "<:c0_2:590153690493747220>",
+			"c1_2": "<:c1_2:590153704129298442>",
+			"c2_2": "<:c2_2:590153702443319307>",
+			"c3_2": "<:c3_2:590153704121040898>",
+			"c0_3": "<:c0_3:590153695363203072>",
+			"c1_3": "<:c1_3:590153703454015493>",
+			"c2_3": "<:c2_3:590153701969231873>",
+			"c3_3": "<:c3_3:590153703697416192>",
+		},
+
+		Guilds: map[string]*Guild{},
+	}
+}
+
+// Load returns the default config overridden by the file at path then the environment
+//
+// A missing file leaves the defaults, the config isn't validated.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if len(path) > 0 {
+		fp, err := os.Open(path)
+		if err == nil {
+			defer fp.Close()
+			dec := json.NewDecoder(fp)
+			dec.DisallowUnknownFields()
+			err = dec.Decode(c)
+			if err != nil {
+				return nil, errors.New(path + ": " + err.Error())
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	err := c.loadEnv()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadEnv sets fields that have their environment variable set
+func (c *Config) loadEnv() error {
+	typ := reflect.TypeOf(c).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		env, ok := f.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		val, ok := os.LookupEnv(EnvPrefix + env)
+		if !ok {
+			continue
+		}
+		err := c.Set(jsonName(f), val)
+		if err != nil {
+			return errors.New(EnvPrefix + env + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Validate returns an error describing the first bad setting, if any
+func (c *Config) Validate() error {
+	if len(c.Prefix) == 0 || !validPrefix(c.Prefix) {
+		return errors.New("prefix: must be non-empty with no spaces")
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return errors.New("timezone: " + err.Error())
+	}
+	if len(c.Store) == 0 {
+		return errors.New("store: must be non-empty")
+	}
+	if len(c.StorePath) == 0 {
+		return errors.New("store_path: must be non-empty")
+	}
+	if c.BackupHours <= 0 {
+		return errors.New("backup_hours: must be more than 0")
+	}
+	if c.BackupKeep < 0 {
+		return errors.New("backup_keep: must be 0 or more")
+	}
+	if c.BackupDays < 0 {
+		return errors.New("backup_days: must be 0 or more")
+	}
+
+	limits := map[string]int{
+		"cache_limit":    c.CacheLimit,
+		"history_limit":  c.HistoryLimit,
+		"tag_limit":      c.TagLimit,
+		"platform_limit": c.PlatformLimit,
+	}
+	for key, lim := range limits {
+		if lim <= 0 {
+			return errors.New(key + ": must be more than 0")
+		}
+	}
+
+	for _, bw := range c.BadWords {
+		if _, err := regexp.Compile(bw); err != nil {
+			return errors.New("bad_words: " + err.Error())
+		}
+	}
+
+	for name := range Default().Emoji {
+		if !emojiTag.MatchString(c.Emoji[name]) {
+			return errors.New("emoji." + name + ": must be an emoji like <:name:id>")
+		}
+	}
+
+	for _, uid := range c.Admins {
+		if !snowflake.MatchString(uid) {
+			return errors.New("admins: " + uid + " is not a user id")
+		}
+	}
+
+	if err := validGroups("groups", c.Groups); err != nil {
+		return err
+	}
+
+	if len(c.LegacyGuild) > 0 && !snowflake.MatchString(c.LegacyGuild) {
+		return errors.New("legacy_guild: not a guild id")
+	}
+
+	for gid, g := range c.Guilds {
+		if !snowflake.MatchString(gid) {
+			return errors.New("guilds." + gid + ": not a guild id")
+		}
+		if g == nil {
+			continue
+		}
+		if !validPrefix(g.Prefix) {
+			return errors.New("guilds." + gid + ".prefix: must have no spaces")
+		}
+		for purpose, cid := range g.Channels {
+			if !snowflake.MatchString(cid) {
+				return errors.New("guilds." + gid + ".channels." + purpose + ": not a channel id")
+			}
+		}
+		if err := validGroups("guilds."+gid+".groups", g.Groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validGroups returns an error describing the first bad permission group, if any
+func validGroups(key string, groups map[string][]string) error {
+	for name, perms := range groups {
+		if len(name) == 0 || strings.ContainsAny(name, " \t\n") {
+			return errors.New(key + "." + name + ": group names must be non-empty with no spaces")
+		}
+		for _, perm := range perms {
+			if len(strings.TrimSpace(perm)) == 0 {
+				return errors.New(key + "." + name + ": permissions must be non-empty")
+			}
+		}
+	}
+	return nil
+}
+
+// validPrefix returns whether the prefix has no spaces, mentions are always prefixes so it can't be one either
+func validPrefix(prefix string) bool {
+	return !strings.ContainsAny(prefix, " \t\n") && !strings.HasPrefix(prefix, "<@")
+}
+
+// Prefixes returns the prefixes of the guilds that have set one, indexed by guild id
+func (c *Config) Prefixes() map[string]string {
+	out := make(map[string]string)
+	for gid, g := range c.Guilds {
+		if g != nil && len(g.Prefix) > 0 {
+			out[gid] = g.Prefix
+		}
+	}
+	return out
+}
+
+// PermissionGroups returns the permission groups indexed by guild id then name, "" for the groups in every guild
+func (c *Config) PermissionGroups() map[string]map[string][]string {
+	out := map[string]map[string][]string{"": c.Groups}
+	for gid, g := range c.Guilds {
+		if g != nil && len(g.Groups) > 0 {
+			out[gid] = g.Groups
+		}
+	}
+	return out
+}
+
+// IsAdmin returns whether the user is one of the bot's admins
+func (c *Config) IsAdmin(userID string) bool {
+	for _, uid := range c.Admins {
+		if uid == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// LegacyGuildID returns the guild that gets the data from before the bot had more than one guild
+//
+// It's legacy_guild if that's set, otherwise the only guild in guilds, or empty if there's more than one.
+func (c *Config) LegacyGuildID() string {
+	if len(c.LegacyGuild) > 0 {
+		return c.LegacyGuild
+	}
+	if len(c.Guilds) != 1 {
+		return ""
+	}
+	for gid := range c.Guilds {
+		return gid
+	}
+	return ""
+}
+
+// Location returns the timezone's location, UTC if it's bad
+func (c *Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// BadWordRegexps returns the compiled bad words, skipping bad ones
+func (c *Config) BadWordRegexps() []*regexp.Regexp {
+	out := []*regexp.Regexp{}
+	for _, bw := range c.BadWords {
+		if re, err := regexp.Compile(bw); err == nil {
+			out = append(out, re)
+		}
+	}
+	return out
+}
+
+// Channel returns the channel the guild uses for the purpose, empty if there isn't one
+func (c *Config) Channel(guildID string, purpose string) string {
+	g, ok := c.Guilds[guildID]
+	if !ok || g == nil {
+		return ""
+	}
+	return g.Channels[purpose]
+}
+
+// Clone returns a deep copy of the config
+func (c *Config) Clone() *Config {
+	mar, _ := json.Marshal(c)
+	cpy := &Config{}
+	json.Unmarshal(mar, cpy)
+	return cpy
+}
+
+// Keys returns the keys of all the settings, ordered
+func (c *Config) Keys() []string {
+	out := []string{}
+	var walk func(prefix string, val reflect.Value)
+	walk = func(prefix string, val reflect.Value) {
+		switch val.Kind() {
+		case reflect.Ptr:
+			if !val.IsNil() {
+				walk(prefix, val.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < val.NumField(); i++ {
+				walk(prefix+jsonName(val.Type().Field(i))+".", val.Field(i))
+			}
+		case reflect.Map:
+			for _, k := range val.MapKeys() {
+				walk(prefix+k.String()+".", val.MapIndex(k))
+			}
+		default:
+			out = append(out, strings.TrimSuffix(prefix, "."))
+		}
+	}
+	walk("", reflect.ValueOf(c))
+	sort.Strings(out)
+	return out
+}
+
+// Get returns the setting at key, lists and maps are returned as JSON
+func (c *Config) Get(key string) (string, error) {
+	val, _, err := c.lookup(key, false)
+	if err != nil {
+		return "", err
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		return val.String(), nil
+	case reflect.Int:
+		return strconv.Itoa(int(val.Int())), nil
+	}
+	mar, err := json.Marshal(val.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(mar), nil
+}
+
+// Set parses the value into the setting at key
+//
+// Lists take a JSON array or comma separated values, maps take a JSON object.
+// Setting a map entry to nothing removes it.
+func (c *Config) Set(key string, value string) error {
+	parts := strings.Split(key, ".")
+	if len(value) == 0 && len(parts) > 1 {
+		// removing a map entry
+		par, _, err := c.lookup(strings.Join(parts[:len(parts)-1], "."), false)
+		if err == nil && par.Kind() == reflect.Map {
+			par.SetMapIndex(reflect.ValueOf(parts[len(parts)-1]), reflect.Value{})
+			return nil
+		}
+	}
+
+	val, commit, err := c.lookup(key, true)
+	if err != nil {
+		return err
+	}
+	defer commit()
+
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(value)
+		return nil
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return ErrBadValue
+		}
+		val.SetInt(int64(n))
+		return nil
+	}
+
+	ptr := reflect.New(val.Type())
+	err = json.Unmarshal([]byte(value), ptr.Interface())
+	if err != nil {
+		if val.Kind() != reflect.Slice || val.Type().Elem().Kind() != reflect.String {
+			return ErrBadValue
+		}
+		// comma separated
+		list := []string{}
+		for _, s := range strings.Split(value, ",") {
+			list = append(list, strings.TrimSpace(s))
+		}
+		ptr.Elem().Set(reflect.ValueOf(list))
+	}
+	val.Set(ptr.Elem())
+	return nil
+}
+
+// lookup returns the settable value at key, map entries are made on the way if create is set
+//
+// Map entries can't be set in place, so the value is a copy that commit writes back.
+func (c *Config) lookup(key string, create bool) (val reflect.Value, commit func(), err error) {
+	commit = func() {}
+	if len(key) == 0 {
+		return reflect.Value{}, commit, ErrNoKey
+	}
+
+	val = reflect.ValueOf(c).Elem()
+	for _, part := range strings.Split(key, ".") {
+		switch val.Kind() {
+		case reflect.Struct:
+			found := false
+			for i := 0; i < val.NumField(); i++ {
+				if jsonName(val.Type().Field(i)) == part {
+					val = val.Field(i)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return reflect.Value{}, commit, ErrNoKey
+			}
+
+		case reflect.Map:
+			if val.IsNil() {
+				if !create {
+					return reflect.Value{}, commit, ErrNoKey
+				}
+				val.Set(reflect.MakeMap(val.Type()))
+			}
+
+			m, k := val, reflect.ValueOf(part)
+			ent := m.MapIndex(k)
+			if !ent.IsValid() || (ent.Kind() == reflect.Ptr && ent.IsNil()) {
+				if !create {
+					return reflect.Value{}, commit, ErrNoKey
+				}
+				ent = reflect.Zero(m.Type().Elem())
+				if ent.Kind() == reflect.Ptr {
+					ent = reflect.New(m.Type().Elem().Elem())
+					m.SetMapIndex(k, ent)
+				}
+			}
+
+			if ent.Kind() == reflect.Ptr {
+				// set through the pointer
+				val = ent.Elem()
+				continue
+			}
+			val = reflect.New(ent.Type()).Elem()
+			val.Set(ent)
+			commit = func() { m.SetMapIndex(k, val) }
+
+		default:
+			return reflect.Value{}, commit, ErrNoKey
+		}
+	}
+	return val, commit, nil
+}
+
+// jsonName returns the name of the field in JSON
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if len(name) == 0 {
+		return f.Name
+	}
+	return name
+}
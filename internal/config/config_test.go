@@ -0,0 +1,157 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/unswpcsoc/pcsocgo/internal/config"
+)
+
+// TestDefault checks the defaults are valid
+func TestDefault(t *testing.T) {
+	err := Default().Validate()
+	if err != nil {
+		t.Errorf("default config isn't valid: %v", err)
+	}
+}
+
+// TestLoad loads a file then the environment over the defaults
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{"prefix": "?", "tag_limit": 10}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(EnvPrefix+"TAG_LIMIT", "12")
+	os.Setenv(EnvPrefix+"BAD_WORDS", "a, b")
+	defer os.Unsetenv(EnvPrefix + "TAG_LIMIT")
+	defer os.Unsetenv(EnvPrefix + "BAD_WORDS")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Prefix != "?" || c.TagLimit != 12 || c.PlatformLimit != Default().PlatformLimit {
+		t.Errorf("loaded prefix %q, tag_limit %d, platform_limit %d; want ?, 12 and the default",
+			c.Prefix, c.TagLimit, c.PlatformLimit)
+	}
+	if !reflect.DeepEqual(c.BadWords, []string{"a", "b"}) {
+		t.Errorf("loaded bad_words %q; want [a b]", c.BadWords)
+	}
+
+	// missing files are fine, typos aren't
+	if _, err = Load(filepath.Join(dir, "missing.json")); err != nil {
+		t.Errorf("loading a missing file threw %v", err)
+	}
+	ioutil.WriteFile(path, []byte(`{"prefx": "?"}`), 0644)
+	if _, err = Load(path); err == nil {
+		t.Errorf("loading an unknown setting didn't throw an error")
+	}
+}
+
+// TestSet sets and gets settings by key
+func TestSet(t *testing.T) {
+	c := Default()
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{"prefix", "$", "$"},
+		{"cache_limit", "5", "5"},
+		{"bad_words", `["x,y"]`, `["x,y"]`},
+		{"emoji.cw", "<:cw:1>", "<:cw:1>"},
+		{"guilds.1.channels.log", "2", "2"},
+		{"groups.mod", "Mod, ManageMessages", `["Mod","ManageMessages"]`},
+	}
+	for _, tt := range tests {
+		err := c.Set(tt.key, tt.value)
+		if err != nil {
+			t.Errorf("Set(%q, %q) threw %v", tt.key, tt.value, err)
+			continue
+		}
+		got, err := c.Get(tt.key)
+		if err != nil || got != tt.want {
+			t.Errorf("Get(%q) = %q, %v; want %q", tt.key, got, err, tt.want)
+		}
+	}
+	if c.Channel("1", "log") != "2" {
+		t.Errorf("Channel(1, log) = %q; want 2", c.Channel("1", "log"))
+	}
+
+	// removing map entries
+	c.Set("guilds.1.channels.log", "")
+	if _, err := c.Get("guilds.1.channels.log"); err != ErrNoKey {
+		t.Errorf("getting a removed channel threw %v; want %v", err, ErrNoKey)
+	}
+
+	if err := c.Set("nope", "1"); err != ErrNoKey {
+		t.Errorf("setting an unknown key threw %v; want %v", err, ErrNoKey)
+	}
+	if err := c.Set("tag_limit", "lots"); err != ErrBadValue {
+		t.Errorf("setting a bad int threw %v; want %v", err, ErrBadValue)
+	}
+
+	// Validate catches bad values
+	c = Default()
+	c.Set("timezone", "Mars/Olympus")
+	if c.Validate() == nil {
+		t.Errorf("bad timezone was valid")
+	}
+	c = Default()
+	c.Set("guilds.1.channels.log", "#general")
+	if c.Validate() == nil {
+		t.Errorf("bad channel id was valid")
+	}
+}
+
+// TestLegacyGuildID picks legacy_guild, or the only guild there is
+func TestLegacyGuildID(t *testing.T) {
+	c := Default()
+	if gid := c.LegacyGuildID(); gid != "" {
+		t.Errorf("LegacyGuildID with no guilds = %q; want none", gid)
+	}
+
+	c.Guilds["41"] = &Guild{}
+	if gid := c.LegacyGuildID(); gid != "41" {
+		t.Errorf("LegacyGuildID with only 41 = %q; want 41", gid)
+	}
+
+	c.Guilds["42"] = &Guild{}
+	if gid := c.LegacyGuildID(); gid != "" {
+		t.Errorf("LegacyGuildID with two guilds = %q; want none", gid)
+	}
+
+	c.Set("legacy_guild", "42")
+	if gid := c.LegacyGuildID(); gid != "42" {
+		t.Errorf("LegacyGuildID with legacy_guild set = %q; want 42", gid)
+	}
+
+	c.LegacyGuild = "pcsoc"
+	if c.Validate() == nil {
+		t.Errorf("legacy_guild that isn't an id didn't fail validation")
+	}
+}
+
+// TestExample checks the example config loads and is valid
+func TestExample(t *testing.T) {
+	c, err := Load(filepath.Join("..", "..", "config.example.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Validate(); err != nil {
+		t.Errorf("example config isn't valid: %v", err)
+	}
+	if c.LegacyGuildID() == "" {
+		t.Errorf("example config has no guild")
+	}
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"sort"
 	"strings"
+	"sync"
 
 	comm "github.com/unswpcsoc/pcsocgo/commands"
 )
@@ -22,23 +23,57 @@ func NewLeaf(com comm.Command) *Leaf {
 }
 
 // Router routes a command string to a command.
+//
+// Commands can be added and removed while routing.
 type Router struct {
-	Routes *Leaf
+	Routes    *Leaf
+	FoldCase  bool // route regardless of case, set before adding commands
+	MinAbbrev int  // shortest unique prefix of an alias word that routes to it, 0 for whole words only
+	mu        sync.RWMutex
+}
+
+// ConflictError means an alias is already routed to another command.
+type ConflictError struct {
+	Alias string
+	Owner comm.Command // the command that has the alias
+}
+
+func (e *ConflictError) Error() string {
+	return "alias \"" + e.Alias + "\" is already used by " + e.Owner.Aliases()[0]
 }
 
 // NewRouter returns a new Router structure.
 func NewRouter() *Router {
-	return &Router{NewLeaf(nil)}
+	return &Router{Routes: NewLeaf(nil)}
+}
+
+// split returns the words of the alias, folded if the router folds case
+func (r *Router) split(alias string) []string {
+	if r.FoldCase {
+		alias = strings.ToLower(alias)
+	}
+	return strings.Split(alias, " ")
 }
 
 // AddCommand adds command-string mapping
-func (r *Router) AddCommand(com comm.Command) {
+//
+// If another command has one of the aliases, none are added and a *ConflictError is returned.
+func (r *Router) AddCommand(com comm.Command) error {
 	if com == nil || len(com.Aliases()) == 0 || r.Routes == nil {
-		return
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, str := range com.Aliases() {
+		if own := r.owner(str); own != nil && own != com {
+			return &ConflictError{str, own}
+		}
 	}
 
 	for _, str := range com.Aliases() {
-		argv := strings.Split(str, " ")
+		argv := r.split(str)
 
 		// Search all known leaves
 		curr := r.Routes
@@ -66,10 +101,80 @@ func (r *Router) AddCommand(com comm.Command) {
 		// Assign command to the final leaf
 		curr.Command = com
 	}
+	return nil
+}
+
+// Owner returns the command with the whole alias, or nil if there isn't one.
+func (r *Router) Owner(alias string) comm.Command {
+	if r.Routes == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.owner(alias)
+}
+
+func (r *Router) owner(alias string) comm.Command {
+	curr := r.Routes
+	for _, arg := range r.split(alias) {
+		next, found := curr.Leaves[arg]
+		if !found {
+			return nil
+		}
+		curr = next
+	}
+	return curr.Command
+}
+
+// RemoveCommand removes the command's routes, leaving routes its aliases share with other commands.
+// Returns whether any routes were removed.
+func (r *Router) RemoveCommand(com comm.Command) bool {
+	if com == nil || r.Routes == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := false
+	for _, str := range com.Aliases() {
+		argv := r.split(str)
+
+		// Find the leaf, remembering the path to it
+		path := []*Leaf{r.Routes}
+		curr := r.Routes
+		for _, arg := range argv {
+			next, found := curr.Leaves[arg]
+			if !found {
+				curr = nil
+				break
+			}
+			curr = next
+			path = append(path, curr)
+		}
+		if curr == nil || curr.Command != com {
+			continue
+		}
+		curr.Command = nil
+		removed = true
+
+		// Prune leaves that route nowhere, from the bottom up
+		for i := len(argv) - 1; i >= 0; i-- {
+			leaf := path[i+1]
+			if leaf.Command != nil || len(leaf.Leaves) > 0 {
+				break
+			}
+			delete(path[i].Leaves, argv[i])
+		}
+	}
+	return removed
 }
 
 // Route routes to handler from string.
 // Returns the command and the number of matched args.
+// Args that aren't a whole word of an alias match the only word they're a prefix of,
+// if they're at least MinAbbrev long and the command before them takes no args.
 // e.g.
 //	   // r has a route through "example"->"command"->"string"
 //     com, ind := r.Route([]string{"example", "command", "string", "with", "args"})
@@ -81,13 +186,16 @@ func (r *Router) Route(argv []string) (comm.Command, int) {
 		return nil, 0
 	}
 
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	// iterate through routes
 	i := 0
 	curr := r.Routes
 	var prev *Leaf = nil
 	var ok bool
 	for i = 0; i < len(argv); i++ {
-		curr, ok = curr.Leaves[argv[i]]
+		curr, ok = r.child(curr, argv[i])
 		if !ok {
 			break
 		}
@@ -101,11 +209,44 @@ func (r *Router) Route(argv []string) (comm.Command, int) {
 	return prev.Command, i
 }
 
+// child returns the leaf the arg routes to from curr, exact matches first then unique abbreviations
+//
+// Words aren't abbreviated under a command that takes args, they're more likely its args than a subcommand.
+func (r *Router) child(curr *Leaf, arg string) (*Leaf, bool) {
+	if r.FoldCase {
+		arg = strings.ToLower(arg)
+	}
+	if next, ok := curr.Leaves[arg]; ok {
+		return next, true
+	}
+	if r.MinAbbrev <= 0 || len(arg) < r.MinAbbrev {
+		return nil, false
+	}
+	if curr.Command != nil && comm.TakesArgs(curr.Command) {
+		return nil, false
+	}
+
+	var found *Leaf
+	for key, next := range curr.Leaves {
+		if strings.HasPrefix(key, arg) {
+			if found != nil {
+				// ambiguous
+				return nil, false
+			}
+			found = next
+		}
+	}
+	return found, found != nil
+}
+
 // ToSlice searches the tree and populates a slice of Commands
 // sorted by the first alias name
 //
 // Duplicates are removed in case you were wondering
 func (r *Router) ToSlice() []comm.Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var commands = make(map[comm.Command]bool)
 	var doToSlice func(*Leaf)
 
@@ -8,7 +8,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 
 	comm "github.com/unswpcsoc/pcsocgo/commands"
-	. "github.com/unswpcsoc/pcsocgo/router"
+	. "github.com/unswpcsoc/pcsocgo/internal/router"
 )
 
 // signal testing
@@ -29,11 +29,13 @@ func (e *Example) Aliases() []string { return []string{"example", "an extended c
 
 func (e *Example) Desc() string { return "Example!" }
 
+func (e *Example) Subcommands() []comm.Command { return nil }
+
 func (e *Example) Roles() []string { return nil }
 
 func (e *Example) Chans() []string { return nil }
 
-func (e *Example) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comm.CommandSend, error) {
+func (e *Example) MsgHandle(ses comm.Session, msg *discordgo.Message) (*comm.CommandSend, error) {
 	return nil, nil
 }
 
@@ -50,14 +52,40 @@ func (e *Example2) Aliases() []string {
 
 func (e *Example2) Desc() string { return "Example2!" }
 
+func (e *Example2) Subcommands() []comm.Command { return nil }
+
 func (e *Example2) Roles() []string { return nil }
 
 func (e *Example2) Chans() []string { return nil }
 
-func (e *Example2) MsgHandle(ses *discordgo.Session, msg *discordgo.Message) (*comm.CommandSend, error) {
+func (e *Example2) MsgHandle(ses comm.Session, msg *discordgo.Message) (*comm.CommandSend, error) {
 	return nil, nil
 }
 
+type Named struct {
+	names []string
+}
+
+func (n *Named) Aliases() []string { return n.names }
+
+func (n *Named) Desc() string { return "Named!" }
+
+func (n *Named) Subcommands() []comm.Command { return nil }
+
+func (n *Named) Roles() []string { return nil }
+
+func (n *Named) Chans() []string { return nil }
+
+func (n *Named) MsgHandle(ses comm.Session, msg *discordgo.Message) (*comm.CommandSend, error) {
+	return nil, nil
+}
+
+// WithArgs is a command that takes an arg, like !tags
+type WithArgs struct {
+	Named
+	Name string `arg:"name"`
+}
+
 /* tests */
 
 func TestMain(m *testing.M) {
@@ -73,7 +101,7 @@ func TestAddcommand(t *testing.T) {
 	exp := NewExample()
 
 	// add to router
-	router.Addcommand(exp)
+	router.AddCommand(exp)
 
 	// assert single route made
 	r1 := "example"
@@ -159,7 +187,7 @@ func TestAddRoute(t *testing.T) {
 	exp := NewExample()
 
 	// add simple route
-	router.Addcommand(exp)
+	router.AddCommand(exp)
 
 	// assert simple routing works
 	got, ind := router.Route([]string{"example"})
@@ -185,14 +213,170 @@ func TestToSlice(t *testing.T) {
 	router := NewRouter()
 
 	// create commands
-	router.Addcommand(NewExample())
-	router.Addcommand(NewExample2())
+	router.AddCommand(NewExample())
+	router.AddCommand(NewExample2())
 
-	// get slice
-	exp := []comm.Command{&Example{}, &Example2{}}
+	// get slice, sorted by first alias
+	exp := []comm.Command{&Example2{}, &Example{}}
 	got := router.ToSlice()
 
 	if !reflect.DeepEqual(got, exp) {
 		t.Errorf("%s: got %#v\nexpected %#v", t.Name(), got, exp)
 	}
 }
+
+func TestRemoveCommand(t *testing.T) {
+	// init router
+	router := NewRouter()
+
+	// create commands sharing the start of a route
+	exp := NewExample()
+	exp2 := NewExample2()
+	router.AddCommand(exp)
+	router.AddCommand(exp2)
+
+	// assert removing works
+	if !router.RemoveCommand(exp) {
+		t.Errorf("%s: did not remove %v\n", t.Name(), exp)
+	}
+	got, _ := router.Route([]string{"example"})
+	if got != nil {
+		t.Errorf("%s: got %#v after removing, expected nil\n", t.Name(), got)
+	}
+	if _, ok := router.Routes.Leaves["example"]; ok {
+		t.Errorf("%s: left an empty leaf for example\n", t.Name())
+	}
+
+	// assert shared routes are kept
+	got, ind := router.Route([]string{"an", "extended", "command", "string", "2"})
+	if got != exp2 || ind != 5 {
+		t.Errorf("%s: got %#v at %d, expected %#v at 5\n", t.Name(), got, ind, exp2)
+	}
+	if _, ok := router.Routes.Leaves["an"].Leaves["extended"].Leaves["command"].Leaves["string"]; !ok {
+		t.Errorf("%s: pruned a leaf another command uses\n", t.Name())
+	}
+
+	// assert removing twice does nothing
+	if router.RemoveCommand(exp) {
+		t.Errorf("%s: removed %v twice\n", t.Name(), exp)
+	}
+}
+
+func TestAddConflict(t *testing.T) {
+	// init router
+	router := NewRouter()
+
+	// create commands with the same alias
+	exp := NewExample()
+	router.AddCommand(exp)
+	con := &Named{names: []string{"new", "example"}}
+
+	// assert conflict is reported with the owner
+	err := router.AddCommand(con)
+	cerr, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("%s: got error %v, expected a *ConflictError\n", t.Name(), err)
+	}
+	if cerr.Alias != "example" || cerr.Owner != exp {
+		t.Errorf("%s: got conflict on %s with %v, expected example with %v\n", t.Name(), cerr.Alias, cerr.Owner, exp)
+	}
+
+	// assert nothing was added or overwritten
+	if got := router.Owner("new"); got != nil {
+		t.Errorf("%s: added %v despite the conflict\n", t.Name(), got)
+	}
+	if got := router.Owner("example"); got != exp {
+		t.Errorf("%s: example is owned by %v, expected %v\n", t.Name(), got, exp)
+	}
+
+	// assert adding the same command again is fine
+	if err = router.AddCommand(exp); err != nil {
+		t.Errorf("%s: adding %v again threw %v\n", t.Name(), exp, err)
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	// init router
+	router := NewRouter()
+	router.FoldCase = true
+
+	// create command
+	exp := NewExample()
+	router.AddCommand(exp)
+
+	// assert case is ignored
+	got, ind := router.Route([]string{"An", "EXTENDED", "command", "String"})
+	if got != exp || ind != 4 {
+		t.Errorf("%s: got %v at %d, expected %v at 4\n", t.Name(), got, ind, exp)
+	}
+
+	// assert case matters by default
+	router = NewRouter()
+	router.AddCommand(exp)
+	if got, _ = router.Route([]string{"Example"}); got != nil {
+		t.Errorf("%s: got %v without folding case, expected nil\n", t.Name(), got)
+	}
+}
+
+func TestAbbrev(t *testing.T) {
+	// init router
+	router := NewRouter()
+	router.MinAbbrev = 2
+
+	// create commands
+	exp := NewExample()
+	exp2 := NewExample2()
+	sub := &Named{names: []string{"example search"}}
+	sub2 := &Named{names: []string{"example set"}}
+	router.AddCommand(exp)
+	router.AddCommand(exp2)
+	router.AddCommand(sub)
+	router.AddCommand(sub2)
+
+	tests := []struct {
+		argv []string
+		exp  comm.Command
+		ind  int
+	}{
+		{[]string{"ex"}, exp, 1},
+		{[]string{"ex", "sea", "args"}, sub, 2},
+		{[]string{"example", "se"}, exp, 1}, // ambiguous
+		{[]string{"e"}, nil, 0},             // too short
+		{[]string{"an", "ext", "com", "str"}, exp, 4},
+		{[]string{"ano", "ex"}, exp2, 2},
+	}
+	for _, tt := range tests {
+		got, ind := router.Route(tt.argv)
+		if got != tt.exp || ind != tt.ind {
+			t.Errorf("%s: routed %v to %v at %d, expected %v at %d\n", t.Name(), tt.argv, got, ind, tt.exp, tt.ind)
+		}
+	}
+}
+
+// TestAbbrevArgs doesn't abbreviate subcommands of a command that takes args
+func TestAbbrevArgs(t *testing.T) {
+	router := NewRouter()
+	router.MinAbbrev = 2
+
+	tags := &WithArgs{Named: Named{names: []string{"tags"}}}
+	user := &Named{names: []string{"tags user"}}
+	router.AddCommand(tags)
+	router.AddCommand(user)
+
+	tests := []struct {
+		argv []string
+		exp  comm.Command
+		ind  int
+	}{
+		{[]string{"ta"}, tags, 1},
+		{[]string{"tags", "user"}, user, 2},
+		{[]string{"tags", "us"}, tags, 1}, // a tag called us
+		{[]string{"ta", "us"}, tags, 1},
+	}
+	for _, tt := range tests {
+		got, ind := router.Route(tt.argv)
+		if got != tt.exp || ind != tt.ind {
+			t.Errorf("%s: routed %v to %v at %d, expected %v at %d\n", t.Name(), tt.argv, got, ind, tt.exp, tt.ind)
+		}
+	}
+}
@@ -8,6 +8,14 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// Session is the part of commands.Session used by the helpers in this package
+type Session interface {
+	State() *discordgo.State
+	GuildMember(guildID, userID string) (*discordgo.Member, error)
+	GuildRoles(guildID string) ([]*discordgo.Role, error)
+	Channel(channelID string) (*discordgo.Channel, error)
+}
+
 // Bold encloses string in bold tags
 func Bold(s string) string {
 	return "**" + s + "**"
@@ -53,7 +61,7 @@ func Reverse(s string) string {
 }
 
 // Unmention returns a string with mentions replaced by nicks/usernames
-func Unmention(ses *discordgo.Session, msg *discordgo.Message, str string) string {
+func Unmention(ses Session, msg *discordgo.Message, str string) string {
 	return regexp.MustCompile(`<@.*>`).ReplaceAllStringFunc(str, func(s string) string {
 		id := strings.Trim(s, "<!@>")
 		member, err := ses.GuildMember(msg.GuildID, id)
@@ -121,13 +129,15 @@ func Strlen(e interface{}) int {
 }
 
 // MsgHasRoles Checks if the author has the required roles
-func MsgHasRoles(ses *discordgo.Session, msg *discordgo.Message, roles []string) (bool, error) {
+//
+// Deprecated: use commands.HasRoles, which also takes role ids, permissions and groups.
+func MsgHasRoles(ses Session, msg *discordgo.Message, roles []string) (bool, error) {
 	if len(roles) == 0 {
 		return true, nil
 	}
 
 	// Get member
-	member, err := ses.State.Member(msg.GuildID, msg.Author.ID)
+	member, err := ses.State().Member(msg.GuildID, msg.Author.ID)
 	if err != nil {
 		member, err = ses.GuildMember(msg.GuildID, msg.Author.ID)
 		if err != nil {
@@ -165,7 +175,9 @@ func MsgHasRoles(ses *discordgo.Session, msg *discordgo.Message, roles []string)
 }
 
 // MsgInChannels Checks if message was sent in the required channels
-func MsgInChannels(s *discordgo.Session, m *discordgo.Message, channels []string) (bool, error) {
+//
+// Deprecated: use commands.InChannels, which also takes channel ids and groups.
+func MsgInChannels(s Session, m *discordgo.Message, channels []string) (bool, error) {
 	if len(channels) == 0 {
 		return true, nil
 	}
@@ -201,7 +213,7 @@ func EmojiAlpha(s string) string {
 		}
 
 		if char == ' ' {
-			out += string(0x1f914)
+			out += string(rune(0x1f914))
 			continue
 		}
 